@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns/exec"
+	"github.com/go-acme/lego/v4/providers/dns/rfc2136"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// ACME challenges which can be answered.
+const (
+	acmeHTTP01    = "http-01"
+	acmeTLSALPN01 = "tls-alpn-01"
+	acmeDNS01     = "dns-01"
+)
+
+// acmeIssuer issues and renews the ACME certificate of a TLS host. Renewed
+// certificates are swapped into the running HTTPS listener.
+type acmeIssuer struct {
+	host     string
+	signer   string
+	caDirURL string
+	kp       *keyProtection
+	// keyType is the type of the certificate keys
+	keyType certcrypto.KeyType
+	// eab binds a new account to an account at the CA
+	eab *registration.RegisterEABOptions
+
+	// challenge is the ACME challenge to answer
+	challenge string
+	// httpPort is the port http-01 challenges are answered on
+	httpPort string
+	// tlsPort is the port of the HTTPS listener, which answers tls-alpn-01
+	tlsPort string
+	// dnsProvider sets the dns-01 records, configured by lego's variables
+	dnsProvider  string
+	dnsResolvers []string
+
+	certFile string
+	keyFile  string
+	certs    *reseed.CertificateStore
+}
+
+// newAcmeIssuer creates an issuer for tlsHost, as the reseed command's flags
+// ask.
+func newAcmeIssuer(c *cli.Context, tlsHost, signer, certFile, keyFile string, kp *keyProtection, certs *reseed.CertificateStore) (*acmeIssuer, error) {
+	a := &acmeIssuer{
+		host:         tlsHost,
+		signer:       signer,
+		caDirURL:     c.String("acmeserver"),
+		kp:           kp,
+		challenge:    c.String("acmeChallenge"),
+		httpPort:     c.String("acmeHTTPPort"),
+		tlsPort:      c.String("port"),
+		dnsProvider:  c.String("acmeDNSProvider"),
+		dnsResolvers: c.StringSlice("acmeDNSResolvers"),
+		certFile:     certFile,
+		keyFile:      keyFile,
+		certs:        certs,
+	}
+	var ok bool
+	if a.keyType, ok = acmeKeyTypes[c.String("acmeKeyType")]; !ok {
+		return nil, fmt.Errorf("unknown ACME key type %q, use ec256, ec384, rsa2048 or rsa4096", c.String("acmeKeyType"))
+	}
+	if kid := c.String("acmeEABKeyID"); kid != "" {
+		hmac := os.Getenv(c.String("acmeEABHMACEnv"))
+		if hmac == "" {
+			return nil, fmt.Errorf("the HMAC key of the external account binding %s must be in $%s", kid, c.String("acmeEABHMACEnv"))
+		}
+		a.eab = &registration.RegisterEABOptions{TermsOfServiceAgreed: true, Kid: kid, HmacEncoded: hmac}
+	}
+	switch a.challenge {
+	case acmeHTTP01, acmeTLSALPN01:
+	case acmeDNS01:
+		if _, err := a.newDNSProvider(); nil != err {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown ACME challenge %q, use %s, %s or %s", a.challenge, acmeHTTP01, acmeTLSALPN01, acmeDNS01)
+	}
+	return a, nil
+}
+
+// acmeKeyTypes are the key types certificates can be issued for.
+var acmeKeyTypes = map[string]certcrypto.KeyType{
+	"ec256":   certcrypto.EC256,
+	"ec384":   certcrypto.EC384,
+	"rsa2048": certcrypto.RSA2048,
+	"rsa4096": certcrypto.RSA4096,
+}
+
+// client returns an ACME client for the saved account of the issuer's
+// contact address at its CA, registering the account if it hasn't been. A
+// new account key is created if create is set and none was saved.
+func (a *acmeIssuer) client(create bool) (*lego.Client, error) {
+	base := acmeAccountBase(a.signer, a.caDirURL)
+	privateKey, err := loadAcmeAccountKey(base, a.host+a.signer+".acme.key", create, a.kp)
+	if nil != err {
+		return nil, err
+	}
+
+	user := NewMyUser(a.signer, privateKey)
+	config := lego.NewConfig(user)
+	config.CADirURL = a.caDirURL
+	config.Certificate.KeyType = a.keyType
+	client, err := lego.NewClient(config)
+	if nil != err {
+		return nil, err
+	}
+	if err := a.setChallenge(client); nil != err {
+		return nil, err
+	}
+	if err := registerAcmeAccount(client, user, base, a.caDirURL, a.eab); nil != err {
+		return nil, err
+	}
+	return client, nil
+}
+
+// setChallenge sets up the client to answer the issuer's challenge. Once the
+// HTTPS listener runs, tls-alpn-01 is answered by it; before, by a server of
+// lego's on the same port.
+func (a *acmeIssuer) setChallenge(client *lego.Client) error {
+	switch a.challenge {
+	case acmeHTTP01:
+		return client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", a.httpPort))
+	case acmeTLSALPN01:
+		if a.certs != nil && a.certs.Listening() {
+			return client.Challenge.SetTLSALPN01Provider(&listenerTLSALPNProvider{certs: a.certs})
+		}
+		return client.Challenge.SetTLSALPN01Provider(tlsalpn01.NewProviderServer("", a.tlsPort))
+	case acmeDNS01:
+		provider, err := a.newDNSProvider()
+		if nil != err {
+			return err
+		}
+		var opts []dns01.ChallengeOption
+		if len(a.dnsResolvers) > 0 {
+			opts = append(opts, dns01.AddRecursiveNameservers(dns01.ParseNameservers(a.dnsResolvers)))
+		}
+		return client.Challenge.SetDNS01Provider(provider, opts...)
+	}
+	return fmt.Errorf("unknown ACME challenge %q", a.challenge)
+}
+
+// newDNSProvider creates the dns-01 provider, which reads its settings from
+// lego's environment variables: EXEC_PATH for exec, and RFC2136_NAMESERVER
+// and RFC2136_TSIG_* for rfc2136.
+func (a *acmeIssuer) newDNSProvider() (challenge.Provider, error) {
+	switch a.dnsProvider {
+	case "exec":
+		return exec.NewDNSProvider()
+	case "rfc2136":
+		return rfc2136.NewDNSProvider()
+	}
+	return nil, fmt.Errorf("unknown ACME DNS provider %q, use exec or rfc2136", a.dnsProvider)
+}
+
+// obtain issues a new certificate for the host.
+func (a *acmeIssuer) obtain() error {
+	client, err := a.client(true)
+	if nil != err {
+		return err
+	}
+	certificates, err := client.Certificate.Obtain(certificate.ObtainRequest{
+		Domains: []string{a.host},
+		Bundle:  true,
+	})
+	if nil != err {
+		return err
+	}
+	return a.save(certificates)
+}
+
+// renew replaces the host's certificate with a new one, and serves it from
+// then on.
+func (a *acmeIssuer) renew() error {
+	certPem, err := os.ReadFile(a.certFile)
+	if nil != err {
+		return err
+	}
+	client, err := a.client(false)
+	if nil != err {
+		return err
+	}
+	certificates, err := client.Certificate.Renew(certificate.Resource{Domain: a.host, Certificate: certPem}, true, false, "")
+	if nil != err {
+		return err
+	}
+	if err := a.save(certificates); nil != err {
+		return err
+	}
+	if a.certs != nil {
+		if err := a.certs.Add(a.certFile, a.keyFile); nil != err {
+			return err
+		}
+		log.Printf("Now serving the renewed TLS certificate of %s\n", a.host)
+	}
+	return nil
+}
+
+func (a *acmeIssuer) save(certificates *certificate.Resource) error {
+	if err := writeIssuedKey(a.keyFile, certificates.PrivateKey, a.kp); nil != err {
+		return err
+	}
+	return writeFileAtomic(a.certFile, certificates.Certificate, 0o600)
+}
+
+// checkUseAcmeCert makes sure there is an ACME certificate for the issuer's
+// host, issuing one if there is none and renewing one which expires within
+// renewBefore. A certificate which is still valid is kept if renewal fails.
+func checkUseAcmeCert(a *acmeIssuer, auto bool, renewBefore time.Duration) error {
+	_, certErr := os.Stat(a.certFile)
+	_, keyErr := os.Stat(a.keyFile)
+	if certErr != nil || keyErr != nil {
+		if certErr != nil {
+			fmt.Printf("Unable to read TLS certificate '%s'\n", a.certFile)
+		}
+		if keyErr != nil {
+			fmt.Printf("Unable to read TLS key '%s'\n", a.keyFile)
+		}
+
+		if !auto {
+			fmt.Printf("Would you like to generate a new certificate with Let's Encrypt or a custom ACME server? '%s'? (y or n): ", a.host)
+			reader := bufio.NewReader(os.Stdin)
+			input, _ := reader.ReadString('\n')
+			if []byte(input)[0] != 'y' {
+				fmt.Println("Continuing without TLS")
+				return nil
+			}
+		}
+		return a.obtain()
+	}
+
+	cert, err := reseed.LoadX509KeyPair(a.certFile, a.keyFile, a.kp.passphrase())
+	if nil != err {
+		return err
+	}
+	if time.Until(cert.Leaf.NotAfter) >= renewBefore {
+		return nil
+	}
+	if err := a.renew(); nil != err {
+		if time.Now().Before(cert.Leaf.NotAfter) {
+			log.Printf("Unable to renew the TLS certificate of %s, keeping it until it can be: %s\n", a.host, err)
+			return nil
+		}
+		return a.obtain()
+	}
+	return nil
+}
+
+// listenerTLSALPNProvider answers tls-alpn-01 challenges on the running
+// HTTPS listener.
+type listenerTLSALPNProvider struct {
+	certs *reseed.CertificateStore
+}
+
+func (p *listenerTLSALPNProvider) Present(domain, token, keyAuth string) error {
+	cert, err := tlsalpn01.ChallengeCert(domain, keyAuth)
+	if nil != err {
+		return err
+	}
+	p.certs.SetChallenge(domain, cert)
+	return nil
+}
+
+func (p *listenerTLSALPNProvider) CleanUp(domain, token, keyAuth string) error {
+	p.certs.ClearChallenge(domain)
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestListenerTLSALPNProvider(t *testing.T) {
+	certs := reseed.NewCertificateStore(nil)
+	provider := &listenerTLSALPNProvider{certs: certs}
+	if err := provider.Present("reseed.example", "token", "token.thumbprint"); err != nil {
+		t.Fatalf("Present failed: %v", err)
+	}
+
+	config, err := certs.GetConfigForClient(&tls.ClientHelloInfo{
+		ServerName:      "reseed.example",
+		SupportedProtos: []string{reseed.ACMETLSALPNProtocol},
+	})
+	if err != nil || config == nil {
+		t.Fatalf("Expected a challenge configuration, got %v", err)
+	}
+	cert, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// id-pe-acmeIdentifier, RFC 8737
+	acmeIdentifier := asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}
+	found := false
+	for _, ext := range cert.Extensions {
+		found = found || ext.Id.Equal(acmeIdentifier)
+	}
+	if !found || len(cert.DNSNames) != 1 || cert.DNSNames[0] != "reseed.example" {
+		t.Errorf("Unexpected challenge certificate for %v", cert.DNSNames)
+	}
+
+	if err := provider.CleanUp("reseed.example", "token", "token.thumbprint"); err != nil {
+		t.Fatalf("CleanUp failed: %v", err)
+	}
+	if _, err := certs.GetConfigForClient(&tls.ClientHelloInfo{
+		ServerName:      "reseed.example",
+		SupportedProtos: []string{reseed.ACMETLSALPNProtocol},
+	}); err == nil {
+		t.Error("Expected no challenge after CleanUp")
+	}
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/registration"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// acmeAccount is the saved registration of an ACME account. There is one
+// account for each contact address and CA, shared by the certificates of
+// every host, saved as base.acme.key and base.acme.json.
+type acmeAccount struct {
+	Email        string                 `json:"email"`
+	CA           string                 `json:"ca"`
+	Registration *registration.Resource `json:"registration"`
+	// EABKeyID is the external account binding the account was registered
+	// with, if the CA asked for one
+	EABKeyID string `json:"eab_kid,omitempty"`
+}
+
+// acmeAccountBase returns the path, without extension, of the account of
+// email at the CA with directory caDirURL.
+func acmeAccountBase(email, caDirURL string) string {
+	ca := caDirURL
+	if u, err := url.Parse(caDirURL); nil == err && u.Host != "" {
+		ca = u.Host
+	}
+	ca = strings.NewReplacer(":", "_", "/", "_").Replace(ca)
+	return signerFile(email) + "." + ca
+}
+
+// acmeAccountFiles lists the saved ACME accounts of email, for any CA.
+func acmeAccountFiles(email string) ([]string, error) {
+	keys, err := globLiteral(signerFile(email), ".*.acme.key")
+	if nil != err {
+		return nil, err
+	}
+	records, err := globLiteral(signerFile(email), ".*.acme.json")
+	if nil != err {
+		return nil, err
+	}
+	return append(keys, records...), nil
+}
+
+// globLiteral matches files starting with the literal prefix and then the
+// pattern.
+func globLiteral(prefix, pattern string) ([]string, error) {
+	escaped := strings.NewReplacer("\\", "\\\\", "*", "\\*", "?", "\\?", "[", "\\[").Replace(prefix)
+	return filepath.Glob(escaped + pattern)
+}
+
+// loadAcmeAccountKey reads the account key at base.acme.key. The key used
+// to be saved for each host, as legacyPath; it is moved to base.acme.key
+// the first time it is loaded. Without either, a new key is created if
+// create is set.
+func loadAcmeAccountKey(base, legacyPath string, create bool, kp *keyProtection) (crypto.Signer, error) {
+	keyPath := base + ".acme.key"
+	key, err := reseed.LoadPrivateKey(keyPath, kp.passphrase())
+	if !os.IsNotExist(err) {
+		return key, err
+	}
+
+	if data, err := os.ReadFile(legacyPath); nil == err {
+		if _, err := reseed.ParsePrivateKeyPEM(data, kp.passphrase()); nil != err {
+			return nil, fmt.Errorf("%s: %s", legacyPath, err)
+		}
+		if err := writeFileAtomic(keyPath, data, 0o600); nil != err {
+			return nil, err
+		}
+		log.Printf("Moved the ACME account key %s to %s\n", legacyPath, keyPath)
+		return reseed.LoadPrivateKey(keyPath, kp.passphrase())
+	}
+	if !create {
+		return nil, err
+	}
+	return newAcmeAccountKey(keyPath, kp)
+}
+
+// loadAcmeAccount reads the account record at base.acme.json, or returns nil
+// if it hasn't been saved.
+func loadAcmeAccount(base string) (*acmeAccount, error) {
+	data, err := os.ReadFile(base + ".acme.json")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	var account acmeAccount
+	if err := json.Unmarshal(data, &account); nil != err {
+		return nil, fmt.Errorf("%s.acme.json: %s", base, err)
+	}
+	return &account, nil
+}
+
+func saveAcmeAccount(base string, account *acmeAccount) error {
+	data, err := json.MarshalIndent(account, "", "  ")
+	if nil != err {
+		return err
+	}
+	return writeFileAtomic(base+".acme.json", append(data, '\n'), 0o600)
+}
+
+// registerAcmeAccount sets the registration of user, from the saved account
+// if there is one. Otherwise the account of the user's key is looked up, or
+// registered with the external account binding eab, and saved.
+func registerAcmeAccount(client *lego.Client, user *MyUser, base, caDirURL string, eab *registration.RegisterEABOptions) error {
+	account, err := loadAcmeAccount(base)
+	if nil != err {
+		return err
+	}
+	if account != nil && account.Registration != nil && account.CA == caDirURL {
+		user.Registration = account.Registration
+		return nil
+	}
+
+	account = &acmeAccount{Email: user.Email, CA: caDirURL}
+	if user.Registration, err = client.Registration.ResolveAccountByKey(); nil != err {
+		if eab != nil {
+			user.Registration, err = client.Registration.RegisterWithExternalAccountBinding(*eab)
+			account.EABKeyID = eab.Kid
+		} else {
+			user.Registration, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+		}
+		if nil != err {
+			return err
+		}
+		log.Printf("Registered the ACME account of %s at %s\n", user.Email, caDirURL)
+	}
+	account.Registration = user.Registration
+	return saveAcmeAccount(base, account)
+}
+
+// newAcmeAccountKey creates an account key and saves it at keyPath.
+func newAcmeAccountKey(keyPath string, kp *keyProtection) (crypto.Signer, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		return nil, err
+	}
+	ecder, err := x509.MarshalECPrivateKey(privateKey)
+	if nil != err {
+		return nil, err
+	}
+	keyBlock, err := kp.keyBlock(privateKey, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})
+	if nil != err {
+		return nil, err
+	}
+	if err := writeFileAtomic(keyPath, pem.EncodeToMemory(keyBlock), 0o600); nil != err {
+		return nil, err
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-acme/lego/v4/registration"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestAcmeAccountBase(t *testing.T) {
+	tests := []struct {
+		email, ca, want string
+	}{
+		{"you@mail.i2p", "https://acme-v02.api.letsencrypt.org/directory", "you_at_mail.i2p.acme-v02.api.letsencrypt.org"},
+		{"you@mail.i2p", "https://localhost:14000/dir", "you_at_mail.i2p.localhost_14000"},
+	}
+	for _, tt := range tests {
+		if got := acmeAccountBase(tt.email, tt.ca); got != tt.want {
+			t.Errorf("acmeAccountBase(%q, %q) = %q, want %q", tt.email, tt.ca, got, tt.want)
+		}
+	}
+}
+
+func TestLoadAcmeAccountKey(t *testing.T) {
+	t.Chdir(t.TempDir())
+	kp := &keyProtection{}
+	base := acmeAccountBase("you@mail.i2p", "https://localhost:14000/dir")
+
+	if _, err := loadAcmeAccountKey(base, "host.exampleyou@mail.i2p.acme.key", false, kp); !os.IsNotExist(err) {
+		t.Fatalf("Expected no key without create, got %v", err)
+	}
+
+	// a key saved for one host is moved to the account
+	legacy, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile("host.exampleyou@mail.i2p.acme.key", pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600)
+	key, err := loadAcmeAccountKey(base, "host.exampleyou@mail.i2p.acme.key", false, kp)
+	if err != nil {
+		t.Fatalf("loadAcmeAccountKey failed: %v", err)
+	}
+	if !legacy.Equal(key) {
+		t.Error("Expected the legacy key")
+	}
+
+	// and shared with every other host
+	key, err = loadAcmeAccountKey(base, "other.exampleyou@mail.i2p.acme.key", true, kp)
+	if err != nil || !legacy.Equal(key) {
+		t.Errorf("Expected the account key to be shared, got %v", err)
+	}
+
+	// another CA gets its own account
+	other := acmeAccountBase("you@mail.i2p", "https://acme.example/directory")
+	key, err = loadAcmeAccountKey(other, "other.exampleyou@mail.i2p.acme.key", true, kp)
+	if err != nil || legacy.Equal(key) {
+		t.Errorf("Expected a new account key, got %v", err)
+	}
+
+	files, err := acmeAccountFiles("you@mail.i2p")
+	if err != nil || len(files) != 2 {
+		t.Errorf("Expected both account keys, got %v, %v", files, err)
+	}
+}
+
+func TestAcmeAccountRecord(t *testing.T) {
+	t.Chdir(t.TempDir())
+	base := acmeAccountBase("you@mail.i2p", "https://localhost:14000/dir")
+	if account, err := loadAcmeAccount(base); err != nil || account != nil {
+		t.Fatalf("Expected no account, got %v, %v", account, err)
+	}
+
+	saved := &acmeAccount{
+		Email:        "you@mail.i2p",
+		CA:           "https://localhost:14000/dir",
+		Registration: &registration.Resource{URI: "https://localhost:14000/my-account/1"},
+		EABKeyID:     "kid-1",
+	}
+	if err := saveAcmeAccount(base, saved); err != nil {
+		t.Fatalf("saveAcmeAccount failed: %v", err)
+	}
+	account, err := loadAcmeAccount(base)
+	if err != nil {
+		t.Fatalf("loadAcmeAccount failed: %v", err)
+	}
+	if account.Registration.URI != saved.Registration.URI || account.EABKeyID != "kid-1" {
+		t.Errorf("Unexpected account %+v", account)
+	}
+
+	files, err := identityFiles("you@mail.i2p", nil, "onion.key", "reseed.i2pkeys", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range files {
+		found = found || (f.Kind == reseed.BackupACMEAccount && filepath.Base(f.Path) == base+".acme.json")
+	}
+	if !found {
+		t.Errorf("Expected the account in the identity files, got %+v", files)
+	}
+}
@@ -0,0 +1,347 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// backupPassphraseFlags say where the passphrase of a backup comes from. It
+// is kept apart from the passphrase of encrypted keys in the backup.
+func backupPassphraseFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "backupPassphraseEnv",
+			Value: "RESEED_BACKUP_PASSPHRASE",
+			Usage: "Environment variable holding the passphrase of the backup",
+		},
+		&cli.IntFlag{
+			Name:  "backupPassphraseFd",
+			Value: -1,
+			Usage: "Read the passphrase of the backup from this file descriptor instead",
+		},
+	}
+}
+
+// NewBackupCommand creates a new CLI command for backing up the keys and
+// certificates of a reseed operator.
+func NewBackupCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "backup",
+		Usage:     "Save the signing, TLS, onion, I2P and ACME keys and certificates to one encrypted backup",
+		ArgsUsage: "[EXTRA FILE...]",
+		Action:    backupAction,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tlsHost",
+				Usage: "Hostname of a TLS certificate to back up. Can be repeated. (default: this host's name)",
+			},
+			&cli.StringFlag{
+				Name:  "onionKey",
+				Value: "onion.key",
+				Usage: "Path to the ed25519 private key of the onion service",
+			},
+			&cli.StringFlag{
+				Name:  "i2pKeys",
+				Value: "reseed.i2pkeys",
+				Usage: "Path to the keys of the I2P service",
+			},
+			&cli.StringFlag{
+				Name:  "keystore",
+				Value: ".",
+				Usage: "Directory holding the key rotation record",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Usage: "Path to write the backup to (default: reseed-backup-YYYYMMDD.pem)",
+			},
+			kdfFlag(),
+		}, backupPassphraseFlags()...),
+	}
+}
+
+// NewRestoreCommand creates a new CLI command for restoring a backup made
+// with the backup command.
+func NewRestoreCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "restore",
+		Usage:     "Check the keys and certificates in a backup and write them out",
+		ArgsUsage: "BACKUP",
+		Action:    restoreAction,
+		Flags: append(append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "dir",
+				Value: ".",
+				Usage: "Directory to restore the files to",
+			},
+			&cli.BoolFlag{
+				Name:  "check",
+				Usage: "Only check the backup and list its files, without writing them",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace files which already exist",
+			},
+		}, backupPassphraseFlags()...), passphraseFlags()...),
+	}
+}
+
+func backupAction(c *cli.Context) error {
+	signerID := c.String("signer")
+	hosts := c.StringSlice("tlsHost")
+	if len(hosts) == 0 {
+		hosts = []string{getHostName()}
+	}
+
+	m, err := collectBackupFiles(signerID, hosts, c.String("onionKey"), c.String("i2pKeys"), c.String("keystore"), c.Args().Slice())
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if len(m.Files) == 0 {
+		fmt.Println("No keys or certificates found to back up")
+		return fmt.Errorf("No keys or certificates found to back up")
+	}
+
+	out := c.String("out")
+	if out == "" {
+		out = "reseed-backup-" + m.Created.Format("20060102") + ".pem"
+	}
+	if _, err := os.Stat(out); nil == err {
+		fmt.Printf("%s already exists\n", out)
+		return fmt.Errorf("%s already exists", out)
+	}
+
+	pass, err := readPassphrase(c.String("backupPassphraseEnv"), c.Int("backupPassphraseFd"), true)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	data, err := reseed.SealBackup(m, pass, c.String("kdf"))
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if err := writeFileAtomic(out, data, 0o600); nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	for _, f := range m.Files {
+		fmt.Printf("\t%-12s %s\n", f.Kind, f.Name)
+	}
+	fmt.Println("Backup saved to:", out)
+	return nil
+}
+
+// collectBackupFiles gathers the operator's keys and certificates from
+// where the reseed command keeps them. Files which don't exist are skipped
+// with a note, except for the extra files, which must exist.
+func collectBackupFiles(signerID string, hosts []string, onionKey, i2pKeys, keystore string, extra []string) (*reseed.BackupManifest, error) {
+	files, err := identityFiles(signerID, hosts, onionKey, i2pKeys, keystore)
+	if nil != err {
+		return nil, err
+	}
+	for _, path := range extra {
+		files = append(files, identityFile{Path: path, Kind: reseed.BackupOther, Required: true})
+	}
+
+	m := &reseed.BackupManifest{Created: time.Now().UTC(), SignerID: signerID}
+	names := make(map[string]string)
+	for _, file := range files {
+		f, err := reseed.NewBackupFile(file.Path, file.Kind)
+		if errors.Is(err, os.ErrNotExist) && !file.Required {
+			fmt.Printf("Not found, skipping: %s\n", file.Path)
+			continue
+		}
+		if nil != err {
+			return nil, err
+		}
+		if file.Key != "" {
+			f.Key = names[file.Key]
+		}
+		if m.File(f.Name) != nil {
+			return nil, fmt.Errorf("two files named %s can't be in the same backup", f.Name)
+		}
+		names[file.Path] = f.Name
+		m.Files = append(m.Files, f)
+	}
+	return m, nil
+}
+
+func restoreAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		fmt.Println("Give the backup to restore")
+		return fmt.Errorf("Give the backup to restore")
+	}
+	data, err := os.ReadFile(c.Args().First())
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	pass, err := readPassphrase(c.String("backupPassphraseEnv"), c.Int("backupPassphraseFd"), false)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	m, err := reseed.OpenBackup(data, pass)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	kp := keyProtectionFromFlags(c, false)
+	if err := checkBackup(m, kp.passphrase()); nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	fmt.Printf("Backup of %s made %s\n", m.SignerID, m.Created.Format(time.RFC3339))
+	for _, f := range m.Files {
+		fmt.Printf("\t%-12s %s\n", f.Kind, f.Name)
+	}
+	if c.Bool("check") {
+		return nil
+	}
+
+	if err := restoreBackup(m, c.String("dir"), c.Bool("force")); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("Restored to:", c.String("dir"))
+	return nil
+}
+
+// checkBackup checks that every key in the backup can be read and matches
+// its certificate. passphrase unlocks encrypted keys.
+func checkBackup(m *reseed.BackupManifest, passphrase reseed.PassphraseFunc) error {
+	for _, f := range m.Files {
+		switch f.Kind {
+		case reseed.BackupSignerKey, reseed.BackupTLSKey, reseed.BackupACMEKey:
+			if _, err := reseed.ParsePrivateKeyPEM(f.Data, passphrase); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		case reseed.BackupSignerCert, reseed.BackupTLSCert:
+			block, _ := pem.Decode(f.Data)
+			if block == nil {
+				return fmt.Errorf("%s: no PEM data found", f.Name)
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+			if f.Key == "" {
+				continue
+			}
+			keyFile := m.File(f.Key)
+			if keyFile == nil {
+				return fmt.Errorf("%s: its key %s is missing from the backup", f.Name, f.Key)
+			}
+			key, err := reseed.ParsePrivateKeyPEM(keyFile.Data, passphrase)
+			if nil != err {
+				return fmt.Errorf("%s: %s", f.Key, err)
+			}
+			if !reseed.KeyMatchesCertificate(key, cert) {
+				return fmt.Errorf("%s is not the key of %s", f.Key, f.Name)
+			}
+		case reseed.BackupACMEAccount:
+			var account acmeAccount
+			if err := json.Unmarshal(f.Data, &account); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		case reseed.BackupOnionKey:
+			if _, err := onionHost(f.Data); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		case reseed.BackupI2PKeys:
+			if _, err := i2pkeys.LoadKeysIncompat(bytes.NewReader(f.Data)); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		case reseed.BackupRotation:
+			var r reseed.KeyRotation
+			if err := json.Unmarshal(f.Data, &r); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+			for _, k := range []reseed.RotationKey{r.Current, r.Next} {
+				if m.File(filepath.Base(k.Key)) == nil || m.File(filepath.Base(k.Certificate)) == nil {
+					return fmt.Errorf("%s: the keys of the rotation are missing from the backup", f.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restoreBackup writes the files of m to dir. Private keys are only readable
+// by their owner. Unless force is set, nothing is written if any of the files
+// already exists.
+func restoreBackup(m *reseed.BackupManifest, dir string, force bool) error {
+	dir, err := filepath.Abs(dir)
+	if nil != err {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); nil != err {
+		return err
+	}
+	if !force {
+		for _, f := range m.Files {
+			if _, err := os.Stat(filepath.Join(dir, f.Name)); nil == err {
+				return fmt.Errorf("%s already exists, use --force to replace it", filepath.Join(dir, f.Name))
+			}
+		}
+	}
+
+	for _, f := range m.Files {
+		data := f.Data
+		perm := f.Mode & 0o644
+		switch f.Kind {
+		case reseed.BackupSignerKey, reseed.BackupTLSKey, reseed.BackupACMEKey, reseed.BackupACMEAccount, reseed.BackupOnionKey, reseed.BackupI2PKeys, reseed.BackupCRL:
+			perm = 0o600
+		case reseed.BackupRotation:
+			// the rotation records absolute paths, which now are in dir
+			if data, err = relocateRotation(data, dir); nil != err {
+				return fmt.Errorf("%s: %s", f.Name, err)
+			}
+		}
+		if perm == 0 {
+			perm = 0o600
+		}
+		if err := writeFileAtomic(filepath.Join(dir, f.Name), data, perm); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// relocateRotation points the key and certificate paths of a key rotation
+// record at dir.
+func relocateRotation(data []byte, dir string) ([]byte, error) {
+	var r reseed.KeyRotation
+	if err := json.Unmarshal(data, &r); nil != err {
+		return nil, err
+	}
+	for _, k := range []*reseed.RotationKey{&r.Current, &r.Next} {
+		k.Key = filepath.Join(dir, filepath.Base(k.Key))
+		k.Certificate = filepath.Join(dir, filepath.Base(k.Certificate))
+	}
+	out, err := json.MarshalIndent(&r, "", "  ")
+	if nil != err {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+// writeTLSPair writes a TLS key and a certificate for host, which is made
+// for certKey instead if it is given.
+func writeTLSPair(t *testing.T, dir, host string, certKey *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if certKey == nil {
+		certKey = key
+	}
+	der, err := reseed.NewTLSCertificateWithOptions(certKey, su3.CertificateOptions{}, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, host+".pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, host+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	writeTLSPair(t, dir, "example.com", nil)
+	if err := os.WriteFile("notes.txt", []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := collectBackupFiles("", []string{"example.com"}, "onion.key", "reseed.i2pkeys", ".", []string{"notes.txt"})
+	if err != nil {
+		t.Fatalf("collectBackupFiles failed: %v", err)
+	}
+	if len(m.Files) != 3 {
+		t.Fatalf("Expected 3 files, got %+v", m.Files)
+	}
+	if cert := m.File("example.com.crt"); cert == nil || cert.Key != "example.com.pem" {
+		t.Errorf("Expected the certificate to name its key, got %+v", cert)
+	}
+	if err := checkBackup(m, nil); err != nil {
+		t.Fatalf("checkBackup failed: %v", err)
+	}
+
+	out := filepath.Join(dir, "restored")
+	if err := restoreBackup(m, out, false); err != nil {
+		t.Fatalf("restoreBackup failed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(out, "example.com.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected the key to be written 0600, got %v", info.Mode().Perm())
+	}
+	if err := restoreBackup(m, out, false); err == nil {
+		t.Error("Expected an error restoring over existing files")
+	}
+	if err := restoreBackup(m, out, true); err != nil {
+		t.Errorf("restoreBackup with force failed: %v", err)
+	}
+}
+
+func TestCheckBackupMismatchedKey(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTLSPair(t, dir, "example.com", other)
+
+	m, err := collectBackupFiles("", []string{"example.com"}, "onion.key", "reseed.i2pkeys", ".", nil)
+	if err != nil {
+		t.Fatalf("collectBackupFiles failed: %v", err)
+	}
+	if err := checkBackup(m, nil); err == nil {
+		t.Error("Expected an error for a key which doesn't match its certificate")
+	}
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// NewCertsCommand creates a new CLI command for listing the keys and
+// certificates the reseed server would use, and checking them.
+func NewCertsCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "certs",
+		Usage:  "List and check the keys, certificates and CRLs of a reseed server. Exits non-zero on problems.",
+		Action: certsAction,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "tlsHost",
+				Usage: "Hostname of a TLS certificate to check. Can be repeated. (default: this host's name)",
+			},
+			&cli.StringFlag{
+				Name:  "onionKey",
+				Value: "onion.key",
+				Usage: "Path to the ed25519 private key of the onion service",
+			},
+			&cli.StringFlag{
+				Name:  "i2pKeys",
+				Value: "reseed.i2pkeys",
+				Usage: "Path to the keys of the I2P service",
+			},
+			&cli.StringFlag{
+				Name:  "keystore",
+				Value: ".",
+				Usage: "Directory holding the key rotation record",
+			},
+			&cli.DurationFlag{
+				Name:  "warn",
+				Value: 30 * 24 * time.Hour,
+				Usage: "Report certificates which expire within this long",
+			},
+		}, passphraseFlags()...),
+	}
+}
+
+func certsAction(c *cli.Context) error {
+	hosts := c.StringSlice("tlsHost")
+	if len(hosts) == 0 {
+		hosts = []string{getHostName()}
+	}
+	files, err := identityFiles(c.String("signer"), hosts, c.String("onionKey"), c.String("i2pKeys"), c.String("keystore"))
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	r := &certReport{
+		signerID:   c.String("signer"),
+		keystore:   c.String("keystore"),
+		warn:       c.Duration("warn"),
+		now:        time.Now(),
+		passphrase: keyProtectionFromFlags(c, false).passphrase(),
+	}
+	for _, f := range files {
+		r.check(f)
+	}
+	fmt.Print(r.out.String())
+
+	if r.problems > 0 {
+		fmt.Printf("%d problem(s) found\n", r.problems)
+		return fmt.Errorf("%d problem(s) found", r.problems)
+	}
+	return nil
+}
+
+// certReport describes the files of identityFiles and counts the problems
+// found with them.
+type certReport struct {
+	signerID   string
+	keystore   string
+	warn       time.Duration
+	now        time.Time
+	passphrase reseed.PassphraseFunc
+
+	out      bytes.Buffer
+	problems int
+}
+
+func (r *certReport) field(label, format string, args ...interface{}) {
+	fmt.Fprintf(&r.out, "\t%-14s%s\n", label+":", fmt.Sprintf(format, args...))
+}
+
+func (r *certReport) problem(format string, args ...interface{}) {
+	r.problems++
+	fmt.Fprintf(&r.out, "\tPROBLEM:      %s\n", fmt.Sprintf(format, args...))
+}
+
+var certReportTitles = map[string]string{
+	reseed.BackupSignerCert:  "su3 signing certificate",
+	reseed.BackupTLSCert:     "TLS certificate",
+	reseed.BackupCRL:         "CRL",
+	reseed.BackupRotation:    "Key rotation",
+	reseed.BackupACMEKey:     "ACME account key",
+	reseed.BackupACMEAccount: "ACME account",
+	reseed.BackupOnionKey:    "Onion service key",
+	reseed.BackupI2PKeys:     "I2P service keys",
+}
+
+func (r *certReport) check(f identityFile) {
+	title, ok := certReportTitles[f.Kind]
+	if !ok {
+		// private keys are checked with their certificate
+		return
+	}
+	data, err := os.ReadFile(f.Path)
+	if nil != err {
+		switch {
+		case f.Required, f.Kind == reseed.BackupSignerCert:
+			fmt.Fprintf(&r.out, "%s %s\n", title, f.Path)
+			r.problem("%s", err)
+		case f.Kind == reseed.BackupTLSCert:
+			fmt.Fprintf(&r.out, "%s %s: not found\n", title, f.Path)
+		}
+		return
+	}
+
+	fmt.Fprintf(&r.out, "%s %s\n", title, f.Path)
+	switch f.Kind {
+	case reseed.BackupSignerCert, reseed.BackupTLSCert:
+		r.checkCertificate(f, data)
+	case reseed.BackupCRL:
+		r.checkCRL(data)
+	case reseed.BackupRotation:
+		r.checkRotation()
+	case reseed.BackupACMEKey:
+		key, err := reseed.ParsePrivateKeyPEM(data, r.passphrase)
+		if nil != err {
+			r.problem("%s", err)
+			return
+		}
+		r.field("Key", "%s", publicKeyDescription(key.Public()))
+	case reseed.BackupACMEAccount:
+		var account acmeAccount
+		if err := json.Unmarshal(data, &account); nil != err {
+			r.problem("%s", err)
+			return
+		}
+		r.field("Contact", "%s", account.Email)
+		r.field("CA", "%s", account.CA)
+		if account.Registration != nil {
+			r.field("Account", "%s (%s)", account.Registration.URI, account.Registration.Body.Status)
+		}
+		if account.EABKeyID != "" {
+			r.field("EAB key ID", "%s", account.EABKeyID)
+		}
+	case reseed.BackupOnionKey:
+		host, err := onionHost(data)
+		if nil != err {
+			r.problem("%s", err)
+			return
+		}
+		r.field("Address", "%s", host)
+	case reseed.BackupI2PKeys:
+		keys, err := i2pkeys.LoadKeysIncompat(bytes.NewReader(data))
+		if nil != err {
+			r.problem("%s", err)
+			return
+		}
+		r.field("Address", "%s", keys.Addr().Base32())
+	}
+}
+
+func (r *certReport) checkCertificate(f identityFile, data []byte) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		r.problem("no PEM data found")
+		return
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if nil != err {
+		r.problem("%s", err)
+		return
+	}
+
+	r.field("Subject", "%s", cert.Subject)
+	if bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		r.field("Issuer", "self-signed")
+	} else {
+		r.field("Issuer", "%s", cert.Issuer)
+	}
+	var names []string
+	names = append(names, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	if len(names) > 0 {
+		r.field("Names", "%s", strings.Join(names, ", "))
+	}
+	r.field("Key", "%s", publicKeyDescription(cert.PublicKey))
+	r.field("SHA-256", "%s", reseed.CertificateFingerprint(cert))
+	left := cert.NotAfter.Sub(r.now)
+	r.field("Valid until", "%s (%s)", cert.NotAfter.UTC().Format(time.RFC3339), daysLeft(left))
+	switch {
+	case left <= 0:
+		r.problem("the certificate has expired")
+	case left < r.warn:
+		r.problem("the certificate expires %s", daysLeft(left))
+	case r.now.Before(cert.NotBefore):
+		r.problem("the certificate is not valid until %s", cert.NotBefore.UTC().Format(time.RFC3339))
+	}
+
+	if f.Kind == reseed.BackupSignerCert {
+		if _, ok := cert.PublicKey.(*rsa.PublicKey); !ok {
+			r.problem("su3 files are signed with RSA keys, not %s", publicKeyDescription(cert.PublicKey))
+		}
+		if r.signerID != "" && cert.Subject.CommonName != r.signerID {
+			r.problem("the certificate is for %q, not %q", cert.Subject.CommonName, r.signerID)
+		}
+	}
+
+	if f.Key == "" {
+		return
+	}
+	key, err := reseed.LoadPrivateKey(f.Key, r.passphrase)
+	switch {
+	case os.IsNotExist(err):
+		r.problem("the private key %s was not found", f.Key)
+	case nil != err:
+		r.problem("%s", err)
+	case !reseed.KeyMatchesCertificate(key, cert):
+		r.problem("the private key %s does not match the certificate", f.Key)
+	default:
+		r.field("Private key", "%s matches", f.Key)
+	}
+}
+
+func (r *certReport) checkCRL(data []byte) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		r.problem("no PEM data found")
+		return
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if nil != err {
+		r.problem("%s", err)
+		return
+	}
+	r.field("Issuer", "%s", crl.Issuer)
+	r.field("Updated", "%s", crl.ThisUpdate.UTC().Format(time.RFC3339))
+	r.field("Revoked", "%d certificate(s)", len(crl.RevokedCertificateEntries))
+}
+
+func (r *certReport) checkRotation() {
+	rotation, err := loadRotation(r.keystore, r.signerID)
+	if nil != err || rotation == nil {
+		r.problem("%v", err)
+		return
+	}
+	if r.now.Before(rotation.Cutover) {
+		r.field("Cutover", "%s (%s)", rotation.Cutover.UTC().Format(time.RFC3339), daysLeft(rotation.Cutover.Sub(r.now)))
+	} else {
+		r.field("Cutover", "%s (done)", rotation.Cutover.UTC().Format(time.RFC3339))
+	}
+	for _, k := range []struct {
+		label string
+		key   reseed.RotationKey
+	}{{"Current", rotation.Current}, {"Next", rotation.Next}} {
+		r.field(k.label, "%s", k.key.Certificate)
+		cert, err := loadCertificate(k.key.Certificate)
+		if nil != err {
+			r.problem("%s", err)
+			continue
+		}
+		if reseed.CertificateFingerprint(cert) != k.key.Fingerprint {
+			r.problem("%s is not the certificate recorded for the rotation", k.key.Certificate)
+		}
+	}
+}
+
+// daysLeft describes a time left in days.
+func daysLeft(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case d <= 0:
+		return fmt.Sprintf("expired %d days ago", -days)
+	case days == 0:
+		return "in less than a day"
+	default:
+		return fmt.Sprintf("in %d days", days)
+	}
+}
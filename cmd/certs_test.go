@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestCertReport(t *testing.T) {
+	dir := t.TempDir()
+	writeTLSPair(t, dir, "good.example", nil)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTLSPair(t, dir, "bad.example", other)
+
+	check := func(host string, warn time.Duration) *certReport {
+		r := &certReport{warn: warn, now: time.Now()}
+		r.check(identityFile{
+			Path: filepath.Join(dir, host+".crt"),
+			Kind: reseed.BackupTLSCert,
+			Key:  filepath.Join(dir, host+".pem"),
+		})
+		return r
+	}
+
+	if r := check("good.example", 24*time.Hour); r.problems != 0 {
+		t.Errorf("Expected no problems, got:\n%s", r.out.String())
+	}
+	if r := check("bad.example", 24*time.Hour); r.problems != 1 {
+		t.Errorf("Expected the mismatched key to be a problem, got:\n%s", r.out.String())
+	}
+	if r := check("good.example", 10*365*24*time.Hour); r.problems != 1 {
+		t.Errorf("Expected the expiry warning to be a problem, got:\n%s", r.out.String())
+	}
+	if r := check("missing.example", 24*time.Hour); r.problems != 0 {
+		t.Errorf("Expected a missing TLS certificate not to be a problem, got:\n%s", r.out.String())
+	}
+
+	r := &certReport{now: time.Now()}
+	r.check(identityFile{Path: filepath.Join(dir, "missing.crt"), Kind: reseed.BackupSignerCert})
+	if r.problems != 1 {
+		t.Errorf("Expected a missing signing certificate to be a problem, got:\n%s", r.out.String())
+	}
+}
+
+func TestDaysLeft(t *testing.T) {
+	tests := map[time.Duration]string{
+		-48 * time.Hour: "expired 2 days ago",
+		time.Hour:       "in less than a day",
+		72 * time.Hour:  "in 3 days",
+	}
+	for d, want := range tests {
+		if got := daysLeft(d); got != want {
+			t.Errorf("daysLeft(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
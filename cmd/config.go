@@ -0,0 +1,366 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+	"github.com/urfave/cli/v3"
+)
+
+// configSections maps the keys of each section of a config file to the
+// flags they set. Any flag can also be set at the top of the file, by its
+// own name.
+var configSections = map[string]map[string]string{
+	"listeners": {
+		"ip":          "ip",
+		"port":        "port",
+		"prefix":      "prefix",
+		"trustProxy":  "trustProxy",
+		"onion":       "onion",
+		"singleOnion": "singleOnion",
+		"onionKey":    "onionKey",
+		"i2p":         "i2p",
+		"samaddr":     "samaddr",
+		"unix":        "unix",
+	},
+	"tls": {
+		"host":           "tlsHost",
+		"cert":           "tlsCert",
+		"key":            "tlsKey",
+		"altHosts":       "tlsAltHost",
+		"watch":          "tlsWatch",
+		"profile":        "tlsProfile",
+		"minVersion":     "tlsMinVersion",
+		"cipherSuites":   "tlsCipherSuites",
+		"curves":         "tlsCurves",
+		"ticketRotation": "tlsTicketRotation",
+	},
+	"acme": {
+		"enabled":      "acme",
+		"server":       "acmeserver",
+		"challenge":    "acmeChallenge",
+		"httpPort":     "acmeHTTPPort",
+		"dnsProvider":  "acmeDNSProvider",
+		"dnsResolvers": "acmeDNSResolvers",
+		"keyType":      "acmeKeyType",
+		"eabKeyID":     "acmeEABKeyID",
+		"eabHMACEnv":   "acmeEABHMACEnv",
+		"renewBefore":  "acmeRenewBefore",
+	},
+	"ratelimit": {
+		"requests":   "ratelimit",
+		"web":        "ratelimitweb",
+		"burst":      "ratelimitBurst",
+		"webBurst":   "ratelimitwebBurst",
+		"onion":      "ratelimitOnion",
+		"onionBurst": "ratelimitOnionBurst",
+		"byPrefix":   "ratelimitByPrefix",
+		"store":      "ratelimitStore",
+	},
+	"friends": {
+		"urls":          "friends",
+		"sharePeer":     "share-peer",
+		"sharePassword": "share-password",
+	},
+}
+
+// secretFlags are not printed by config check.
+var secretFlags = map[string]bool{
+	"share-password": true,
+}
+
+// NewConfigCommand creates a new CLI command for checking config files.
+func NewConfigCommand() *cli.Command {
+	flags := reseedFlags()
+	var fromFile []string
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Check config files of the reseed command",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "check",
+				Usage:     "Check a config file and print the settings the reseed command would use, with the same flags and environment",
+				ArgsUsage: "[file]",
+				Before: func(c *cli.Context) error {
+					if c.NArg() > 0 {
+						if err := c.Set("config", c.Args().First()); nil != err {
+							return err
+						}
+					}
+					var err error
+					fromFile, err = applyConfigFile(c, flags)
+					return err
+				},
+				Action: func(c *cli.Context) error {
+					return configCheckAction(c, flags, fromFile)
+				},
+				Flags: flags,
+			},
+		},
+	}
+}
+
+// configCheckAction validates the settings and prints them with where they
+// came from: the command line, the environment, the config file or the
+// default.
+func configCheckAction(c *cli.Context, flags []cli.Flag, fromFile []string) error {
+	if _, err := tlsProfile(c); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if _, err := time.ParseDuration(c.String("interval")); nil != err {
+		fmt.Println("interval:", err)
+		return err
+	}
+
+	applied := make(map[string]bool)
+	for _, name := range fromFile {
+		applied[name] = true
+	}
+	for _, f := range flags {
+		name := f.Names()[0]
+		source := "default"
+		switch {
+		case applied[name]:
+			source = "config"
+		case c.IsSet(name) && inEnvironment(f):
+			source = "environment"
+		case c.IsSet(name):
+			source = "flag"
+		}
+		value := configValue(c, f)
+		if secretFlags[name] && value != `""` {
+			value = `"(hidden)"`
+		}
+		fmt.Printf("%s = %s # %s\n", name, value, source)
+	}
+	return nil
+}
+
+// inEnvironment reports whether an environment variable of f is set.
+func inEnvironment(f cli.Flag) bool {
+	envFlag, ok := f.(interface{ GetEnvVars() []string })
+	if !ok {
+		return false
+	}
+	for _, env := range envFlag.GetEnvVars() {
+		if _, ok := os.LookupEnv(env); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// configValue formats the value of f the way a config file would set it.
+func configValue(c *cli.Context, f cli.Flag) string {
+	name := f.Names()[0]
+	switch f.(type) {
+	case *cli.BoolFlag:
+		return strconv.FormatBool(c.Bool(name))
+	case *cli.IntFlag:
+		return strconv.Itoa(c.Int(name))
+	case *cli.Int64Flag:
+		return strconv.FormatInt(c.Int64(name), 10)
+	case *cli.DurationFlag:
+		return strconv.Quote(c.Duration(name).String())
+	case *cli.StringSliceFlag:
+		var values []string
+		for _, v := range c.StringSlice(name) {
+			values = append(values, strconv.Quote(v))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	}
+	return strconv.Quote(c.String(name))
+}
+
+// loadConfigFile sets the flags which weren't given on the command line or
+// in the environment from the file named by --config, if there is one.
+func loadConfigFile(c *cli.Context, flags []cli.Flag) error {
+	_, err := applyConfigFile(c, flags)
+	return err
+}
+
+// applyConfigFile is loadConfigFile, returning the names of the flags the
+// file set.
+func applyConfigFile(c *cli.Context, flags []cli.Flag) ([]string, error) {
+	path := c.String("config")
+	if path == "" {
+		return nil, nil
+	}
+	settings, err := configFileSettings(path)
+	if nil != err {
+		fmt.Println(err)
+		return nil, err
+	}
+
+	byName := make(map[string]cli.Flag)
+	for _, f := range flags {
+		byName[f.Names()[0]] = f
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var applied []string
+	for _, name := range names {
+		f, ok := byName[name]
+		if !ok || name == "config" {
+			err := fmt.Errorf("%s: unknown setting %q", path, name)
+			fmt.Println(err)
+			return nil, err
+		}
+		if c.IsSet(name) {
+			continue
+		}
+		values := settings[name]
+		if _, ok := f.(*cli.StringSliceFlag); !ok && len(values) != 1 {
+			err := fmt.Errorf("%s: %s takes one value", path, name)
+			fmt.Println(err)
+			return nil, err
+		}
+		for _, value := range values {
+			if err := c.Set(name, value); nil != err {
+				err = fmt.Errorf("%s: %s: %s", path, name, err)
+				fmt.Println(err)
+				return nil, err
+			}
+		}
+		applied = append(applied, name)
+	}
+	return applied, nil
+}
+
+// configFileSettings reads the TOML config file at path, returning the
+// values of each flag it sets.
+func configFileSettings(path string) (map[string][]string, error) {
+	settings := make(map[string][]string)
+	if path == "" {
+		return settings, nil
+	}
+	var file map[string]interface{}
+	if _, err := toml.DecodeFile(path, &file); nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	for key, value := range file {
+		section, isSection := value.(map[string]interface{})
+		if !isSection {
+			if _, ok := settings[key]; ok {
+				return nil, fmt.Errorf("%s: %s is set twice", path, key)
+			}
+			values, err := configValues(value)
+			if nil != err {
+				return nil, fmt.Errorf("%s: %s: %s", path, key, err)
+			}
+			settings[key] = values
+			continue
+		}
+		names, ok := configSections[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown section [%s]", path, key)
+		}
+		for sectionKey, value := range section {
+			name, ok := names[sectionKey]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q in [%s]", path, sectionKey, key)
+			}
+			if _, ok := settings[name]; ok {
+				return nil, fmt.Errorf("%s: %s is set twice", path, name)
+			}
+			values, err := configValues(value)
+			if nil != err {
+				return nil, fmt.Errorf("%s: %s.%s: %s", path, key, sectionKey, err)
+			}
+			settings[name] = values
+		}
+	}
+	return settings, nil
+}
+
+// configValues turns a TOML value into the flag values it stands for.
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}, nil
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("lists can only hold strings")
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+// withEnvVars lets each flag without environment variables be set with
+// RESEED_ and its name in upper snake case, such as RESEED_TLS_HOST.
+func withEnvVars(flags []cli.Flag) []cli.Flag {
+	for _, f := range flags {
+		env := []string{envVarName(f.Names()[0])}
+		switch f := f.(type) {
+		case *cli.StringFlag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		case *cli.BoolFlag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		case *cli.IntFlag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		case *cli.Int64Flag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		case *cli.DurationFlag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		case *cli.StringSliceFlag:
+			if len(f.EnvVars) == 0 {
+				f.EnvVars = env
+			}
+		}
+	}
+	return flags
+}
+
+// envVarName returns the RESEED_ environment variable of the flag name.
+func envVarName(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	b.WriteString("RESEED_")
+	for i, r := range runes {
+		if r == '-' {
+			b.WriteRune('_')
+			continue
+		}
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
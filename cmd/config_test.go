@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "reseed.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFileSettings(t *testing.T) {
+	path := writeConfig(t, `
+signer = "you@mail.i2p"
+numRi = 60
+
+[listeners]
+port = "443"
+onion = true
+
+[tls]
+altHosts = ["a.example", "b.example"]
+watch = "5m"
+`)
+	settings, err := configFileSettings(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"signer":     {"you@mail.i2p"},
+		"numRi":      {"60"},
+		"port":       {"443"},
+		"onion":      {"true"},
+		"tlsAltHost": {"a.example", "b.example"},
+		"tlsWatch":   {"5m"},
+	}
+	if !reflect.DeepEqual(settings, want) {
+		t.Errorf("settings = %v, want %v", settings, want)
+	}
+
+	for content, problem := range map[string]string{
+		"[nowhere]\nport = \"1\"\n":              "unknown section",
+		"[tls]\ncolour = \"blue\"\n":             "unknown setting",
+		"tlsHost = \"a\"\n[tls]\nhost = \"b\"\n": "set twice",
+		"port = 1.5\n":                           "unsupported value",
+		"port = \n":                              "",
+	} {
+		_, err := configFileSettings(writeConfig(t, content))
+		if err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("%q: error = %v, want %q", content, err, problem)
+		}
+	}
+}
+
+// TestLoadConfigFile checks that flags and environment variables override
+// the config file.
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfig(t, `
+ip = "127.0.0.1"
+stats = "1h"
+
+[listeners]
+port = "443"
+
+[tls]
+altHosts = ["a.example"]
+
+[ratelimit]
+requests = 10
+`)
+	t.Setenv("RESEED_RATELIMIT", "3")
+
+	flags := withEnvVars([]cli.Flag{
+		&cli.StringFlag{Name: "config"},
+		&cli.StringFlag{Name: "ip", Value: "0.0.0.0"},
+		&cli.StringFlag{Name: "port", Value: "8443"},
+		&cli.DurationFlag{Name: "stats"},
+		&cli.StringSliceFlag{Name: "tlsAltHost"},
+		&cli.IntFlag{Name: "ratelimit", Value: 4},
+	})
+	var ip, port string
+	var stats time.Duration
+	var altHosts []string
+	var ratelimit int
+	app := &cli.App{
+		Name:   "test",
+		Flags:  flags,
+		Before: func(c *cli.Context) error { return loadConfigFile(c, flags) },
+		Action: func(c *cli.Context) error {
+			ip, port = c.String("ip"), c.String("port")
+			stats = c.Duration("stats")
+			altHosts = c.StringSlice("tlsAltHost")
+			ratelimit = c.Int("ratelimit")
+			return nil
+		},
+	}
+	if err := app.Run([]string{"test", "--config", path, "--port", "8080"}); err != nil {
+		t.Fatal(err)
+	}
+	if ip != "127.0.0.1" || port != "8080" || stats != time.Hour || ratelimit != 3 || !reflect.DeepEqual(altHosts, []string{"a.example"}) {
+		t.Errorf("got ip %s, port %s, stats %s, ratelimit %d, altHosts %v", ip, port, stats, ratelimit, altHosts)
+	}
+}
+
+func TestEnvVarName(t *testing.T) {
+	for name, want := range map[string]string{
+		"tlsHost":        "RESEED_TLS_HOST",
+		"numRi":          "RESEED_NUM_RI",
+		"acmeHTTPPort":   "RESEED_ACME_HTTP_PORT",
+		"acmeEABHMACEnv": "RESEED_ACME_EABHMAC_ENV",
+		"share-peer":     "RESEED_SHARE_PEER",
+		"ratelimitweb":   "RESEED_RATELIMITWEB",
+	} {
+		if got := envVarName(name); got != want {
+			t.Errorf("envVarName(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
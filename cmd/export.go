@@ -0,0 +1,339 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/checki2cp/getmeanetdb"
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// exportManifest describes an exported su3 set.
+type exportManifest struct {
+	SignerID      string    `json:"signer"`
+	Built         time.Time `json:"built"`
+	Deterministic bool      `json:"deterministic"`
+	Seed          *int64    `json:"seed,omitempty"`
+	// Unsigned is set while the files are waiting to be signed offline, and
+	// the digests and sizes are those of the unsigned bodies
+	Unsigned bool         `json:"unsigned"`
+	Files    []exportFile `json:"files"`
+}
+
+type exportFile struct {
+	Name        string   `json:"name"`
+	SHA256      string   `json:"sha256"`
+	Size        int      `json:"size"`
+	RouterInfos []string `json:"routerinfos"`
+}
+
+// NewExportCommand creates a new CLI command for building a signed su3 set
+// once and writing it to a directory for static hosting.
+func NewExportCommand() *cli.Command {
+	ndb, err := getmeanetdb.WhereIstheNetDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &cli.Command{
+		Name:   "export",
+		Usage:  "Build a signed su3 set once and write it to a directory",
+		Action: exportAction,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key",
+			},
+			&cli.StringFlag{
+				Name:  "netdb",
+				Value: ndb,
+				Usage: "Path to NetDB directory containing routerInfos",
+			},
+			&cli.DurationFlag{
+				Name:  "routerInfoAge",
+				Value: 72 * time.Hour,
+				Usage: "Maximum age of router infos to include in reseed files (ex. 72h, 8d)",
+			},
+			&cli.IntFlag{
+				Name:  "numRi",
+				Value: 77,
+				Usage: "Number of routerInfos to include in each su3 file",
+			},
+			&cli.IntFlag{
+				Name:  "numSu3",
+				Value: 50,
+				Usage: "Number of su3 files to build (0 = automatic based on size of netdb)",
+			},
+			&cli.IntFlag{
+				Name:  "netdbWorkers",
+				Value: runtime.NumCPU(),
+				Usage: "Number of workers used to read and parse routerInfos from the netDb",
+			},
+			&cli.IntFlag{
+				Name:  "su3Builders",
+				Value: 3,
+				Usage: "Number of su3 files to build in parallel",
+			},
+			&cli.BoolFlag{
+				Name:  "deterministic",
+				Usage: "Build reproducible su3 files from --seed and --buildTime so they can be audited",
+			},
+			&cli.Int64Flag{
+				Name:  "seed",
+				Value: 0,
+				Usage: "Seed used to select routerInfos for deterministic builds",
+			},
+			&cli.StringFlag{
+				Name:  "buildTime",
+				Value: "",
+				Usage: "Build time for deterministic builds, as unix seconds or RFC3339 (default: now)",
+			},
+			&cli.StringFlag{
+				Name:  "transparencyLog",
+				Value: "",
+				Usage: "Also record the exported su3 files in the transparency log at this path",
+			},
+			&cli.BoolFlag{
+				Name:  "unsigned",
+				Usage: "Write unsigned su3 bodies for offline signing with the sign command, instead of signing them here",
+			},
+			&cli.StringFlag{
+				Name:  "signerCert",
+				Usage: "Path to your su3 signing certificate, used with --unsigned to size the signatures (default: signerID.crt)",
+			},
+			&cli.StringFlag{
+				Name:  "out",
+				Value: "su3",
+				Usage: "Directory to write the su3 files and manifest.json to",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Report what would be built without signing or writing anything",
+			},
+			&cli.BoolFlag{
+				Name:  "yes",
+				Usage: "Automatically answer 'yes' to signing key generation",
+			},
+			&cli.StringFlag{
+				Name:  "keystore",
+				Value: ".",
+				Usage: "Directory holding the signing key rotation recorded by 'keygen rotate'",
+			},
+		}, passphraseFlags()...),
+	}
+}
+
+// exportFilename returns the name of the i'th exported su3 file.
+func exportFilename(i int) string {
+	return fmt.Sprintf("i2pseeds-%03d.su3", i)
+}
+
+// Unsigned su3 bodies and their detached signatures are written next to
+// where the signed su3 file will go, with these suffixes.
+const (
+	unsignedSuffix  = ".unsigned"
+	signatureSuffix = ".sig"
+)
+
+// readExportManifest reads the manifest.json in dir.
+func readExportManifest(dir string) (exportManifest, error) {
+	var manifest exportManifest
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if nil != err {
+		return manifest, err
+	}
+	err = json.Unmarshal(data, &manifest)
+	return manifest, err
+}
+
+// writeExportManifest writes manifest to the manifest.json in dir.
+func writeExportManifest(dir string, manifest exportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if nil != err {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0o644)
+}
+
+func exportAction(c *cli.Context) error {
+	netdbDir := c.String("netdb")
+	if netdbDir == "" {
+		fmt.Println("--netdb is required")
+		return fmt.Errorf("--netdb is required")
+	}
+
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+
+	buildTime, err := parseBuildTime(c.String("buildTime"))
+	if nil != err {
+		return err
+	}
+
+	netdb := reseed.NewLocalNetDb(netdbDir, c.Duration("routerInfoAge"))
+	netdb.Workers = c.Int("netdbWorkers")
+
+	reseeder := reseed.NewReseeder(netdb)
+	reseeder.SignerID = []byte(signerID)
+	reseeder.NumRi = c.Int("numRi")
+	reseeder.NumSu3 = c.Int("numSu3")
+	reseeder.NumBuilders = c.Int("su3Builders")
+	reseeder.Deterministic = c.Bool("deterministic")
+	reseeder.Seed = c.Int64("seed")
+	reseeder.BuildTime = buildTime
+	if reseeder.Deterministic && reseeder.BuildTime.IsZero() {
+		// pin the build time so the plan and the manifest agree
+		reseeder.BuildTime = time.Now().Truncate(time.Second)
+	}
+
+	manifest := exportManifest{
+		SignerID:      signerID,
+		Built:         reseeder.BuildTime.UTC(),
+		Deterministic: reseeder.Deterministic,
+	}
+	if reseeder.Deterministic {
+		manifest.Seed = &reseeder.Seed
+	}
+
+	outDir := c.String("out")
+
+	if c.Bool("dry-run") {
+		plan, available, err := reseeder.Plan()
+		if nil != err {
+			return err
+		}
+		fmt.Printf("Would build %d su3 files each containing %d out of %d routerInfos into %s\n", len(plan), reseeder.NumRi, available, outDir)
+		if !reseeder.Deterministic {
+			fmt.Println("Without --deterministic the routerInfos are chosen at random, so this is only an example selection.")
+		}
+		for i, names := range plan {
+			fmt.Printf("%s: %s\n", exportFilename(i), strings.Join(names, " "))
+		}
+		return nil
+	}
+
+	// after a 'keygen rotate', the key to sign with depends on the cutover
+	rotation, err := loadRotation(c.String("keystore"), signerID)
+	if nil != err {
+		return err
+	}
+
+	unsigned := c.Bool("unsigned")
+	var privKey *rsa.PrivateKey
+	if unsigned {
+		if c.String("transparencyLog") != "" {
+			fmt.Println("--transparencyLog needs the signing key and can't be used with --unsigned")
+			return fmt.Errorf("--transparencyLog needs the signing key and can't be used with --unsigned")
+		}
+		certPath := c.String("signerCert")
+		// if no certificate is specified, default to the signerID.crt in the current dir
+		if certPath == "" {
+			certPath = signerFile(signerID) + ".crt"
+			if rotation != nil {
+				certPath = rotation.Active(time.Now()).Certificate
+			}
+		}
+		cert, err := loadCertificate(certPath)
+		if nil != err {
+			return err
+		}
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s is not an RSA signing certificate", certPath)
+		}
+		reseeder.SignatureSize = pub.Size()
+		manifest.Unsigned = true
+	} else {
+		signerKey := c.String("key")
+		// if no key is specified, default to the signerID.pem in the current dir
+		if signerKey == "" {
+			signerKey = signerFile(signerID) + ".pem"
+			if rotation != nil {
+				signerKey = rotation.Active(time.Now()).Key
+			}
+		}
+		privKey, err = getOrNewSigningCert(&signerKey, signerID, c.Bool("yes"), keyProtectionFromFlags(c, false))
+		if nil != err {
+			fmt.Println(err)
+			return err
+		}
+		reseeder.SigningKey = privKey
+	}
+
+	bundles, err := reseeder.Build()
+	if nil != err {
+		return err
+	}
+	if reseeder.BuildTime.IsZero() && len(bundles) > 0 {
+		manifest.Built = bundles[0].Built.UTC()
+	}
+
+	if logPath := c.String("transparencyLog"); logPath != "" {
+		tl, err := reseed.OpenTransparencyLog(logPath, privKey, []byte(signerID))
+		if nil != err {
+			return err
+		}
+		var seed *int64
+		if reseeder.Deterministic {
+			seed = &reseeder.Seed
+		}
+		entries := make([]reseed.LogEntry, len(bundles))
+		for i, b := range bundles {
+			entries[i] = reseed.NewLogEntry(b.Data, []byte(signerID), b.Built, len(bundles), i, len(b.RouterInfos), seed)
+		}
+		if err := tl.Append(entries); nil != err {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(outDir, 0o755); nil != err {
+		return err
+	}
+	for i, b := range bundles {
+		name := exportFilename(i)
+		data, path := b.Data, filepath.Join(outDir, name)
+		if unsigned {
+			// leave off the reserved signature
+			data, path = data[:len(data)-reseeder.SignatureSize], path+unsignedSuffix
+		}
+		if err := os.WriteFile(path, data, 0o644); nil != err {
+			return err
+		}
+		digest := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, exportFile{
+			Name:        name,
+			SHA256:      hex.EncodeToString(digest[:]),
+			Size:        len(data),
+			RouterInfos: b.RouterInfos,
+		})
+	}
+
+	if err := writeExportManifest(outDir, manifest); nil != err {
+		return err
+	}
+
+	if unsigned {
+		fmt.Printf("Exported %d unsigned su3 bodies to %s\n", len(bundles), outDir)
+		fmt.Println("Copy them to the signing host, run 'reseed-tools sign' there, and bring the .sig files back for 'reseed-tools import'.")
+		return nil
+	}
+
+	fmt.Printf("Exported %d su3 files to %s\n", len(bundles), outDir)
+	return nil
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewExportCommand(t *testing.T) {
+	cmd := NewExportCommand()
+	if cmd == nil {
+		t.Fatal("NewExportCommand() returned nil")
+	}
+
+	if cmd.Name != "export" {
+		t.Errorf("Expected command name 'export', got %s", cmd.Name)
+	}
+
+	if cmd.Action == nil {
+		t.Error("Command action should not be nil")
+	}
+}
+
+func TestExportFilename(t *testing.T) {
+	if name := exportFilename(7); name != "i2pseeds-007.su3" {
+		t.Errorf("Expected i2pseeds-007.su3, got %s", name)
+	}
+}
+
+func TestParseBuildTime(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"", time.Time{}, false},
+		{"1700000000", time.Unix(1700000000, 0), false},
+		{"2023-11-14T22:13:20Z", time.Unix(1700000000, 0), false},
+		{"yesterday", time.Time{}, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseBuildTime(tc.value)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("parseBuildTime(%q) error = %v, wantErr %v", tc.value, err, tc.wantErr)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("parseBuildTime(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
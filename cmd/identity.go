@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cretz/bine/torutil"
+	"github.com/cretz/bine/torutil/ed25519"
+	"github.com/go-i2p/i2pkeys"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// identityFile is one of the files making up a reseed operator's identity.
+// Its kind is one of the reseed.Backup kinds.
+type identityFile struct {
+	Path string
+	Kind string
+	// Key is the path of the private key of a certificate
+	Key string
+	// Required files are an error to be missing
+	Required bool
+}
+
+// identityFiles lists the keys, certificates and CRLs of a reseed operator,
+// at the paths the reseed command uses for them: those of signerID and its
+// key rotation in keystore, the TLS certificates of hosts, the ACME accounts
+// of signerID, the onion and I2P service keys, and the TLS certificates of the
+// onion and I2P addresses. Paths are absolute and listed once, and private
+// keys come before their certificates. The files need not exist, apart from
+// those named by the key rotation.
+func identityFiles(signerID string, hosts []string, onionKey, i2pKeys, keystore string) ([]identityFile, error) {
+	var files []identityFile
+	seen := make(map[string]bool)
+	add := func(path, kind, keyPath string, required bool) error {
+		abs, err := filepath.Abs(path)
+		if nil != err {
+			return err
+		}
+		if seen[abs] {
+			return nil
+		}
+		seen[abs] = true
+		if keyPath != "" {
+			if keyPath, err = filepath.Abs(keyPath); nil != err {
+				return err
+			}
+		}
+		files = append(files, identityFile{Path: abs, Kind: kind, Key: keyPath, Required: required})
+		return nil
+	}
+	// addPair adds a private key, its certificate and its CRL.
+	addPair := func(keyPath, keyKind, certPath, certKind string, required bool) error {
+		if err := add(keyPath, keyKind, "", required); nil != err {
+			return err
+		}
+		if err := add(certPath, certKind, keyPath, required); nil != err {
+			return err
+		}
+		return add(trimExt(certPath)+".crl", reseed.BackupCRL, "", false)
+	}
+
+	if signerID != "" {
+		base := signerFile(signerID)
+		if err := addPair(base+".pem", reseed.BackupSignerKey, base+".crt", reseed.BackupSignerCert, false); nil != err {
+			return nil, err
+		}
+
+		rotation, err := loadRotation(keystore, signerID)
+		if nil != err {
+			return nil, err
+		}
+		if rotation != nil {
+			if err := add(reseed.NewKeyStore(keystore).RotationPath(signerID), reseed.BackupRotation, "", true); nil != err {
+				return nil, err
+			}
+			for _, k := range []reseed.RotationKey{rotation.Current, rotation.Next} {
+				if err := addPair(k.Key, reseed.BackupSignerKey, k.Certificate, reseed.BackupSignerCert, true); nil != err {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, host := range hosts {
+		if err := addPair(host+".pem", reseed.BackupTLSKey, host+".crt", reseed.BackupTLSCert, false); nil != err {
+			return nil, err
+		}
+		if signerID != "" {
+			if err := add(host+signerID+".acme.key", reseed.BackupACMEKey, "", false); nil != err {
+				return nil, err
+			}
+		}
+	}
+	if signerID != "" {
+		accounts, err := acmeAccountFiles(signerID)
+		if nil != err {
+			return nil, err
+		}
+		for _, path := range accounts {
+			kind := reseed.BackupACMEKey
+			if filepath.Ext(path) == ".json" {
+				kind = reseed.BackupACMEAccount
+			}
+			if err := add(path, kind, "", false); nil != err {
+				return nil, err
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(onionKey); nil == err {
+		if err := add(onionKey, reseed.BackupOnionKey, "", true); nil != err {
+			return nil, err
+		}
+		if host, err := onionHost(data); nil == err {
+			if err := addPair(host+".pem", reseed.BackupTLSKey, host+".crt", reseed.BackupTLSCert, false); nil != err {
+				return nil, err
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(i2pKeys); nil == err {
+		if err := add(i2pKeys, reseed.BackupI2PKeys, "", true); nil != err {
+			return nil, err
+		}
+		if keys, err := i2pkeys.LoadKeysIncompat(bytes.NewReader(data)); nil == err {
+			host := keys.Addr().Base32()
+			if err := addPair(host+".pem", reseed.BackupTLSKey, host+".crt", reseed.BackupTLSCert, false); nil != err {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// onionHost returns the .onion address of the onion service key data.
+func onionHost(data []byte) (string, error) {
+	if len(data) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("onion key is %d bytes, not %d", len(data), ed25519.PrivateKeySize)
+	}
+	return torutil.OnionServiceIDFromPrivateKey(ed25519.PrivateKey(data)) + ".onion", nil
+}
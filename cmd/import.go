@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+// NewImportCommand creates a new CLI command for attaching the signatures
+// made by the sign command to their unsigned su3 bodies.
+func NewImportCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "import",
+		Usage:  "Attach signatures made by the sign command and write the signed su3 files",
+		Action: importAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringFlag{
+				Name:  "signerCert",
+				Usage: "Path to your su3 signing certificate, used to check the signatures (default: signerID.crt)",
+			},
+			&cli.StringFlag{
+				Name:  "dir",
+				Value: "su3",
+				Usage: "Directory containing the unsigned su3 bodies and their signatures. The signed su3 files are written next to them.",
+			},
+		},
+	}
+}
+
+func importAction(c *cli.Context) error {
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+
+	certPath := c.String("signerCert")
+	// if no certificate is specified, default to the signerID.crt in the current dir
+	if certPath == "" {
+		certPath = signerFile(signerID) + ".crt"
+	}
+	cert, err := loadCertificate(certPath)
+	if nil != err {
+		return err
+	}
+
+	dir := c.String("dir")
+	bodies, err := unsignedBodies(dir)
+	if nil != err {
+		return err
+	}
+
+	// check every signature before writing anything, so a set is never
+	// half imported
+	signed := make(map[string][]byte, len(bodies))
+	for _, path := range bodies {
+		base := strings.TrimSuffix(path, unsignedSuffix)
+		body, err := os.ReadFile(path)
+		if nil != err {
+			return err
+		}
+		sig, err := os.ReadFile(base + signatureSuffix)
+		if nil != err {
+			return err
+		}
+		data, err := attachSu3Signature(body, sig, signerID, cert)
+		if nil != err {
+			return fmt.Errorf("unable to import %s: %s", filepath.Base(base), err)
+		}
+		signed[base] = data
+	}
+
+	for path, data := range signed {
+		if err := os.WriteFile(path, data, 0o644); nil != err {
+			return err
+		}
+	}
+
+	manifest, err := readExportManifest(dir)
+	if nil == err {
+		for i, file := range manifest.Files {
+			data, ok := signed[filepath.Join(dir, file.Name)]
+			if !ok {
+				continue
+			}
+			digest := sha256.Sum256(data)
+			manifest.Files[i].SHA256 = hex.EncodeToString(digest[:])
+			manifest.Files[i].Size = len(data)
+		}
+		manifest.Unsigned = false
+		if err := writeExportManifest(dir, manifest); nil != err {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	fmt.Printf("Imported %d signed su3 files to %s\n", len(signed), dir)
+	return nil
+}
+
+// attachSu3Signature appends a detached signature to an unsigned su3 body and
+// checks the result against the signer certificate.
+func attachSu3Signature(body, sig []byte, signerID string, cert *x509.Certificate) ([]byte, error) {
+	data := make([]byte, 0, len(body)+len(sig))
+	data = append(data, body...)
+	data = append(data, sig...)
+
+	su3File := su3.New()
+	if err := su3File.UnmarshalBinary(data); nil != err {
+		return nil, err
+	}
+	if string(su3File.SignerID) != signerID {
+		return nil, fmt.Errorf("signed for %q, not %q", su3File.SignerID, signerID)
+	}
+	if marshalled, _ := su3File.MarshalBinary(); len(marshalled) != len(data) {
+		return nil, fmt.Errorf("signature is %d bytes, the body has room for %d", len(sig), len(su3File.Signature))
+	}
+	if err := su3File.VerifySignature(cert); nil != err {
+		return nil, fmt.Errorf("signature is not valid: %s", err)
+	}
+
+	return data, nil
+}
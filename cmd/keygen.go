@@ -1,9 +1,22 @@
 package cmd
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/su3"
 )
 
 // NewKeygenCommand creates a new CLI command for generating keys.
@@ -12,7 +25,10 @@ func NewKeygenCommand() *cli.Command {
 		Name:   "keygen",
 		Usage:  "Generate keys for reseed su3 signing and TLS serving.",
 		Action: keygenAction,
-		Flags: []cli.Flag{
+		Subcommands: []*cli.Command{
+			newKeygenRotateCommand(),
+		},
+		Flags: append(append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "signer",
 				Usage: "Generate a private key and certificate for the given su3 signing ID (ex. something@mail.i2p)",
@@ -21,33 +37,276 @@ func NewKeygenCommand() *cli.Command {
 				Name:  "tlsHost",
 				Usage: "Generate a self-signed TLS certificate and private key for the given host",
 			},
+			&cli.StringFlag{
+				Name:  "tlsAlgorithm",
+				Value: "ecdsa-p384",
+				Usage: "TLS key algorithm: ecdsa-p256, ecdsa-p384, ecdsa-p521, ed25519, rsa2048, rsa3072 or rsa4096",
+			},
+			&cli.StringSliceFlag{
+				Name:  "san",
+				Usage: "Extra names for the TLS certificate, such as your .onion or .b32.i2p address. Can be repeated.",
+			},
+			&cli.StringFlag{
+				Name:  "submit",
+				Usage: "Also write the su3 signing certificate, named the way I2P expects, and its fingerprints to this directory to submit to the I2P developers",
+			},
+			&cli.BoolFlag{
+				Name:  "encrypt",
+				Usage: "Encrypt the new private keys with a passphrase",
+			},
+			kdfFlag(),
+		}, certificateFlags()...), passphraseFlags()...),
+	}
+}
+
+// certificateFlags are the flags for the algorithm, validity and subject of
+// newly generated certificates.
+func certificateFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "signerAlgorithm",
+			Value: "rsa4096",
+			Usage: "su3 signing key algorithm: rsa4096, rsa3072 or rsa2048. I2P routers expect rsa4096.",
+		},
+		&cli.StringFlag{
+			Name:  "validity",
+			Usage: "How long new certificates are valid for (ex. 10y, 90d, 2160h) (default: 10y for signing, 5y for TLS certificates)",
+		},
+		&cli.StringFlag{
+			Name:  "org",
+			Usage: "Organization of new certificates (default: I2P Anonymous Network)",
+		},
+		&cli.StringFlag{
+			Name:  "orgUnit",
+			Usage: "Organizational unit of new certificates (default: I2P)",
+		},
+		&cli.StringFlag{
+			Name:  "country",
+			Usage: "Two letter country code of new certificates (default: XX)",
 		},
+		&cli.StringFlag{
+			Name:  "province",
+			Usage: "State or province of new certificates",
+		},
+		&cli.StringFlag{
+			Name:  "locality",
+			Usage: "Locality of new certificates (default: XX)",
+		},
+		&cli.StringFlag{
+			Name:  "street",
+			Usage: "Street address of new certificates (default: XX)",
+		},
+	}
+}
+
+// keygenOptions are the algorithms and certificate fields used for new keys.
+// A nil *keygenOptions keeps the defaults.
+type keygenOptions struct {
+	SignerAlgorithm string
+	TLSAlgorithm    string
+	Certificate     su3.CertificateOptions
+	// AltNames are added to the TLS certificate besides its host
+	AltNames []string
+}
+
+// keygenOptionsFromFlags reads the certificateFlags, and the TLS flags if
+// the command has them.
+func keygenOptionsFromFlags(c *cli.Context) (*keygenOptions, error) {
+	validity, err := parseValidity(c.String("validity"))
+	if nil != err {
+		return nil, err
+	}
+	opts := &keygenOptions{
+		SignerAlgorithm: c.String("signerAlgorithm"),
+		TLSAlgorithm:    c.String("tlsAlgorithm"),
+		Certificate: su3.CertificateOptions{
+			Validity:           validity,
+			Organization:       c.String("org"),
+			OrganizationalUnit: c.String("orgUnit"),
+			Country:            c.String("country"),
+			Province:           c.String("province"),
+			Locality:           c.String("locality"),
+			StreetAddress:      c.String("street"),
+		},
+		AltNames: c.StringSlice("san"),
+	}
+	if _, ok := signerKeyBits[opts.SignerAlgorithm]; !ok && opts.SignerAlgorithm != "" {
+		return nil, fmt.Errorf("unknown su3 signing key algorithm %q", opts.SignerAlgorithm)
+	}
+	if _, ok := keyAlgorithms[opts.TLSAlgorithm]; !ok && opts.TLSAlgorithm != "" {
+		return nil, fmt.Errorf("unknown TLS key algorithm %q", opts.TLSAlgorithm)
+	}
+	if len(opts.Certificate.Country) > 2 {
+		return nil, fmt.Errorf("country must be a two letter code, not %q", opts.Certificate.Country)
+	}
+	return opts, nil
+}
+
+// signerKeyBits are the su3 signing key algorithms. su3 files only carry RSA
+// signatures, and each size has its own signature type.
+var signerKeyBits = map[string]int{
+	"rsa2048": 2048,
+	"rsa3072": 3072,
+	"rsa4096": 4096,
+}
+
+// signerKey generates a new su3 signing key.
+func (o *keygenOptions) signerKey() (*rsa.PrivateKey, error) {
+	bits := 4096
+	if o != nil && o.SignerAlgorithm != "" {
+		bits = signerKeyBits[o.SignerAlgorithm]
+	}
+	return rsa.GenerateKey(rand.Reader, bits)
+}
+
+// tlsKey generates a new TLS key.
+func (o *keygenOptions) tlsKey() (crypto.Signer, error) {
+	algorithm := "ecdsa-p384"
+	if o != nil && o.TLSAlgorithm != "" {
+		algorithm = o.TLSAlgorithm
 	}
+	return keyAlgorithms[algorithm]()
+}
+
+func (o *keygenOptions) certificate() su3.CertificateOptions {
+	if o == nil {
+		return su3.CertificateOptions{}
+	}
+	return o.Certificate
+}
+
+func (o *keygenOptions) altNames() []string {
+	if o == nil {
+		return nil
+	}
+	return o.AltNames
+}
+
+// keyAlgorithms generate keys for the TLS key algorithms keygen knows.
+var keyAlgorithms = map[string]func() (crypto.Signer, error){
+	"ecdsa-p256": func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P256(), rand.Reader) },
+	"ecdsa-p384": func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P384(), rand.Reader) },
+	"ecdsa-p521": func() (crypto.Signer, error) { return ecdsa.GenerateKey(elliptic.P521(), rand.Reader) },
+	"ed25519": func() (crypto.Signer, error) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	},
+	"rsa2048": func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 2048) },
+	"rsa3072": func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 3072) },
+	"rsa4096": func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, 4096) },
+}
+
+// plainKeyBlock returns key as an unencrypted PEM block in the format
+// OpenSSL writes for its type.
+func plainKeyBlock(key crypto.Signer) (*pem.Block, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}, nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(k)
+		if nil != err {
+			return nil, err
+		}
+		return &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}, nil
+	default:
+		return pkcs8Block(key)
+	}
+}
+
+// ecParametersBlock returns the EC PARAMETERS block naming the curve of an
+// ECDSA key, or nil for other keys. http://www.ietf.org/rfc/rfc5480.txt
+func ecParametersBlock(key crypto.Signer) (*pem.Block, error) {
+	k, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, nil
+	}
+	var oid asn1.ObjectIdentifier
+	switch k.Curve {
+	case elliptic.P256():
+		oid = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	case elliptic.P384():
+		oid = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+	case elliptic.P521():
+		oid = asn1.ObjectIdentifier{1, 3, 132, 0, 35}
+	default:
+		return nil, nil
+	}
+	der, err := asn1.Marshal(oid)
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{Type: "EC PARAMETERS", Bytes: der}, nil
+}
+
+// parseValidity parses a validity period given in years (10y), days (90d)
+// or as a duration (2160h). An empty string is 0, keeping the default.
+func parseValidity(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	var d time.Duration
+	var err error
+	switch {
+	case strings.HasSuffix(value, "y"):
+		var n int
+		n, err = strconv.Atoi(strings.TrimSuffix(value, "y"))
+		d = time.Duration(n) * 365 * 24 * time.Hour
+	case strings.HasSuffix(value, "d"):
+		var n int
+		n, err = strconv.Atoi(strings.TrimSuffix(value, "d"))
+		d = time.Duration(n) * 24 * time.Hour
+	default:
+		d, err = time.ParseDuration(value)
+	}
+	if nil != err || d <= 0 {
+		return 0, fmt.Errorf("'%s' is not a valid validity period", value)
+	}
+	return d, nil
 }
 
 func keygenAction(c *cli.Context) error {
 	signerID := c.String("signer")
 	tlsHost := c.String("tlsHost")
-	trustProxy := c.Bool("trustProxy")
+	kp := keyProtectionFromFlags(c, c.Bool("encrypt"))
 
 	if signerID == "" && tlsHost == "" {
 		fmt.Println("You must specify either --tlsHost or --signer")
 		return fmt.Errorf("You must specify either --tlsHost or --signer")
 	}
 
+	if c.String("submit") != "" && signerID == "" {
+		fmt.Println("--submit needs --signer")
+		return fmt.Errorf("--submit needs --signer")
+	}
+
+	opts, err := keygenOptionsFromFlags(c)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+
 	if signerID != "" {
-		if err := createSigningCertificate(signerID); nil != err {
+		if err := createSigningCertificateFiles(signerID, signerFile(signerID), kp, opts); nil != err {
 			fmt.Println(err)
 			return err
 		}
-	}
-
-	if trustProxy {
-		if tlsHost != "" {
-			if err := createTLSCertificate(tlsHost); nil != err {
+		if dir := c.String("submit"); dir != "" {
+			cert, err := loadCertificate(signerFile(signerID) + ".crt")
+			if nil != err {
+				return err
+			}
+			if err := writeSubmissionBundle(dir, signerID, cert, nil); nil != err {
 				fmt.Println(err)
 				return err
 			}
+			fmt.Println("\tCertificate bundle to submit saved to:", dir)
+		}
+	}
+
+	if tlsHost != "" {
+		if err := createTLSCertificate(tlsHost, kp, opts); nil != err {
+			fmt.Println(err)
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func TestParseValidity(t *testing.T) {
+	tests := map[string]time.Duration{
+		"":      0,
+		"2y":    2 * 365 * 24 * time.Hour,
+		"90d":   90 * 24 * time.Hour,
+		"2160h": 2160 * time.Hour,
+	}
+	for value, want := range tests {
+		got, err := parseValidity(value)
+		if err != nil || got != want {
+			t.Errorf("parseValidity(%q) = %v, %v, want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"3w", "-1d", "0y", "y"} {
+		if _, err := parseValidity(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	for _, name := range []string{"ecdsa-p256", "ecdsa-p384", "ecdsa-p521", "ed25519"} {
+		t.Run(name, func(t *testing.T) {
+			key, err := (&keygenOptions{TLSAlgorithm: name}).tlsKey()
+			if err != nil {
+				t.Fatalf("tlsKey failed: %v", err)
+			}
+			block, err := plainKeyBlock(key)
+			if err != nil {
+				t.Fatalf("plainKeyBlock failed: %v", err)
+			}
+			data := pem.EncodeToMemory(block)
+			params, err := ecParametersBlock(key)
+			if err != nil {
+				t.Fatalf("ecParametersBlock failed: %v", err)
+			}
+			if _, isEC := key.(*ecdsa.PrivateKey); isEC != (params != nil) {
+				t.Errorf("Expected EC PARAMETERS only for ECDSA keys")
+			}
+			if params != nil {
+				data = append(pem.EncodeToMemory(params), data...)
+			}
+			if _, err := reseed.ParsePrivateKeyPEM(data, nil); err != nil {
+				t.Errorf("Written key can't be read back: %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteSubmissionBundle(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificateWithOptions("test@mail.i2p", key, su3.CertificateOptions{Country: "DE"})
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := writeSubmissionBundle(dir, "test@mail.i2p", cert, []string{"a note"}); err != nil {
+		t.Fatalf("writeSubmissionBundle failed: %v", err)
+	}
+	written, err := loadCertificate(filepath.Join(dir, "reseed", "test_at_mail.i2p.crt"))
+	if err != nil {
+		t.Fatalf("Failed to read the bundled certificate: %v", err)
+	}
+	if !written.Equal(cert) {
+		t.Error("Bundled certificate does not match")
+	}
+	note, err := os.ReadFile(filepath.Join(dir, "SUBMIT.txt"))
+	if err != nil {
+		t.Fatalf("Failed to read SUBMIT.txt: %v", err)
+	}
+	for _, want := range []string{reseed.CertificateFingerprint(cert), "RSA 2048", "C=DE", "a note"} {
+		if !strings.Contains(string(note), want) {
+			t.Errorf("SUBMIT.txt is missing %q:\n%s", want, note)
+		}
+	}
+}
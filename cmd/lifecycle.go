@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// serverGroup runs the servers of the reseed command until one of them
+// fails or the process is told to stop, and then shuts them all down.
+type serverGroup struct {
+	mu      sync.Mutex
+	servers []*reseed.Server
+	// errs holds the error of the first server to stop
+	errs chan error
+}
+
+func newServerGroup() *serverGroup {
+	return &serverGroup{errs: make(chan error, 1)}
+}
+
+// serve runs serve in the background for srv, named name in logs. A server
+// can serve several listeners, each with its own call.
+func (g *serverGroup) serve(name string, srv *reseed.Server, serve func() error) {
+	g.mu.Lock()
+	known := false
+	for _, s := range g.servers {
+		known = known || s == srv
+	}
+	if !known {
+		g.servers = append(g.servers, srv)
+	}
+	g.mu.Unlock()
+
+	go func() {
+		err := serve()
+		if errors.Is(err, http.ErrServerClosed) {
+			return
+		}
+		err = fmt.Errorf("%s server stopped: %s", name, err)
+		select {
+		case g.errs <- err:
+		default:
+			log.Println(err)
+		}
+	}()
+}
+
+// setRateLimits changes the rate limits of every server.
+func (g *serverGroup) setRateLimits(request, requestBurst, web, webBurst int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, srv := range g.servers {
+		srv.SetRateLimits(request, requestBurst, web, webBurst)
+	}
+}
+
+// wait blocks until ctx is done or a server stops, and then shuts every
+// server down, giving the requests in progress up to timeout to finish. It
+// returns the error of the server which stopped, if one did.
+func (g *serverGroup) wait(ctx context.Context, timeout time.Duration) error {
+	var err error
+	select {
+	case <-ctx.Done():
+		log.Println("Shutting down, finishing the requests in progress...")
+	case err = <-g.errs:
+		log.Println(err)
+		log.Println("Shutting down the other servers...")
+	}
+
+	shutdown, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	g.mu.Lock()
+	servers := g.servers
+	g.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *reseed.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(shutdown); nil != err {
+				log.Println("Shutdown:", err)
+			}
+		}(srv)
+	}
+	wg.Wait()
+	return err
+}
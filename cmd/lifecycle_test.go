@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestServerGroup(t *testing.T) {
+	// stopping shuts every server down without an error
+	g := newServerGroup()
+	for i := 0; i < 2; i++ {
+		server := reseed.NewServer("", false)
+		server.Addr = "127.0.0.1:0"
+		g.serve("HTTP", server, server.ListenAndServe)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := g.wait(ctx, time.Second); err != nil {
+		t.Errorf("wait() = %v after stopping", err)
+	}
+
+	// a server which fails stops the others
+	g = newServerGroup()
+	running := reseed.NewServer("", false)
+	running.Addr = "127.0.0.1:0"
+	g.serve("HTTP", running, running.ListenAndServe)
+	failing := reseed.NewServer("", false)
+	failing.Addr = "127.0.0.1:-1"
+	g.serve("Broken", failing, failing.ListenAndServe)
+
+	done := make(chan error)
+	go func() { done <- g.wait(context.Background(), time.Second) }()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "Broken server stopped") {
+			t.Errorf("wait() = %v, want the failing server's error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait() didn't return when a server failed")
+	}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+	"golang.org/x/term"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// passphraseFlags are the flags of every command which reads private keys,
+// saying where the passphrase for encrypted keys comes from.
+func passphraseFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "passphraseEnv",
+			Value: "RESEED_PASSPHRASE",
+			Usage: "Environment variable holding the passphrase for encrypted private keys",
+		},
+		&cli.IntFlag{
+			Name:  "passphraseFd",
+			Value: -1,
+			Usage: "Read the passphrase for encrypted private keys from this file descriptor instead",
+		},
+	}
+}
+
+// kdfFlag is the flag of every command which encrypts private keys.
+func kdfFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "kdf",
+		Value: reseed.KDFScrypt,
+		Usage: "Key derivation function for encrypting private keys (scrypt or argon2id)",
+	}
+}
+
+// keyProtection says how private keys are read and written.
+type keyProtection struct {
+	// Passphrase unlocks encrypted keys, and encrypts new ones
+	Passphrase reseed.PassphraseFunc
+	// Encrypt new keys with the passphrase using KDF
+	Encrypt bool
+	KDF     string
+}
+
+// keyProtectionFromFlags reads the passphrase flags. The passphrase itself is
+// only read, once, when an encrypted key is found or a key is encrypted.
+func keyProtectionFromFlags(c *cli.Context, encrypt bool) *keyProtection {
+	kp := &keyProtection{Encrypt: encrypt, KDF: c.String("kdf")}
+	if kp.KDF == "" {
+		kp.KDF = reseed.KDFScrypt
+	}
+	var once sync.Once
+	var pass []byte
+	var err error
+	kp.Passphrase = func() ([]byte, error) {
+		once.Do(func() {
+			// a new passphrase is typed twice
+			pass, err = readPassphrase(c.String("passphraseEnv"), c.Int("passphraseFd"), encrypt)
+		})
+		return pass, err
+	}
+	return kp
+}
+
+// passphrase returns the PassphraseFunc for reading keys, which is nil if kp
+// is.
+func (kp *keyProtection) passphrase() reseed.PassphraseFunc {
+	if kp == nil {
+		return nil
+	}
+	return kp.Passphrase
+}
+
+// keyBlock returns the PEM block to store key in: plain, or key encrypted
+// with the passphrase if kp asks for it.
+func (kp *keyProtection) keyBlock(key crypto.PrivateKey, plain *pem.Block) (*pem.Block, error) {
+	if kp == nil || !kp.Encrypt {
+		return plain, nil
+	}
+	pass, err := kp.Passphrase()
+	if nil != err {
+		return nil, err
+	}
+	return reseed.EncryptPrivateKey(key, pass, kp.KDF)
+}
+
+// pkcs8Block returns key as an unencrypted PKCS#8 PEM block.
+func pkcs8Block(key crypto.PrivateKey) (*pem.Block, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if nil != err {
+		return nil, err
+	}
+	return &pem.Block{Type: "PRIVATE KEY", Bytes: der}, nil
+}
+
+// readPassphrase reads a passphrase from the file descriptor fd if it is not
+// negative, then from the environment variable env, and then by prompting on
+// the terminal.
+func readPassphrase(env string, fd int, confirm bool) ([]byte, error) {
+	if fd >= 0 {
+		f := os.NewFile(uintptr(fd), "passphrase")
+		if f == nil {
+			return nil, fmt.Errorf("invalid passphrase file descriptor %d", fd)
+		}
+		defer f.Close()
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if nil != err && line == "" {
+			return nil, fmt.Errorf("unable to read passphrase from file descriptor %d: %s", fd, err)
+		}
+		return passphraseBytes(strings.TrimRight(line, "\r\n"))
+	}
+
+	if env != "" {
+		if value := os.Getenv(env); value != "" {
+			// don't pass it on to anything we start
+			os.Unsetenv(env)
+			return passphraseBytes(value)
+		}
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("a passphrase is needed: set $%s, use --passphraseFd, or run from a terminal", env)
+	}
+	fmt.Fprint(os.Stderr, "Passphrase for private keys: ")
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if nil != err {
+		return nil, err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Repeat the passphrase: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if nil != err {
+			return nil, err
+		}
+		if !bytes.Equal(pass, again) {
+			return nil, fmt.Errorf("the passphrases don't match")
+		}
+	}
+	return passphraseBytes(string(pass))
+}
+
+func passphraseBytes(pass string) ([]byte, error) {
+	if pass == "" {
+		return nil, fmt.Errorf("the passphrase is empty")
+	}
+	return []byte(pass), nil
+}
@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// pkcs12PasswordFlags say where the password of a PKCS#12 bundle comes from.
+func pkcs12PasswordFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "p12PasswordEnv",
+			Value: "RESEED_P12_PASSWORD",
+			Usage: "Environment variable holding the password of the PKCS#12 bundle",
+		},
+		&cli.IntFlag{
+			Name:  "p12PasswordFd",
+			Value: -1,
+			Usage: "Read the password of the PKCS#12 bundle from this file descriptor instead",
+		},
+	}
+}
+
+// credentialFlags choose the su3 signing or TLS credentials to move.
+func credentialFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "signer",
+			Usage: "Your su3 signing ID (ex. something@mail.i2p), to use the signing key",
+		},
+		&cli.StringFlag{
+			Name:  "tlsHost",
+			Usage: "The hostname of the TLS certificate, to use the TLS key",
+		},
+	}
+}
+
+// NewPKCS12Command creates a new CLI command for moving keys between PEM
+// files and PKCS#12 bundles.
+func NewPKCS12Command() *cli.Command {
+	return &cli.Command{
+		Name:  "pkcs12",
+		Usage: "Move su3 signing and TLS keys between PEM files and PKCS#12 bundles",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "export",
+				Usage:  "Bundle a key and its certificate chain in a password protected PKCS#12 file",
+				Action: pkcs12ExportAction,
+				Flags: append(append(append(credentialFlags(), []cli.Flag{
+					&cli.StringFlag{
+						Name:  "key",
+						Usage: "Path to the private key (default: the signer's or host's .pem)",
+					},
+					&cli.StringFlag{
+						Name:  "cert",
+						Usage: "Path to the certificate chain (default: the signer's or host's .crt)",
+					},
+					&cli.StringFlag{
+						Name:  "out",
+						Usage: "Path to write the PKCS#12 bundle to (default: the signer's or host's .p12)",
+					},
+				}...), pkcs12PasswordFlags()...), passphraseFlags()...),
+			},
+			{
+				Name:      "import",
+				Usage:     "Write the key and certificate chain of a PKCS#12 file as PEM files",
+				ArgsUsage: "BUNDLE",
+				Action:    pkcs12ImportAction,
+				Flags: append(append(append(credentialFlags(), []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Replace existing key and certificate files",
+					},
+					&cli.BoolFlag{
+						Name:  "encrypt",
+						Usage: "Encrypt the imported private key with a passphrase",
+					},
+					kdfFlag(),
+				}...), pkcs12PasswordFlags()...), passphraseFlags()...),
+			},
+		},
+	}
+}
+
+// credentialBase returns the file name, without extension, of the keys
+// chosen with credentialFlags, and the name to use for them.
+func credentialBase(c *cli.Context) (string, string, error) {
+	signerID := c.String("signer")
+	tlsHost := c.String("tlsHost")
+	switch {
+	case signerID != "" && tlsHost != "":
+		return "", "", fmt.Errorf("Give either --signer or --tlsHost, not both")
+	case signerID != "":
+		return signerFile(signerID), signerID, nil
+	case tlsHost != "":
+		return tlsHost, tlsHost, nil
+	default:
+		return "", "", fmt.Errorf("You must specify either --tlsHost or --signer")
+	}
+}
+
+func pkcs12ExportAction(c *cli.Context) error {
+	base, name, err := credentialBase(c)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	keyPath := c.String("key")
+	if keyPath == "" {
+		keyPath = base + ".pem"
+	}
+	certPath := c.String("cert")
+	if certPath == "" {
+		certPath = base + ".crt"
+	}
+	out := c.String("out")
+	if out == "" {
+		out = base + ".p12"
+	}
+	kp := keyProtectionFromFlags(c, false)
+	key, err := reseed.LoadPrivateKey(keyPath, kp.passphrase())
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	certs, err := loadCertificateChain(certPath)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	chain, err := reseed.OrderChain(key, certs)
+	if nil != err {
+		fmt.Printf("%s and %s: %s\n", keyPath, certPath, err)
+		return fmt.Errorf("%s and %s: %s", keyPath, certPath, err)
+	}
+
+	password, err := readPassphrase(c.String("p12PasswordEnv"), c.Int("p12PasswordFd"), true)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	data, err := reseed.EncodePKCS12(key, chain, password)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if err := writeFileAtomic(out, data, 0o600); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Printf("\tPKCS#12 bundle of %s saved to: %s\n", name, out)
+	return nil
+}
+
+func pkcs12ImportAction(c *cli.Context) error {
+	base, name, err := credentialBase(c)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if c.Args().Len() != 1 {
+		fmt.Println("Give the PKCS#12 bundle to import")
+		return fmt.Errorf("Give the PKCS#12 bundle to import")
+	}
+	data, err := os.ReadFile(c.Args().First())
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	password, err := readPassphrase(c.String("p12PasswordEnv"), c.Int("p12PasswordFd"), false)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	key, certs, err := reseed.DecodePKCS12(data, password)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	chain, err := reseed.OrderChain(key, certs)
+	if nil == err {
+		err = checkImportedCredentials(c.String("signer") != "", name, key, chain[0])
+	}
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if time.Now().After(chain[0].NotAfter) {
+		fmt.Printf("Warning: the certificate expired on %s\n", chain[0].NotAfter.Format(time.RFC3339))
+	}
+
+	keyPath, certPath := base+".pem", base+".crt"
+	if !c.Bool("force") {
+		for _, path := range []string{keyPath, certPath} {
+			if _, err := os.Stat(path); nil == err {
+				fmt.Printf("%s already exists, use --force to replace it\n", path)
+				return fmt.Errorf("%s already exists", path)
+			}
+		}
+	}
+
+	kp := keyProtectionFromFlags(c, c.Bool("encrypt"))
+	plain, err := plainKeyBlock(key)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	keyBlock, err := kp.keyBlock(key, plain)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	var certPem bytes.Buffer
+	for _, cert := range chain {
+		pem.Encode(&certPem, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	keyPem := pem.EncodeToMemory(keyBlock)
+	if c.String("signer") != "" {
+		// signing keys are saved with their certificate, as keygen does
+		keyPem = append(keyPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: chain[0].Raw})...)
+	}
+
+	if err := writeFileAtomic(keyPath, keyPem, 0o600); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("\tPrivate key saved to:", keyPath)
+	if err := writeFileAtomic(certPath, certPem.Bytes(), 0o644); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("\tCertificate chain saved to:", certPath)
+	return nil
+}
+
+// checkImportedCredentials checks that a key and certificate can be used as
+// the su3 signing key of name, or as the TLS key of the host name.
+func checkImportedCredentials(signer bool, name string, key crypto.Signer, cert *x509.Certificate) error {
+	if !signer {
+		return cert.VerifyHostname(name)
+	}
+	if _, ok := key.(*rsa.PrivateKey); !ok {
+		return fmt.Errorf("su3 files are signed with RSA keys, not %T", key)
+	}
+	if cert.Subject.CommonName != name {
+		return fmt.Errorf("the certificate is for %q, not %q", cert.Subject.CommonName, name)
+	}
+	return nil
+}
+
+// loadCertificateChain reads every certificate in the PEM file at path.
+func loadCertificateChain(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if nil != err {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+	return certs, nil
+}
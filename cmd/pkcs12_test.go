@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func TestCheckImportedCredentials(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := su3.NewSigningCertificate("test@mail.i2p", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkImportedCredentials(true, "test@mail.i2p", rsaKey, signerCert); err != nil {
+		t.Errorf("Expected the signing key to be accepted: %v", err)
+	}
+	if err := checkImportedCredentials(true, "other@mail.i2p", rsaKey, signerCert); err == nil {
+		t.Error("Expected an error for a certificate of another signer")
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err = reseed.NewTLSCertificateWithOptions(ecKey, su3.CertificateOptions{}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkImportedCredentials(false, "example.com", ecKey, tlsCert); err != nil {
+		t.Errorf("Expected the TLS key to be accepted: %v", err)
+	}
+	if err := checkImportedCredentials(false, "example.org", ecKey, tlsCert); err == nil {
+		t.Error("Expected an error for a certificate of another host")
+	}
+	if err := checkImportedCredentials(true, "example.com", ecKey, tlsCert); err == nil {
+		t.Error("Expected an error for an ECDSA signing key")
+	}
+}
+
+func TestLoadCertificateChain(t *testing.T) {
+	dir := t.TempDir()
+	writeTLSPair(t, dir, "example.com", nil)
+	certs, err := loadCertificateChain(filepath.Join(dir, "example.com.crt"))
+	if err != nil || len(certs) != 1 {
+		t.Fatalf("Expected 1 certificate, got %d: %v", len(certs), err)
+	}
+	if _, err := loadCertificateChain(filepath.Join(dir, "example.com.pem")); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// NewReencryptCommand creates a new CLI command for changing the passphrase
+// of private key files, or encrypting or decrypting them.
+func NewReencryptCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "reencrypt",
+		Usage:     "Encrypt private key files with a new passphrase, or decrypt them",
+		ArgsUsage: "KEYFILE...",
+		Action:    reencryptAction,
+		Flags: append([]cli.Flag{
+			&cli.BoolFlag{
+				Name:  "decrypt",
+				Usage: "Write the keys unencrypted instead",
+			},
+			&cli.StringFlag{
+				Name:  "newPassphraseEnv",
+				Value: "RESEED_NEW_PASSPHRASE",
+				Usage: "Environment variable holding the new passphrase",
+			},
+			&cli.IntFlag{
+				Name:  "newPassphraseFd",
+				Value: -1,
+				Usage: "Read the new passphrase from this file descriptor instead",
+			},
+			kdfFlag(),
+		}, passphraseFlags()...),
+	}
+}
+
+func reencryptAction(c *cli.Context) error {
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		fmt.Println("No key files given")
+		return fmt.Errorf("No key files given")
+	}
+
+	kp := keyProtectionFromFlags(c, false)
+	out := &keyProtection{Encrypt: !c.Bool("decrypt"), KDF: c.String("kdf")}
+	var once sync.Once
+	var newPass []byte
+	var newErr error
+	out.Passphrase = func() ([]byte, error) {
+		once.Do(func() {
+			newPass, newErr = readPassphrase(c.String("newPassphraseEnv"), c.Int("newPassphraseFd"), true)
+		})
+		return newPass, newErr
+	}
+
+	// read every file first, so a wrong passphrase changes nothing
+	reencrypted := make([][]byte, len(files))
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if nil != err {
+			return err
+		}
+		reencrypted[i], err = reencryptPEM(data, kp.passphrase(), out)
+		if nil != err {
+			fmt.Printf("Unable to re-encrypt '%s'\n", file)
+			return fmt.Errorf("%s: %s", file, err)
+		}
+	}
+
+	for i, file := range files {
+		if err := writeFileAtomic(file, reencrypted[i], 0o600); nil != err {
+			return err
+		}
+		if out.Encrypt {
+			fmt.Printf("Encrypted %s\n", file)
+		} else {
+			fmt.Printf("Decrypted %s\n", file)
+		}
+	}
+	return nil
+}
+
+// reencryptPEM rewrites every private key in data with out, and keeps any
+// other blocks, like certificates, as they are.
+func reencryptPEM(data []byte, passphrase reseed.PassphraseFunc, out *keyProtection) ([]byte, error) {
+	var buf bytes.Buffer
+	keys := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY", reseed.EncryptedPrivateKeyType:
+			key, err := reseed.ParsePrivateKeyPEM(pem.EncodeToMemory(block), passphrase)
+			if nil != err {
+				return nil, err
+			}
+			plain, err := pkcs8Block(key)
+			if nil != err {
+				return nil, err
+			}
+			if block, err = out.keyBlock(key, plain); nil != err {
+				return nil, err
+			}
+			keys++
+		}
+		if err := pem.Encode(&buf, block); nil != err {
+			return nil, err
+		}
+	}
+
+	if keys == 0 {
+		return nil, fmt.Errorf("no private key found")
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic replaces path with data, so it is never left half written.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); nil != err {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestNewReencryptCommand(t *testing.T) {
+	cmd := NewReencryptCommand()
+	if cmd.Name != "reencrypt" {
+		t.Errorf("Expected command name 'reencrypt', got %s", cmd.Name)
+	}
+	if cmd.Action == nil {
+		t.Error("Command action should not be nil")
+	}
+}
+
+func TestReencryptPEM(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("cert")})...)
+
+	first := func() ([]byte, error) { return []byte("first"), nil }
+	second := func() ([]byte, error) { return []byte("second"), nil }
+
+	encrypted, err := reencryptPEM(data, nil, &keyProtection{Passphrase: first, Encrypt: true, KDF: reseed.KDFScrypt})
+	if err != nil {
+		t.Fatalf("reencryptPEM failed: %v", err)
+	}
+	if !strings.Contains(string(encrypted), reseed.EncryptedPrivateKeyType) || !strings.Contains(string(encrypted), "CERTIFICATE") {
+		t.Fatalf("Expected an encrypted key and the certificate, got:\n%s", encrypted)
+	}
+
+	changed, err := reencryptPEM(encrypted, first, &keyProtection{Passphrase: second, Encrypt: true, KDF: reseed.KDFArgon2id})
+	if err != nil {
+		t.Fatalf("reencryptPEM failed: %v", err)
+	}
+	if _, err := reseed.ParsePrivateKeyPEM(changed, first); err == nil {
+		t.Error("Expected the old passphrase to be rejected")
+	}
+
+	decrypted, err := reencryptPEM(changed, second, &keyProtection{})
+	if err != nil {
+		t.Fatalf("reencryptPEM failed: %v", err)
+	}
+	got, err := reseed.ParsePrivateKeyPEM(decrypted, nil)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM failed: %v", err)
+	}
+	if !key.Equal(got) {
+		t.Error("Decrypted key does not match")
+	}
+
+	if _, err := reencryptPEM(changed, first, &keyProtection{}); err == nil {
+		t.Error("Expected an error with the wrong passphrase")
+	}
+}
+
+func TestReadPassphraseEnv(t *testing.T) {
+	t.Setenv("RESEED_TEST_PASSPHRASE", "hunter2")
+	pass, err := readPassphrase("RESEED_TEST_PASSPHRASE", -1, true)
+	if err != nil {
+		t.Fatalf("readPassphrase failed: %v", err)
+	}
+	if string(pass) != "hunter2" {
+		t.Errorf("Expected 'hunter2', got %q", pass)
+	}
+}
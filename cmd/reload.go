@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// reloadableFlags are the settings of the reseed command which SIGHUP
+// applies while it runs. The others need a restart.
+var reloadableFlags = map[string]bool{
+	"config":            true,
+	"blacklist":         true,
+	"friends":           true,
+	"ratelimit":         true,
+	"ratelimitweb":      true,
+	"ratelimitBurst":    true,
+	"ratelimitwebBurst": true,
+	"numRi":             true,
+	"numSu3":            true,
+	"su3Builders":       true,
+	"interval":          true,
+	"churnThreshold":    true,
+}
+
+// reloader reads the settings of the reseed command again on SIGHUP, and
+// applies those which can change without dropping the listeners.
+type reloader struct {
+	// started are the settings the command started with, which are kept
+	// for those needing a restart
+	started *cli.Context
+	// current are the settings of the last reload
+	current   *cli.Context
+	certs     *reseed.CertificateStore
+	expiry    *reseed.ExpiryMonitor
+	blacklist *reseed.Blacklist
+	reseeder  startableReseeder
+	servers   *serverGroup
+	// args are the arguments of the reseed command, os.Args when nil
+	args []string
+}
+
+// reloadedSettings are the settings read again, checked and ready to apply.
+type reloadedSettings struct {
+	c         *cli.Context
+	blacklist *reseed.Blacklist
+	// blacklistStats describe the blacklist files loaded
+	blacklistStats reseed.BlacklistStats
+	selection      reseed.Selection
+}
+
+// reload reads the config file, the environment and the blacklist again,
+// and reloads the TLS certificates. Nothing changes unless all of them are
+// valid.
+func (r *reloader) reload() {
+	log.Println("Reloading the configuration")
+	next, err := r.read()
+	if nil != err {
+		log.Printf("Reload rejected, keeping the current settings: %s\n", err)
+		return
+	}
+	r.apply(next)
+}
+
+// read parses the reseed command line again and checks the settings.
+func (r *reloader) read() (*reloadedSettings, error) {
+	args := r.args
+	if args == nil {
+		args = reseedArgs(os.Args)
+	}
+	c, err := parseReseedFlags(args)
+	if nil != err {
+		return nil, err
+	}
+
+	next := &reloadedSettings{c: c, blacklist: reseed.NewBlacklist()}
+	interval, err := time.ParseDuration(c.String("interval"))
+	if nil != err {
+		return nil, fmt.Errorf("interval: %s", err)
+	}
+	next.selection = reseed.Selection{
+		NumRi:           c.Int("numRi"),
+		NumSu3:          c.Int("numSu3"),
+		NumBuilders:     c.Int("su3Builders"),
+		RebuildInterval: interval,
+		ChurnThreshold:  c.Int("churnThreshold"),
+	}
+
+	var errs []error
+	if interval <= 0 {
+		errs = append(errs, fmt.Errorf("interval must be positive, not %s", interval))
+	}
+	if next.selection.NumRi <= 0 {
+		errs = append(errs, fmt.Errorf("numRi must be positive, not %d", next.selection.NumRi))
+	}
+	if next.selection.NumSu3 < 0 {
+		errs = append(errs, fmt.Errorf("numSu3 can't be negative"))
+	}
+	if next.selection.NumBuilders <= 0 {
+		errs = append(errs, fmt.Errorf("su3Builders must be positive, not %d", next.selection.NumBuilders))
+	}
+	for _, name := range []string{"ratelimit", "ratelimitweb", "ratelimitBurst", "ratelimitwebBurst"} {
+		if c.Int(name) < 0 {
+			errs = append(errs, fmt.Errorf("%s can't be negative", name))
+		}
+	}
+	for _, friend := range c.StringSlice("friends") {
+		if u, err := url.Parse(friend); nil != err || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("friends: %q is not an http or https URL", friend))
+		}
+	}
+	if files := c.StringSlice("blacklist"); len(files) > 0 {
+		next.blacklistStats, err = next.blacklist.LoadFiles(files...)
+		if nil != err {
+			errs = append(errs, fmt.Errorf("blacklist: %s", err))
+		}
+	}
+	if r.certs != nil {
+		if err := r.certs.Check(); nil != err {
+			errs = append(errs, err)
+		}
+	}
+	return next, errors.Join(errs...)
+}
+
+// apply logs what changed and puts the reloadable settings in use.
+func (r *reloader) apply(next *reloadedSettings) {
+	c := next.c
+	for _, f := range reseedFlags() {
+		name := f.Names()[0]
+		in := r.started
+		if reloadableFlags[name] {
+			in = r.current
+		}
+		was, is := configValue(in, f), configValue(c, f)
+		if was == is {
+			continue
+		}
+		if secretFlags[name] {
+			was, is = `"(hidden)"`, `"(hidden)"`
+		}
+		if reloadableFlags[name] {
+			log.Printf("%s: %s -> %s\n", name, was, is)
+		} else {
+			log.Printf("%s: %s -> %s needs a restart, keeping %s\n", name, was, is, was)
+		}
+	}
+
+	added, removed := r.blacklist.Replace(next.blacklist)
+	if added != 0 || removed != 0 {
+		log.Printf("Blacklist: %d added, %d removed, %d blocked\n", added, removed, r.blacklist.Len())
+	}
+	if next.blacklistStats.Files > 0 {
+		log.Printf("Blacklist: %s\n", next.blacklistStats)
+	}
+	reseed.SetReseeds(c.StringSlice("friends"))
+	r.servers.setRateLimits(c.Int("ratelimit"), c.Int("ratelimitBurst"), c.Int("ratelimitweb"), c.Int("ratelimitwebBurst"))
+	if rs, ok := r.reseeder.(*reseed.ReseederImpl); ok {
+		selection := next.selection
+		if !r.started.Bool("watch") {
+			selection.ChurnThreshold = 0
+		}
+		rs.SetSelection(selection)
+	}
+
+	if r.certs != nil {
+		log.Println("Reloading TLS certificates")
+		if err := r.certs.Reload(); nil != err {
+			log.Println(err)
+		}
+	}
+	if r.expiry != nil {
+		r.expiry.Check()
+	}
+
+	r.current = c
+}
+
+// reseedArgs returns the arguments after the reseed command in args.
+func reseedArgs(args []string) []string {
+	for i, arg := range args {
+		if i > 0 && arg == "reseed" {
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// parseReseedFlags parses the flags of the reseed command from args, the
+// environment and the config file as they are now.
+func parseReseedFlags(args []string) (*cli.Context, error) {
+	flags := reseedFlags()
+	var parsed *cli.Context
+	app := &cli.App{
+		Name:      "reseed",
+		Flags:     flags,
+		Writer:    io.Discard,
+		ErrWriter: io.Discard,
+		HideHelp:  true,
+		Before: func(c *cli.Context) error {
+			return loadConfigFile(c, flags)
+		},
+		Action: func(c *cli.Context) error {
+			parsed = c
+			return nil
+		},
+	}
+	if err := app.Run(append([]string{"reseed"}, args...)); nil != err {
+		return nil, err
+	}
+	return parsed, nil
+}
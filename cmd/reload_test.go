@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	blacklistFile := filepath.Join(dir, "blacklist.txt")
+	if err := os.WriteFile(blacklistFile, []byte("10.0.0.1\n10.0.0.2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := writeConfig(t, `
+blacklist = "`+blacklistFile+`"
+
+[ratelimit]
+requests = 6
+
+[friends]
+urls = ["https://reseed.example/"]
+`)
+	args := []string{"--config", path}
+	started, err := parseReseedFlags(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := reseed.NewServer("", false)
+	servers := &serverGroup{servers: []*reseed.Server{server}}
+	r := &reloader{
+		started:   started,
+		current:   started,
+		blacklist: reseed.NewBlacklist(),
+		servers:   servers,
+		args:      args,
+	}
+	defer reseed.SetReseeds(reseed.AllReseeds)
+
+	r.reload()
+	if r.blacklist.Len() != 2 {
+		t.Errorf("blacklist has %d entries after the reload", r.blacklist.Len())
+	}
+	if server.RequestRateLimit != 6 {
+		t.Errorf("RequestRateLimit = %d, want 6", server.RequestRateLimit)
+	}
+	if got := reseed.Reseeds(); !reflect.DeepEqual(got, []string{"https://reseed.example/"}) {
+		t.Errorf("Reseeds() = %v", got)
+	}
+
+	// a bad setting rejects the whole reload
+	if err := os.WriteFile(path, []byte("blacklist = \"/nonexistent\"\n[ratelimit]\nrequests = 8\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.reload()
+	if r.blacklist.Len() != 2 || server.RequestRateLimit != 6 {
+		t.Errorf("rejected reload changed the settings: %d blacklisted, rate limit %d", r.blacklist.Len(), server.RequestRateLimit)
+	}
+	if err := os.WriteFile(path, []byte("[ratelimit]\nrequests = -1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.read(); err == nil {
+		t.Error("a negative rate limit was accepted")
+	}
+
+	// 0 lifts the limit, as it does on start
+	if err := os.WriteFile(path, []byte("[ratelimit]\nrequests = 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.read(); err != nil {
+		t.Errorf("a rate limit of 0 was rejected: %v", err)
+	}
+}
+
+func TestReseedArgs(t *testing.T) {
+	got := reseedArgs([]string{"reseed-tools", "reseed", "--signer", "you@mail.i2p"})
+	if !reflect.DeepEqual(got, []string{"--signer", "you@mail.i2p"}) {
+		t.Errorf("reseedArgs() = %v", got)
+	}
+}
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"crypto"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -12,8 +14,10 @@ import (
 	"log"
 	"net"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/cretz/bine/tor"
@@ -52,161 +56,351 @@ func getHostName() string {
 
 func providedReseeds(c *cli.Context) []string {
 	reseedArg := c.StringSlice("friends")
-	reseed.AllReseeds = reseedArg
-	return reseed.AllReseeds
+	reseed.SetReseeds(reseedArg)
+	return reseed.Reseeds()
 }
 
 // NewReseedCommand creates a new CLI command for starting a reseed server.
 func NewReseedCommand() *cli.Command {
-	ndb, err := getmeanetdb.WhereIstheNetDB()
-	if err != nil {
-		log.Fatal(err)
-	}
+	flags := reseedFlags()
 	return &cli.Command{
 		Name:   "reseed",
 		Usage:  "Start a reseed server",
 		Action: reseedAction,
-		Flags: []cli.Flag{
-			&cli.StringFlag{
-				Name:  "signer",
-				Value: getDefaultSigner(),
-				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
-			},
-			&cli.StringFlag{
-				Name:  "tlsHost",
-				Value: getHostName(),
-				Usage: "The public hostname used on your TLS certificate",
-			},
-			&cli.BoolFlag{
-				Name:  "onion",
-				Usage: "Present an onionv3 address",
-			},
-			&cli.BoolFlag{
-				Name:  "singleOnion",
-				Usage: "Use a faster, but non-anonymous single-hop onion",
-			},
-			&cli.StringFlag{
-				Name:  "onionKey",
-				Value: "onion.key",
-				Usage: "Specify a path to an ed25519 private key for onion",
-			},
-			&cli.StringFlag{
-				Name:  "key",
-				Usage: "Path to your su3 signing private key",
-			},
-			&cli.StringFlag{
-				Name:  "netdb",
-				Value: ndb,
-				Usage: "Path to NetDB directory containing routerInfos",
-			},
-			&cli.DurationFlag{
-				Name:  "routerInfoAge",
-				Value: 72 * time.Hour,
-				Usage: "Maximum age of router infos to include in reseed files (ex. 72h, 8d)",
-			},
-			&cli.StringFlag{
-				Name:  "tlsCert",
-				Usage: "Path to a TLS certificate",
-			},
-			&cli.StringFlag{
-				Name:  "tlsKey",
-				Usage: "Path to a TLS private key",
-			},
-			&cli.StringFlag{
-				Name:  "ip",
-				Value: "0.0.0.0",
-				Usage: "IP address to listen on",
-			},
-			&cli.StringFlag{
-				Name:  "port",
-				Value: "8443",
-				Usage: "Port to listen on",
-			},
-			&cli.IntFlag{
-				Name:  "numRi",
-				Value: 77,
-				Usage: "Number of routerInfos to include in each su3 file",
-			},
-			&cli.IntFlag{
-				Name:  "numSu3",
-				Value: 50,
-				Usage: "Number of su3 files to build (0 = automatic based on size of netdb)",
-			},
-			&cli.StringFlag{
-				Name:  "interval",
-				Value: "90h",
-				Usage: "Duration between SU3 cache rebuilds (ex. 12h, 15m)",
-			},
-			&cli.StringFlag{
-				Name:  "prefix",
-				Value: "",
-				Usage: "Prefix path for the HTTP(S) server. (ex. /netdb)",
-			},
-			&cli.BoolFlag{
-				Name:  "trustProxy",
-				Usage: "If provided, we will trust the 'X-Forwarded-For' header in requests (ex. behind cloudflare)",
-			},
-			&cli.StringFlag{
-				Name:  "blacklist",
-				Value: "",
-				Usage: "Path to a txt file containing a list of IPs to deny connections from.",
-			},
-			&cli.DurationFlag{
-				Name:  "stats",
-				Value: 0,
-				Usage: "Periodically print memory stats.",
-			},
-			&cli.BoolFlag{
-				Name:  "i2p",
-				Usage: "Listen for reseed request inside the I2P network",
-			},
-			&cli.BoolFlag{
-				Name:  "yes",
-				Usage: "Automatically answer 'yes' to self-signed SSL generation",
-			},
-			&cli.StringFlag{
-				Name:  "samaddr",
-				Value: "127.0.0.1:7656",
-				Usage: "Use this SAM address to set up I2P connections for in-network reseed",
-			},
-			&cli.StringSliceFlag{
-				Name:  "friends",
-				Value: cli.NewStringSlice(reseed.AllReseeds...),
-				Usage: "Ping other reseed servers and display the result on the homepage to provide information about reseed uptime.",
-			},
-			&cli.StringFlag{
-				Name:  "share-peer",
-				Value: "",
-				Usage: "Download the shared netDb content of another I2P router, over I2P",
-			},
-			&cli.StringFlag{
-				Name:  "share-password",
-				Value: "",
-				Usage: "Password for downloading netDb content from another router. Required for share-peer to work.",
-			},
-			&cli.BoolFlag{
-				Name:  "acme",
-				Usage: "Automatically generate a TLS certificate with the ACME protocol, defaults to Let's Encrypt",
-			},
-			&cli.StringFlag{
-				Name:  "acmeserver",
-				Value: "https://acme-staging-v02.api.letsencrypt.org/directory",
-				Usage: "Use this server to issue a certificate with the ACME protocol",
-			},
-			&cli.IntFlag{
-				Name:  "ratelimit",
-				Value: 4,
-				Usage: "Maximum number of reseed bundle requests per-IP address, per-hour.",
-			},
-			&cli.IntFlag{
-				Name:  "ratelimitweb",
-				Value: 40,
-				Usage: "Maxiumum number of web-visits per-IP address, per-hour",
-			},
+		Before: func(c *cli.Context) error {
+			return loadConfigFile(c, flags)
 		},
+		Flags: flags,
 	}
 }
 
+// reseedFlags are the flags of the reseed command. Each can also be set
+// in the config file, or with a RESEED_ environment variable.
+func reseedFlags() []cli.Flag {
+	ndb, err := getmeanetdb.WhereIstheNetDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return withEnvVars(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "config",
+			Usage: "Read settings from this TOML file. Flags and RESEED_ environment variables override it.",
+		},
+		&cli.StringFlag{
+			Name:  "signer",
+			Value: getDefaultSigner(),
+			Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+		},
+		&cli.StringFlag{
+			Name:  "tlsHost",
+			Value: getHostName(),
+			Usage: "The public hostname used on your TLS certificate",
+		},
+		&cli.BoolFlag{
+			Name:  "onion",
+			Usage: "Present an onionv3 address",
+		},
+		&cli.BoolFlag{
+			Name:  "singleOnion",
+			Usage: "Use a faster, but non-anonymous single-hop onion",
+		},
+		&cli.StringFlag{
+			Name:  "onionKey",
+			Value: "onion.key",
+			Usage: "Specify a path to an ed25519 private key for onion",
+		},
+		&cli.StringFlag{
+			Name:  "key",
+			Usage: "Path to your su3 signing private key",
+		},
+		&cli.StringFlag{
+			Name:  "signerSocket",
+			Value: "",
+			Usage: "Sign su3 files through the signing daemon on this Unix socket instead of loading --key (see the signer command)",
+		},
+		&cli.StringFlag{
+			Name:  "netdb",
+			Value: ndb,
+			Usage: "Path to NetDB directory containing routerInfos",
+		},
+		&cli.DurationFlag{
+			Name:  "routerInfoAge",
+			Value: 72 * time.Hour,
+			Usage: "Maximum age of router infos to include in reseed files (ex. 72h, 8d)",
+		},
+		&cli.StringFlag{
+			Name:  "tlsCert",
+			Usage: "Path to a TLS certificate",
+		},
+		&cli.StringFlag{
+			Name:  "tlsKey",
+			Usage: "Path to a TLS private key",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tlsAltHost",
+			Usage: "Another hostname to serve a certificate for, from host.crt and host.pem, picked by SNI. Can be repeated.",
+		},
+		&cli.DurationFlag{
+			Name:  "tlsWatch",
+			Value: time.Minute,
+			Usage: "How often to check the TLS certificate files for changes to reload. 0 only reloads them on SIGHUP.",
+		},
+		&cli.StringFlag{
+			Name:  "tlsProfile",
+			Value: "modern",
+			Usage: "TLS policy of the HTTPS listener: modern (TLS 1.3 only), intermediate (TLS 1.2 too, for older clients) or custom (from the flags below)",
+		},
+		&cli.StringFlag{
+			Name:  "tlsMinVersion",
+			Value: "1.2",
+			Usage: "Lowest TLS version of the custom TLS profile: 1.2 or 1.3",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tlsCipherSuites",
+			Usage: "TLS 1.2 cipher suite of the custom TLS profile, in order of preference, such as TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256. Can be repeated. Defaults to the Go defaults.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tlsCurves",
+			Usage: "Curve of the custom TLS profile, in order of preference: X25519, P-256, P-384, P-521 or X25519MLKEM768. Can be repeated. Defaults to the Go defaults.",
+		},
+		&cli.StringFlag{
+			Name:  "tlsTicketRotation",
+			Value: "auto",
+			Usage: "How often the custom TLS profile replaces the session ticket keys: auto (daily), off (no session tickets) or a duration",
+		},
+		&cli.StringFlag{
+			Name:  "ip",
+			Value: "0.0.0.0",
+			Usage: "IP address to listen on",
+		},
+		&cli.StringFlag{
+			Name:  "port",
+			Value: "8443",
+			Usage: "Port to listen on",
+		},
+		&cli.IntFlag{
+			Name:  "numRi",
+			Value: 77,
+			Usage: "Number of routerInfos to include in each su3 file",
+		},
+		&cli.IntFlag{
+			Name:  "numSu3",
+			Value: 50,
+			Usage: "Number of su3 files to build (0 = automatic based on size of netdb)",
+		},
+		&cli.IntFlag{
+			Name:  "netdbWorkers",
+			Value: runtime.NumCPU(),
+			Usage: "Number of workers used to read and parse routerInfos from the netDb",
+		},
+		&cli.IntFlag{
+			Name:  "su3Builders",
+			Value: 3,
+			Usage: "Number of su3 files to build in parallel",
+		},
+		&cli.StringFlag{
+			Name:  "interval",
+			Value: "90h",
+			Usage: "Duration between SU3 cache rebuilds (ex. 12h, 15m)",
+		},
+		&cli.BoolFlag{
+			Name:  "watch",
+			Usage: "Watch the netDb for changes and rebuild only the su3 files which need it",
+		},
+		&cli.BoolFlag{
+			Name:  "watchPoll",
+			Usage: "Watch the netDb by periodically rescanning it instead of using inotify",
+		},
+		&cli.DurationFlag{
+			Name:  "watchInterval",
+			Value: time.Minute,
+			Usage: "How often to check the netDb churn, and to rescan the netDb when polling or --su3Dir for changes",
+		},
+		&cli.BoolFlag{
+			Name:  "deterministic",
+			Usage: "Build reproducible su3 files from --seed and the logged time of each rebuild so they can be audited",
+		},
+		&cli.Int64Flag{
+			Name:  "seed",
+			Value: 0,
+			Usage: "Seed used to select routerInfos for deterministic builds",
+		},
+		&cli.StringFlag{
+			Name:  "transparencyLog",
+			Value: "",
+			Usage: "Record every published su3 file in an append-only transparency log at this path, and serve it under /transparency/",
+		},
+		&cli.IntFlag{
+			Name:  "churnThreshold",
+			Value: 100,
+			Usage: "Number of added, changed or expired routerInfos which triggers an incremental rebuild when watching",
+		},
+		&cli.StringFlag{
+			Name:  "su3Dir",
+			Value: "",
+			Usage: "Serve su3 files signed elsewhere from this directory instead of building them. The signing key is not needed.",
+		},
+		&cli.StringFlag{
+			Name:  "signerCert",
+			Value: "",
+			Usage: "Path to your su3 signing certificate, used to check the files in --su3Dir (default: signerID.crt)",
+		},
+		&cli.StringFlag{
+			Name:  "keystore",
+			Value: ".",
+			Usage: "Directory holding the signing key rotation recorded by 'keygen rotate'",
+		},
+		&cli.StringFlag{
+			Name:  "prefix",
+			Value: "",
+			Usage: "Prefix path for the HTTP(S) server. (ex. /netdb)",
+		},
+		&cli.BoolFlag{
+			Name:  "trustProxy",
+			Usage: "If provided, we will trust the 'X-Forwarded-For' header in requests (ex. behind cloudflare)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "blacklist",
+			Usage: "Path to a txt file of IP addresses, CIDR ranges and address ranges to deny connections from, one a line, such as an I2P blocklist.txt. Can be repeated.",
+		},
+		&cli.DurationFlag{
+			Name:  "stats",
+			Value: 0,
+			Usage: "Periodically print memory stats and the days left on each certificate.",
+		},
+		&cli.DurationFlag{
+			Name:  "shutdownTimeout",
+			Value: 30 * time.Second,
+			Usage: "How long to wait for downloads in progress to finish when stopping on SIGINT or SIGTERM",
+		},
+		&cli.DurationFlag{
+			Name:  "expiryCheck",
+			Value: 12 * time.Hour,
+			Usage: "How often to check the signer and TLS certificates for expiry while running",
+		},
+		&cli.BoolFlag{
+			Name:  "i2p",
+			Usage: "Listen for reseed request inside the I2P network",
+		},
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "Automatically answer 'yes' to self-signed SSL generation",
+		},
+		&cli.StringFlag{
+			Name:  "samaddr",
+			Value: "127.0.0.1:7656",
+			Usage: "Use this SAM address to set up I2P connections for in-network reseed",
+		},
+		&cli.StringFlag{
+			Name:  "unix",
+			Usage: "Also serve plain HTTP on a Unix domain socket at this path, for a reverse proxy on the same host. The client address is taken from X-Forwarded-For.",
+		},
+		&cli.StringSliceFlag{
+			Name:  "friends",
+			Value: cli.NewStringSlice(reseed.AllReseeds...),
+			Usage: "Ping other reseed servers and display the result on the homepage to provide information about reseed uptime.",
+		},
+		&cli.StringFlag{
+			Name:  "share-peer",
+			Value: "",
+			Usage: "Download the shared netDb content of another I2P router, over I2P",
+		},
+		&cli.StringFlag{
+			Name:  "share-password",
+			Value: "",
+			Usage: "Password for downloading netDb content from another router. Required for share-peer to work.",
+		},
+		&cli.BoolFlag{
+			Name:  "acme",
+			Usage: "Automatically generate a TLS certificate with the ACME protocol, defaults to Let's Encrypt",
+		},
+		&cli.StringFlag{
+			Name:  "acmeserver",
+			Value: "https://acme-staging-v02.api.letsencrypt.org/directory",
+			Usage: "Use this server to issue a certificate with the ACME protocol",
+		},
+		&cli.StringFlag{
+			Name:  "acmeChallenge",
+			Value: acmeHTTP01,
+			Usage: "ACME challenge to answer: http-01 (on --acmeHTTPPort), tls-alpn-01 (on the HTTPS port) or dns-01",
+		},
+		&cli.StringFlag{
+			Name:  "acmeHTTPPort",
+			Value: "8000",
+			Usage: "Port to answer http-01 challenges on, which port 80 must reach",
+		},
+		&cli.StringFlag{
+			Name:  "acmeDNSProvider",
+			Value: "exec",
+			Usage: "How dns-01 records are set: exec (runs EXEC_PATH) or rfc2136 (dynamic update of RFC2136_NAMESERVER)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "acmeDNSResolvers",
+			Usage: "Resolvers to check dns-01 records with, instead of the system's. Can be repeated.",
+		},
+		&cli.StringFlag{
+			Name:  "acmeKeyType",
+			Value: "rsa2048",
+			Usage: "Key type of ACME certificates: ec256, ec384, rsa2048 or rsa4096",
+		},
+		&cli.StringFlag{
+			Name:  "acmeEABKeyID",
+			Usage: "Key ID of the external account binding some CAs require to register an ACME account",
+		},
+		&cli.StringFlag{
+			Name:  "acmeEABHMACEnv",
+			Value: "RESEED_ACME_EAB_HMAC",
+			Usage: "Environment variable holding the base64url HMAC key of the external account binding",
+		},
+		&cli.DurationFlag{
+			Name:  "acmeRenewBefore",
+			Value: 30 * 24 * time.Hour,
+			Usage: "Renew the ACME certificate this long before it expires, while running",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimit",
+			Value: 4,
+			Usage: "Maximum number of reseed bundle requests per-IP address, per-hour.",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimitweb",
+			Value: 40,
+			Usage: "Maxiumum number of web-visits per-IP address, per-hour",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimitBurst",
+			Usage: "Maximum number of reseed bundle requests an address may make at once. 0 allows one, spreading them evenly over the hour.",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimitwebBurst",
+			Usage: "Maximum number of web-visits an address may make at once. 0 allows one, spreading them evenly over the hour.",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimitOnion",
+			Value: 120,
+			Usage: "Maximum number of reseed bundle requests and of web-visits per-hour shared by all onion peers, which Tor doesn't tell apart",
+		},
+		&cli.IntFlag{
+			Name:  "ratelimitOnionBurst",
+			Value: 10,
+			Usage: "Maximum number of reseed bundle requests and of web-visits the onion peers may make at once, so one of them can't spend the whole --ratelimitOnion. 0 allows one.",
+		},
+		&cli.BoolFlag{
+			Name:  "ratelimitByPrefix",
+			Usage: "Count the requests of each IPv4 /24 and IPv6 /64 together, rather than those of each address",
+		},
+		&cli.StringFlag{
+			Name:  "ratelimitStore",
+			Usage: "File to keep the rate limit state of each client in, so a restart doesn't reset them",
+		},
+		&cli.BoolFlag{
+			Name:  "encryptKeys",
+			Usage: "Encrypt newly generated private keys with a passphrase",
+		},
+		kdfFlag(),
+	}, passphraseFlags()...))
+}
+
 func CreateEepServiceKey(c *cli.Context) (i2pkeys.I2PKeys, error) {
 	sam, err := sam3.NewSAM(c.String("samaddr"))
 	if err != nil {
@@ -262,31 +456,46 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func reseedAction(c *cli.Context) error {
-	providedReseeds(c)
-	netdbDir := c.String("netdb")
-	if netdbDir == "" {
-		fmt.Println("--netdb is required")
-		return fmt.Errorf("--netdb is required")
-	}
-
+// signerIDFromFlags returns the --signer flag, which is either an email
+// address or a file containing one.
+func signerIDFromFlags(c *cli.Context) (string, error) {
 	signerID := c.String("signer")
 	if signerID == "" || signerID == "you@mail.i2p" {
 		fmt.Println("--signer is required")
-		return fmt.Errorf("--signer is required")
+		return "", fmt.Errorf("--signer is required")
 	}
 	if !strings.Contains(signerID, "@") {
 		if !fileExists(signerID) {
 			fmt.Println("--signer must be an email address or a file containing an email address.")
-			return fmt.Errorf("--signer must be an email address or a file containing an email address.")
+			return "", fmt.Errorf("--signer must be an email address or a file containing an email address.")
 		}
 		bytes, err := ioutil.ReadFile(signerID)
 		if err != nil {
 			fmt.Println("--signer must be an email address or a file containing an email address.")
-			return fmt.Errorf("--signer must be an email address or a file containing an email address.")
+			return "", fmt.Errorf("--signer must be an email address or a file containing an email address.")
 		}
 		signerID = string(bytes)
 	}
+	return signerID, nil
+}
+
+func reseedAction(c *cli.Context) error {
+	providedReseeds(c)
+	netdbDir := c.String("netdb")
+	if netdbDir == "" && c.String("su3Dir") == "" {
+		fmt.Println("--netdb is required")
+		return fmt.Errorf("--netdb is required")
+	}
+
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+	// one passphrase unlocks, or encrypts, all of the keys
+	kp := keyProtectionFromFlags(c, c.Bool("encryptKeys"))
+	// the background work runs until the servers have stopped
+	work, stopWork := context.WithCancel(context.Background())
+	defer stopWork()
 	if c.String("share-peer") != "" {
 		count := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
 		for i := range count {
@@ -298,7 +507,12 @@ func reseedAction(c *cli.Context) error {
 				break
 			}
 		}
-		go getSupplementalNetDb(c.String("share-peer"), c.String("share-password"), c.String("netdb"), c.String("samaddr"))
+		go getSupplementalNetDb(work, c.String("share-peer"), c.String("share-password"), c.String("netdb"), c.String("samaddr"))
+	}
+
+	profile, err := tlsProfile(c)
+	if nil != err {
+		log.Fatalln(err)
 	}
 
 	var tlsCert, tlsKey string
@@ -308,6 +522,8 @@ func reseedAction(c *cli.Context) error {
 	i2pTlsHost := ""
 	var i2pTlsCert, i2pTlsKey string
 	var i2pkey i2pkeys.I2PKeys
+	var acme *acmeIssuer
+	certs := reseed.NewCertificateStore(kp.passphrase())
 
 	if tlsHost != "" {
 		onionTlsHost = tlsHost
@@ -333,15 +549,17 @@ func reseedAction(c *cli.Context) error {
 		ignore := c.Bool("trustProxy")
 		if !ignore {
 			// use ACME?
-			acme := c.Bool("acme")
-			if acme {
-				acmeserver := c.String("acmeserver")
-				err := checkUseAcmeCert(tlsHost, signerID, acmeserver, &tlsCert, &tlsKey, auto)
+			if c.Bool("acme") {
+				var err error
+				acme, err = newAcmeIssuer(c, tlsHost, signerID, tlsCert, tlsKey, kp, certs)
 				if nil != err {
 					log.Fatalln(err)
 				}
+				if err := checkUseAcmeCert(acme, auto, c.Duration("acmeRenewBefore")); nil != err {
+					log.Fatalln(err)
+				}
 			} else {
-				err := checkOrNewTLSCert(tlsHost, &tlsCert, &tlsKey, auto)
+				err := checkOrNewTLSCert(tlsHost, &tlsCert, &tlsKey, auto, kp)
 				if nil != err {
 					log.Fatalln(err)
 				}
@@ -350,6 +568,16 @@ func reseedAction(c *cli.Context) error {
 
 	}
 
+	// certificates of other hostnames, picked by SNI
+	if !c.Bool("trustProxy") {
+		for _, host := range c.StringSlice("tlsAltHost") {
+			cert, key := host+".crt", host+".pem"
+			if err := checkOrNewTLSCert(host, &cert, &key, c.Bool("yes"), kp); nil != err {
+				log.Fatalln(err)
+			}
+		}
+	}
+
 	if c.Bool("i2p") {
 		var err error
 		i2pkey, err = LoadKeys("reseed.i2pkeys", c)
@@ -374,7 +602,7 @@ func reseedAction(c *cli.Context) error {
 			auto := c.Bool("yes")
 			ignore := c.Bool("trustProxy")
 			if !ignore {
-				err := checkOrNewTLSCert(i2pTlsHost, &i2pTlsCert, &i2pTlsKey, auto)
+				err := checkOrNewTLSCert(i2pTlsHost, &i2pTlsCert, &i2pTlsKey, auto, kp)
 				if nil != err {
 					log.Fatalln(err)
 				}
@@ -419,7 +647,7 @@ func reseedAction(c *cli.Context) error {
 			auto := c.Bool("yes")
 			ignore := c.Bool("trustProxy")
 			if !ignore {
-				err := checkOrNewTLSCert(onionTlsHost, &onionTlsCert, &onionTlsKey, auto)
+				err := checkOrNewTLSCert(onionTlsHost, &onionTlsCert, &onionTlsKey, auto, kp)
 				if nil != err {
 					log.Fatalln(err)
 				}
@@ -427,255 +655,444 @@ func reseedAction(c *cli.Context) error {
 		}
 	}
 
-	reloadIntvl, err := time.ParseDuration(c.String("interval"))
-	if nil != err {
-		fmt.Printf("'%s' is not a valid time interval.\n", reloadIntvl)
-		return fmt.Errorf("'%s' is not a valid time interval.\n", reloadIntvl)
-	}
+	// SIGINT and SIGTERM stop the servers, and then the work behind them
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	signerKey := c.String("key")
-	// if no key is specified, default to the signerID.pem in the current dir
-	if signerKey == "" {
-		signerKey = signerFile(signerID) + ".pem"
+	var reseeder startableReseeder
+	if su3Dir := c.String("su3Dir"); su3Dir != "" {
+		reseeder, err = newDirReseeder(c, su3Dir, signerID)
+	} else {
+		reseeder, err = newNetDbReseeder(work, c, netdbDir, signerID, kp)
 	}
-
-	// load our signing privKey
-	auto := c.Bool("yes")
-	privKey, err := getOrNewSigningCert(&signerKey, signerID, auto)
 	if nil != err {
 		log.Fatalln(err)
 	}
+	reseederDone := reseeder.Start(work)
 
-	// create a local file netdb provider
-	routerInfoAge := c.Duration("routerInfoAge")
-	netdb := reseed.NewLocalNetDb(netdbDir, routerInfoAge)
-
-	// create a reseeder
-	reseeder := reseed.NewReseeder(netdb)
-	reseeder.SigningKey = privKey
-	reseeder.SignerID = []byte(signerID)
-	reseeder.NumRi = c.Int("numRi")
-	reseeder.NumSu3 = c.Int("numSu3")
-	reseeder.RebuildInterval = reloadIntvl
-	reseeder.Start()
+	// one server, with one blacklist, rate limit store, handler chain and
+	// stats, serves every listener
+	blacklist := reseed.NewBlacklist()
+	if blacklistFiles := c.StringSlice("blacklist"); len(blacklistFiles) > 0 {
+		stats, err := blacklist.LoadFiles(blacklistFiles...)
+		if nil != err {
+			log.Println(err)
+		}
+		log.Printf("Blacklist: %s\n", stats)
+	}
+	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
+	server.Reseeder = reseeder
+	server.Blacklist = blacklist
+	server.SetRateLimits(c.Int("ratelimit"), c.Int("ratelimitBurst"), c.Int("ratelimitweb"), c.Int("ratelimitwebBurst"))
+	server.SharedRateLimit = c.Int("ratelimitOnion")
+	server.SharedRateBurst = c.Int("ratelimitOnionBurst")
+	server.RateLimitByPrefix = c.Bool("ratelimitByPrefix")
+	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+	rateLimitStore := c.String("ratelimitStore")
+	if rateLimitStore != "" {
+		store, err := reseed.LoadRateLimitStore(rateLimitStore, 400000)
+		if nil != err {
+			log.Fatalln(err)
+		}
+		log.Printf("Loaded the rate limits of %d clients from %s\n", store.Len(), rateLimitStore)
+		server.RateLimitStore = store
+		store.Persist(work, rateLimitStore, time.Minute)
+	}
 
-	// create a server
+	// watch the certificates, and print stats once in a while
+	expiry := newExpiryMonitor(c, signerID, tlsHost, tlsCert, onionTlsCert, i2pTlsCert, acme)
+	expiry.Start(work)
+	if c.Duration("stats") != 0 {
+		go logStats(work, c.Duration("stats"), expiry, server)
+	}
 
+	// start the listeners. Without a TLS certificate, only the onion, I2P
+	// and Unix socket listeners are started when they are asked for.
+	servers := newServerGroup()
+	unixSocket := c.String("unix")
+	hiddenOnly := (c.Bool("onion") || c.Bool("i2p") || unixSocket != "") && (tlsHost == "" || tlsCert == "" || tlsKey == "")
 	if c.Bool("onion") {
 		log.Printf("Onion server starting\n")
-		if tlsHost != "" && tlsCert != "" && tlsKey != "" {
-			go reseedOnion(c, onionTlsCert, onionTlsKey, reseeder)
-		} else {
-			reseedOnion(c, onionTlsCert, onionTlsKey, reseeder)
+		if err := reseedOnion(c, servers, server, onionTlsCert, onionTlsKey); nil != err {
+			log.Fatalln(err)
 		}
 	}
 	if c.Bool("i2p") {
 		log.Printf("I2P server starting\n")
-		if tlsHost != "" && tlsCert != "" && tlsKey != "" {
-			go reseedI2P(c, i2pTlsCert, i2pTlsKey, i2pkey, reseeder)
+		if err := reseedI2P(c, servers, server, i2pTlsCert, i2pTlsKey, i2pkey); nil != err {
+			log.Fatalln(err)
+		}
+	}
+	if unixSocket != "" {
+		log.Printf("HTTP server started on Unix socket %s\n", unixSocket)
+		servers.serve("Unix", server, func() error {
+			return server.ListenAndServeUnix(unixSocket)
+		})
+	}
+	if !hiddenOnly {
+		if !c.Bool("trustProxy") {
+			log.Printf("HTTPS server starting\n")
+			if err := reseedHTTPS(work, c, servers, server, tlsCert, tlsKey, certs, profile); nil != err {
+				log.Fatalln(err)
+			}
 		} else {
-			reseedI2P(c, i2pTlsCert, i2pTlsKey, i2pkey, reseeder)
+			log.Printf("HTTP server starting on\n")
+			reseedHTTP(servers, server)
 		}
 	}
-	if !c.Bool("trustProxy") {
-		log.Printf("HTTPS server starting\n")
-		reseedHTTPS(c, tlsCert, tlsKey, reseeder)
-	} else {
-		log.Printf("HTTP server starting on\n")
-		reseedHTTP(c, reseeder)
+
+	// reload the settings which can change while running on SIGHUP
+	r := &reloader{
+		started:   c,
+		current:   c,
+		certs:     certs,
+		expiry:    expiry,
+		blacklist: blacklist,
+		reseeder:  reseeder,
+		servers:   servers,
 	}
-	return nil
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				r.reload()
+			case <-work.Done():
+				return
+			}
+		}
+	}()
+
+	// until told to stop, or a server fails
+	err = servers.wait(ctx, c.Duration("shutdownTimeout"))
+	stop()
+	if store, ok := server.RateLimitStore.(*reseed.RateLimitStore); ok && rateLimitStore != "" {
+		if err := store.Save(rateLimitStore); nil != err {
+			log.Println("Saving the rate limits:", err)
+		}
+	}
+
+	// stop rebuilding, after the rebuild in progress is published
+	stopWork()
+	<-reseederDone
+	log.Println("Stopped")
+	return err
 }
 
-func reseedHTTPS(c *cli.Context, tlsCert, tlsKey string, reseeder *reseed.ReseederImpl) {
-	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
-	server.Reseeder = reseeder
-	server.RequestRateLimit = c.Int("ratelimit")
-	server.WebRateLimit = c.Int("ratelimitweb")
-	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+// startableReseeder is a reseeder which keeps its su3 set fresh from Start
+// until the context is done.
+type startableReseeder interface {
+	reseed.Reseeder
+	Start(ctx context.Context) <-chan struct{}
+}
 
-	// load a blacklist
-	blacklist := reseed.NewBlacklist()
-	server.Blacklist = blacklist
-	blacklistFile := c.String("blacklist")
-	if "" != blacklistFile {
-		blacklist.LoadFile(blacklistFile)
+// newNetDbReseeder creates a reseeder which builds and signs su3 files from
+// the local netDb, watched until ctx is done.
+func newNetDbReseeder(ctx context.Context, c *cli.Context, netdbDir, signerID string, kp *keyProtection) (*reseed.ReseederImpl, error) {
+	reloadIntvl, err := time.ParseDuration(c.String("interval"))
+	if nil != err {
+		fmt.Printf("'%s' is not a valid time interval.\n", reloadIntvl)
+		return nil, fmt.Errorf("'%s' is not a valid time interval.\n", reloadIntvl)
 	}
 
-	// print stats once in a while
-	if c.Duration("stats") != 0 {
-		go func() {
-			var mem runtime.MemStats
-			for range time.Tick(c.Duration("stats")) {
-				runtime.ReadMemStats(&mem)
-				log.Printf("TotalAllocs: %d Kb, Allocs: %d Kb, Mallocs: %d, NumGC: %d", mem.TotalAlloc/1024, mem.Alloc/1024, mem.Mallocs, mem.NumGC)
-			}
-		}()
+	rotation, err := loadRotation(c.String("keystore"), signerID)
+	if nil != err {
+		return nil, err
 	}
-	log.Printf("HTTPS server started on %s\n", server.Addr)
-	if err := server.ListenAndServeTLS(tlsCert, tlsKey); err != nil {
-		log.Fatalln(err)
+
+	// load our signing privKey, or reach the daemon holding it
+	var signer, nextSigner crypto.Signer
+	if socket := c.String("signerSocket"); socket != "" {
+		remote, err := reseed.DialSigner(socket)
+		if nil != err {
+			return nil, err
+		}
+		log.Printf("Signing through the signing daemon on %s\n", socket)
+		signer = remote
+		if rotation != nil && time.Now().Before(rotation.Cutover) {
+			// the daemon holds a single key, and the successor key stays
+			// with it
+			log.Printf("The signing daemon keeps its key at the cutover at %s: restart it and the reseed server with %s then\n", rotation.Cutover.UTC().Format(time.RFC3339), rotation.Next.Key)
+		}
+	} else if rotation != nil && c.String("key") == "" {
+		active := rotation.Active(time.Now())
+		if signer, err = loadPrivateKey(active.Key, kp.passphrase()); nil != err {
+			return nil, err
+		}
+		log.Printf("Signing with %s (%s)\n", active.Key, active.Fingerprint)
+		if time.Now().Before(rotation.Cutover) {
+			if nextSigner, err = loadPrivateKey(rotation.Next.Key, kp.passphrase()); nil != err {
+				return nil, err
+			}
+		}
+	} else {
+		signerKey := c.String("key")
+		// if no key is specified, default to the signerID.pem in the current dir
+		if signerKey == "" {
+			signerKey = signerFile(signerID) + ".pem"
+		}
+
+		auto := c.Bool("yes")
+		privKey, err := getOrNewSigningCert(&signerKey, signerID, auto, kp)
+		if nil != err {
+			return nil, err
+		}
+		signer = privKey
 	}
-}
 
-func reseedHTTP(c *cli.Context, reseeder *reseed.ReseederImpl) {
-	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
-	server.RequestRateLimit = c.Int("ratelimit")
-	server.WebRateLimit = c.Int("ratelimitweb")
-	server.Reseeder = reseeder
-	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+	// create a local file netdb provider
+	routerInfoAge := c.Duration("routerInfoAge")
+	netdb := reseed.NewLocalNetDb(netdbDir, routerInfoAge)
+	netdb.Workers = c.Int("netdbWorkers")
 
-	// load a blacklist
-	blacklist := reseed.NewBlacklist()
-	server.Blacklist = blacklist
-	blacklistFile := c.String("blacklist")
-	if "" != blacklistFile {
-		blacklist.LoadFile(blacklistFile)
+	// create a reseeder
+	reseeder := reseed.NewReseeder(netdb)
+	reseeder.Signer = signer
+	if nextSigner != nil {
+		reseeder.NextSigner = nextSigner
+		reseeder.Cutover = rotation.Cutover
+	}
+	reseeder.SignerID = []byte(signerID)
+	reseeder.NumRi = c.Int("numRi")
+	reseeder.NumSu3 = c.Int("numSu3")
+	reseeder.RebuildInterval = reloadIntvl
+	reseeder.NumBuilders = c.Int("su3Builders")
+	reseeder.Deterministic = c.Bool("deterministic")
+	reseeder.Seed = c.Int64("seed")
+	if logPath := c.String("transparencyLog"); logPath != "" {
+		reseeder.TransparencyLog, err = reseed.OpenTransparencyLog(logPath, signer, []byte(signerID))
+		if nil != err {
+			return nil, err
+		}
+		log.Printf("Transparency log %s has %d entries\n", logPath, reseeder.TransparencyLog.Size())
+	}
+	if c.Bool("watch") {
+		netdb.Watch(ctx, c.Bool("watchPoll"), c.Duration("watchInterval"))
+		reseeder.ChurnThreshold = c.Int("churnThreshold")
+		reseeder.CheckInterval = c.Duration("watchInterval")
 	}
 
-	// print stats once in a while
-	if c.Duration("stats") != 0 {
-		go func() {
-			var mem runtime.MemStats
-			for range time.Tick(c.Duration("stats")) {
-				runtime.ReadMemStats(&mem)
-				log.Printf("TotalAllocs: %d Kb, Allocs: %d Kb, Mallocs: %d, NumGC: %d", mem.TotalAlloc/1024, mem.Alloc/1024, mem.Mallocs, mem.NumGC)
-			}
-		}()
+	return reseeder, nil
+}
+
+// newDirReseeder creates a reseeder which serves su3 files built
+// and signed on another host. Only the signer certificate is needed here.
+func newDirReseeder(c *cli.Context, su3Dir, signerID string) (*reseed.DirReseeder, error) {
+	certPath := c.String("signerCert")
+	// if no certificate is specified, default to the signerID.crt in the current dir
+	if certPath == "" {
+		certPath = signerFile(signerID) + ".crt"
 	}
-	log.Printf("HTTP server started on %s\n", server.Addr)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalln(err)
+	cert, err := loadCertificate(certPath)
+	if nil != err {
+		return nil, fmt.Errorf("unable to load signer certificate '%s': %s", certPath, err)
 	}
+
+	reseeder := reseed.NewDirReseeder(su3Dir, []byte(signerID), cert)
+	reseeder.CheckInterval = c.Duration("watchInterval")
+
+	return reseeder, nil
 }
 
-func reseedOnion(c *cli.Context, onionTlsCert, onionTlsKey string, reseeder *reseed.ReseederImpl) {
-	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
-	server.Reseeder = reseeder
-	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+// newExpiryMonitor creates a monitor for the signer certificate and the TLS
+// certificates the servers will use. An ACME certificate is renewed by the
+// monitor before it expires.
+func newExpiryMonitor(c *cli.Context, signerID, tlsHost, tlsCert, onionTlsCert, i2pTlsCert string, acme *acmeIssuer) *reseed.ExpiryMonitor {
+	monitor := reseed.NewExpiryMonitor()
+	monitor.Interval = c.Duration("expiryCheck")
+	monitor.RenewBefore = c.Duration("acmeRenewBefore")
+	add := func(name, path string, renew func() error) {
+		if path == "" {
+			return
+		}
+		if _, err := os.Stat(path); nil != err {
+			return
+		}
+		monitor.Add(name, path, renew)
+	}
 
-	// load a blacklist
-	blacklist := reseed.NewBlacklist()
-	server.Blacklist = blacklist
-	blacklistFile := c.String("blacklist")
-	if "" != blacklistFile {
-		blacklist.LoadFile(blacklistFile)
+	rotation, err := loadRotation(c.String("keystore"), signerID)
+	switch {
+	case c.String("su3Dir") != "" && c.String("signerCert") != "":
+		add("signer certificate", c.String("signerCert"), nil)
+	case nil == err && rotation != nil && c.String("key") == "":
+		add("signer certificate", rotation.Active(time.Now()).Certificate, nil)
+		if time.Now().Before(rotation.Cutover) {
+			add("next signer certificate", rotation.Next.Certificate, nil)
+		}
+	default:
+		add("signer certificate", signerFile(signerID)+".crt", nil)
 	}
 
-	// print stats once in a while
-	if c.Duration("stats") != 0 {
-		go func() {
-			var mem runtime.MemStats
-			for range time.Tick(c.Duration("stats")) {
-				runtime.ReadMemStats(&mem)
-				log.Printf("TotalAllocs: %d Kb, Allocs: %d Kb, Mallocs: %d, NumGC: %d", mem.TotalAlloc/1024, mem.Alloc/1024, mem.Mallocs, mem.NumGC)
-			}
-		}()
+	if c.Bool("trustProxy") {
+		return monitor
 	}
-	port, err := strconv.Atoi(c.String("port"))
-	if err != nil {
-		log.Fatalln(err.Error())
+	if tlsHost != "" {
+		var renew func() error
+		if acme != nil {
+			renew = acme.renew
+		}
+		add("TLS certificate "+tlsHost, tlsCert, renew)
 	}
-	port += 1
-	if _, err := os.Stat(c.String("onionKey")); err == nil {
-		ok, err := ioutil.ReadFile(c.String("onionKey"))
-		if err != nil {
-			log.Fatalln(err.Error())
-		} else {
-			if onionTlsCert != "" && onionTlsKey != "" {
-				tlc := &tor.ListenConf{
-					LocalPort:    port,
-					Key:          ed25519.PrivateKey(ok),
-					RemotePorts:  []int{443},
-					Version3:     true,
-					NonAnonymous: c.Bool("singleOnion"),
-					DiscardKey:   false,
-				}
-				if err := server.ListenAndServeOnionTLS(nil, tlc, onionTlsCert, onionTlsKey); err != nil {
-					log.Fatalln(err)
-				}
-			} else {
-				tlc := &tor.ListenConf{
-					LocalPort:    port,
-					Key:          ed25519.PrivateKey(ok),
-					RemotePorts:  []int{80},
-					Version3:     true,
-					NonAnonymous: c.Bool("singleOnion"),
-					DiscardKey:   false,
-				}
-				if err := server.ListenAndServeOnion(nil, tlc); err != nil {
-					log.Fatalln(err)
-				}
+	for _, host := range c.StringSlice("tlsAltHost") {
+		add("TLS certificate "+host, host+".crt", nil)
+	}
+	if c.Bool("onion") {
+		add("onion TLS certificate", onionTlsCert, nil)
+	}
+	if c.Bool("i2p") {
+		add("I2P TLS certificate", i2pTlsCert, nil)
+	}
+	return monitor
+}
 
+// logStats prints memory stats, the days left on each certificate and what
+// each listener served every interval, until ctx is done.
+func logStats(ctx context.Context, interval time.Duration, expiry *reseed.ExpiryMonitor, server *reseed.Server) {
+	var mem runtime.MemStats
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			runtime.ReadMemStats(&mem)
+			log.Printf("TotalAllocs: %d Kb, Allocs: %d Kb, Mallocs: %d, NumGC: %d, Certificates: %s", mem.TotalAlloc/1024, mem.Alloc/1024, mem.Mallocs, mem.NumGC, expiry.Summary())
+			for _, stats := range server.Stats() {
+				log.Printf("%s: %d requests, %d su3 files served, %d connections blacklisted", stats.Name, stats.Requests, stats.Su3Served, stats.Blocked)
 			}
-		}
-	} else if os.IsNotExist(err) {
-		tlc := &tor.ListenConf{
-			LocalPort:    port,
-			RemotePorts:  []int{80},
-			Version3:     true,
-			NonAnonymous: c.Bool("singleOnion"),
-			DiscardKey:   false,
-		}
-		if err := server.ListenAndServeOnion(nil, tlc); err != nil {
-			log.Fatalln(err)
+		case <-ctx.Done():
+			return
 		}
 	}
-	log.Printf("Onion server started on %s\n", server.Addr)
 }
 
-func reseedI2P(c *cli.Context, i2pTlsCert, i2pTlsKey string, i2pIdentKey i2pkeys.I2PKeys, reseeder *reseed.ReseederImpl) {
-	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
-	server.RequestRateLimit = c.Int("ratelimit")
-	server.WebRateLimit = c.Int("ratelimitweb")
-	server.Reseeder = reseeder
-	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+// parseBuildTime parses a deterministic build time given as unix seconds or
+// as an RFC3339 timestamp. An empty string is the zero time.
+func parseBuildTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is not a valid build time: %s", value, err)
+	}
+	return t, nil
+}
 
-	// load a blacklist
-	blacklist := reseed.NewBlacklist()
-	server.Blacklist = blacklist
-	blacklistFile := c.String("blacklist")
-	if "" != blacklistFile {
-		blacklist.LoadFile(blacklistFile)
+// tlsProfile returns the TLS profile chosen with --tlsProfile, or built from
+// the custom profile flags.
+func tlsProfile(c *cli.Context) (reseed.TLSProfile, error) {
+	name := c.String("tlsProfile")
+	if name == "custom" {
+		return reseed.NewCustomTLSProfile(c.String("tlsMinVersion"), c.StringSlice("tlsCipherSuites"), c.StringSlice("tlsCurves"), c.String("tlsTicketRotation"))
 	}
+	for _, flag := range []string{"tlsMinVersion", "tlsCipherSuites", "tlsCurves", "tlsTicketRotation"} {
+		if c.IsSet(flag) {
+			return reseed.TLSProfile{}, fmt.Errorf("--%s needs --tlsProfile=custom", flag)
+		}
+	}
+	return reseed.TLSProfileByName(name)
+}
 
-	// print stats once in a while
-	if c.Duration("stats") != 0 {
-		go func() {
-			var mem runtime.MemStats
-			for range time.Tick(c.Duration("stats")) {
-				runtime.ReadMemStats(&mem)
-				log.Printf("TotalAllocs: %d Kb, Allocs: %d Kb, Mallocs: %d, NumGC: %d", mem.TotalAlloc/1024, mem.Alloc/1024, mem.Mallocs, mem.NumGC)
-			}
-		}()
+func reseedHTTPS(ctx context.Context, c *cli.Context, servers *serverGroup, server *reseed.Server, tlsCert, tlsKey string, certs *reseed.CertificateStore, profile reseed.TLSProfile) error {
+	server.KeyPassphrase = certs.Passphrase
+	server.Certificates = certs
+	server.UseTLSProfile(profile)
+
+	// the first certificate is served to clients which don't ask for one
+	// of the others by name
+	if err := certs.Add(tlsCert, tlsKey); err != nil {
+		return err
+	}
+	for _, host := range c.StringSlice("tlsAltHost") {
+		if err := certs.Add(host+".crt", host+".pem"); err != nil {
+			return err
+		}
+	}
+	if c.Duration("tlsWatch") != 0 {
+		certs.Watch(ctx, c.Duration("tlsWatch"))
 	}
+
+	log.Printf("TLS profile %s\n", profile)
+	log.Printf("HTTPS server started on %s\n", server.Addr)
+	servers.serve("HTTPS", server, func() error {
+		return server.ListenAndServeTLS("", "")
+	})
+	return nil
+}
+
+func reseedHTTP(servers *serverGroup, server *reseed.Server) {
+	log.Printf("HTTP server started on %s\n", server.Addr)
+	servers.serve("HTTP", server, server.ListenAndServe)
+}
+
+func reseedOnion(c *cli.Context, servers *serverGroup, server *reseed.Server, onionTlsCert, onionTlsKey string) error {
 	port, err := strconv.Atoi(c.String("port"))
 	if err != nil {
-		log.Fatalln(err.Error())
+		return err
 	}
 	port += 1
-	if i2pTlsCert != "" && i2pTlsKey != "" {
-		if err := server.ListenAndServeI2PTLS(c.String("samaddr"), i2pIdentKey, i2pTlsCert, i2pTlsKey); err != nil {
-			log.Fatalln(err)
+	tlc := &tor.ListenConf{
+		LocalPort:    port,
+		RemotePorts:  []int{80},
+		Version3:     true,
+		NonAnonymous: c.Bool("singleOnion"),
+		DiscardKey:   false,
+	}
+	if _, err := os.Stat(c.String("onionKey")); err == nil {
+		ok, err := ioutil.ReadFile(c.String("onionKey"))
+		if err != nil {
+			return err
 		}
-	} else {
-		if err := server.ListenAndServeI2P(c.String("samaddr"), i2pIdentKey); err != nil {
-			log.Fatalln(err)
+		tlc.Key = ed25519.PrivateKey(ok)
+		if onionTlsCert != "" && onionTlsKey != "" {
+			tlc.RemotePorts = []int{443}
+			servers.serve("Onion", server, func() error {
+				return server.ListenAndServeOnionTLS(nil, tlc, onionTlsCert, onionTlsKey)
+			})
+			return nil
 		}
+	} else if !os.IsNotExist(err) {
+		return err
 	}
+	servers.serve("Onion", server, func() error {
+		return server.ListenAndServeOnion(nil, tlc)
+	})
+	return nil
+}
 
-	log.Printf("Onion server started on %s\n", server.Addr)
+func reseedI2P(c *cli.Context, servers *serverGroup, server *reseed.Server, i2pTlsCert, i2pTlsKey string, i2pIdentKey i2pkeys.I2PKeys) error {
+	if i2pTlsCert != "" && i2pTlsKey != "" {
+		servers.serve("I2P", server, func() error {
+			return server.ListenAndServeI2PTLS(c.String("samaddr"), i2pIdentKey, i2pTlsCert, i2pTlsKey)
+		})
+		return nil
+	}
+	servers.serve("I2P", server, func() error {
+		return server.ListenAndServeI2P(c.String("samaddr"), i2pIdentKey)
+	})
+	return nil
 }
 
-func getSupplementalNetDb(remote, password, path, samaddr string) {
+func getSupplementalNetDb(ctx context.Context, remote, password, path, samaddr string) {
 	log.Println("Remote NetDB Update Loop")
 	for {
+		wait := time.Minute * 30
 		if err := downloadRemoteNetDB(remote, password, path, samaddr); err != nil {
 			log.Println("Error downloading remote netDb", err)
-			time.Sleep(time.Second * 30)
+			wait = time.Second * 30
 		} else {
 			log.Println("Success downloading remote netDb", err)
-			time.Sleep(time.Minute * 30)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
 		}
 	}
 }
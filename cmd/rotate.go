@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// newKeygenRotateCommand creates the 'keygen rotate' command, which creates
+// the successor of a signing key and schedules the switch to it.
+func newKeygenRotateCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "rotate",
+		Usage:  "Create a successor su3 signing key and schedule the switch to it",
+		Action: keygenRotateAction,
+		Flags: append(append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your current su3 signing private key (default: signerID.pem)",
+			},
+			&cli.StringFlag{
+				Name:  "signerCert",
+				Usage: "Path to your current su3 signing certificate (default: signerID.crt)",
+			},
+			&cli.StringFlag{
+				Name:  "cutover",
+				Usage: "When to switch to the new key, as unix seconds, an RFC3339 timestamp or a duration from now (ex. 2160h). Leave enough time for the certificate to ship with I2P.",
+			},
+			&cli.StringFlag{
+				Name:  "keystore",
+				Value: ".",
+				Usage: "Directory to record the key rotation in, which the reseed server reads it from",
+			},
+			&cli.StringFlag{
+				Name:  "bundle",
+				Usage: "Directory to write the certificate bundle to submit to the I2P developers to (default: signerID-rotation)",
+			},
+			&cli.BoolFlag{
+				Name:  "force",
+				Usage: "Replace a successor key which hasn't been switched to yet",
+			},
+			&cli.BoolFlag{
+				Name:  "encrypt",
+				Usage: "Encrypt the new private key with a passphrase",
+			},
+			kdfFlag(),
+		}, certificateFlags()...), passphraseFlags()...),
+	}
+}
+
+func keygenRotateAction(c *cli.Context) error {
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+	now := time.Now()
+	cutover, err := parseCutover(c.String("cutover"), now)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+
+	ks := reseed.NewKeyStore(c.String("keystore"))
+	current, err := currentRotationKey(c, ks, signerID, now)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if !cutover.Before(current.NotAfter) {
+		fmt.Printf("Warning: the current certificate expires at %s, before the cutover\n", current.NotAfter.Format(time.RFC3339))
+	}
+
+	// name the successor after the day it was made, so rotations don't
+	// overwrite each other
+	base := signerFile(signerID) + "." + now.UTC().Format("20060102")
+	if fileExists(base+".pem") || fileExists(base+".crt") {
+		err := fmt.Errorf("%s.pem or %s.crt already exists", base, base)
+		fmt.Println(err)
+		return err
+	}
+	opts, err := keygenOptionsFromFlags(c)
+	if nil != err {
+		fmt.Println(err)
+		return err
+	}
+	if err := createSigningCertificateFiles(signerID, base, keyProtectionFromFlags(c, c.Bool("encrypt")), opts); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	nextCert, err := loadCertificate(base + ".crt")
+	if nil != err {
+		return err
+	}
+	next, err := reseed.NewRotationKey(base+".pem", base+".crt", nextCert)
+	if nil != err {
+		return err
+	}
+
+	rotation := &reseed.KeyRotation{
+		SignerID: signerID,
+		Current:  current,
+		Next:     next,
+		Cutover:  cutover.UTC(),
+		Created:  now.UTC().Truncate(time.Second),
+	}
+	if err := ks.SaveRotation(rotation); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("\tKey rotation recorded in:", ks.RotationPath(signerID))
+
+	bundle := c.String("bundle")
+	if bundle == "" {
+		bundle = signerFile(signerID) + "-rotation"
+	}
+	if err := writeRotationBundle(bundle, rotation, nextCert); nil != err {
+		fmt.Println(err)
+		return err
+	}
+	fmt.Println("\tCertificate bundle to submit saved to:", bundle)
+	fmt.Printf("The reseed server switches to the new key at %s\n", rotation.Cutover.Format(time.RFC3339))
+	return nil
+}
+
+// parseCutover parses a cutover time given as unix seconds, an RFC3339
+// timestamp, or a duration from now.
+func parseCutover(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("--cutover is required")
+	}
+	var cutover time.Time
+	if d, err := time.ParseDuration(value); err == nil {
+		cutover = now.Add(d)
+	} else if cutover, err = parseBuildTime(value); nil != err {
+		return time.Time{}, fmt.Errorf("'%s' is not a valid cutover time", value)
+	}
+	if !cutover.After(now) {
+		return time.Time{}, fmt.Errorf("the cutover %s is not in the future", cutover.UTC().Format(time.RFC3339))
+	}
+	return cutover, nil
+}
+
+// currentRotationKey returns the key the reseeder signs with now: the active
+// key of an earlier rotation, or else the one given by the flags.
+func currentRotationKey(c *cli.Context, ks *reseed.KeyStore, signerID string, now time.Time) (reseed.RotationKey, error) {
+	previous, err := loadRotation(ks.Path, signerID)
+	if nil != err {
+		return reseed.RotationKey{}, err
+	}
+	if previous != nil {
+		if now.Before(previous.Cutover) && !c.Bool("force") {
+			return reseed.RotationKey{}, fmt.Errorf("a switch to %s is already scheduled for %s, use --force to replace it", previous.Next.Certificate, previous.Cutover.Format(time.RFC3339))
+		}
+		return previous.Active(now), nil
+	}
+
+	signerKey := c.String("key")
+	if signerKey == "" {
+		signerKey = signerFile(signerID) + ".pem"
+	}
+	certFile := c.String("signerCert")
+	if certFile == "" {
+		certFile = signerFile(signerID) + ".crt"
+	}
+	if !fileExists(signerKey) {
+		return reseed.RotationKey{}, fmt.Errorf("Unable to read signing key '%s', create one with 'keygen --signer' first", signerKey)
+	}
+	cert, err := loadCertificate(certFile)
+	if nil != err {
+		return reseed.RotationKey{}, err
+	}
+	return reseed.NewRotationKey(signerKey, certFile, cert)
+}
+
+// writeRotationBundle writes the submission bundle of the successor
+// certificate, noting which certificate it replaces and the cutover.
+func writeRotationBundle(dir string, rotation *reseed.KeyRotation, cert *x509.Certificate) error {
+	return writeSubmissionBundle(dir, rotation.SignerID, cert, []string{
+		fmt.Sprintf("Replaces SHA-256:     %s", rotation.Current.Fingerprint),
+		fmt.Sprintf("Replaced valid until: %s", rotation.Current.NotAfter.Format(time.RFC3339)),
+		fmt.Sprintf("Cutover:              %s", rotation.Cutover.Format(time.RFC3339)),
+		"",
+		"Until the cutover this reseed server signs with the replaced key, and after it with the new one.",
+	})
+}
+
+// loadRotation returns the key rotation recorded for signerID in keystore,
+// or nil if there is none.
+func loadRotation(keystore, signerID string) (*reseed.KeyRotation, error) {
+	rotation, err := reseed.NewKeyStore(keystore).LoadRotation(signerID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return rotation, err
+}
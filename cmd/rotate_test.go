@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCutover(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	got, err := parseCutover("24h", now)
+	if err != nil || !got.Equal(now.Add(24*time.Hour)) {
+		t.Errorf("Expected %v, got %v (%v)", now.Add(24*time.Hour), got, err)
+	}
+	got, err = parseCutover("2030-01-01T00:00:00Z", now)
+	if err != nil || got.Unix() != 1893456000 {
+		t.Errorf("Expected 2030-01-01, got %v (%v)", got, err)
+	}
+	for _, value := range []string{"", "-1h", "1600000000", "soon"} {
+		if _, err := parseCutover(value, now); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+// NewSignCommand creates a new CLI command for signing the unsigned su3
+// bodies written by 'export --unsigned', on a host which holds the signing key
+// and is kept off the network.
+func NewSignCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "sign",
+		Usage:  "Sign unsigned su3 bodies written by 'export --unsigned'",
+		Action: signAction,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key",
+			},
+			&cli.StringFlag{
+				Name:  "dir",
+				Value: "su3",
+				Usage: "Directory containing the unsigned su3 bodies. The signatures are written next to them.",
+			},
+		}, passphraseFlags()...),
+	}
+}
+
+func signAction(c *cli.Context) error {
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+
+	signerKey := c.String("key")
+	// if no key is specified, default to the signerID.pem in the current dir
+	if signerKey == "" {
+		signerKey = signerFile(signerID) + ".pem"
+	}
+	// never generate a key here, the new one wouldn't match the certificate
+	// the su3 files are checked against
+	privKey, err := loadPrivateKey(signerKey, keyProtectionFromFlags(c, false).passphrase())
+	if nil != err {
+		fmt.Printf("Unable to read signing key '%s'\n", signerKey)
+		return err
+	}
+
+	bodies, err := unsignedBodies(c.String("dir"))
+	if nil != err {
+		return err
+	}
+
+	for _, path := range bodies {
+		body, err := os.ReadFile(path)
+		if nil != err {
+			return err
+		}
+		sig, err := signSu3Body(body, signerID, privKey)
+		if nil != err {
+			return fmt.Errorf("unable to sign %s: %s", path, err)
+		}
+		sigPath := strings.TrimSuffix(path, unsignedSuffix) + signatureSuffix
+		if err := os.WriteFile(sigPath, sig, 0o644); nil != err {
+			return err
+		}
+		fmt.Println("Signed", filepath.Base(path))
+	}
+
+	fmt.Printf("Signed %d su3 bodies in %s\n", len(bodies), c.String("dir"))
+	return nil
+}
+
+// unsignedBodies returns the paths of the unsigned su3 bodies in dir.
+func unsignedBodies(dir string) ([]string, error) {
+	bodies, err := filepath.Glob(filepath.Join(dir, "*.su3"+unsignedSuffix))
+	if nil != err {
+		return nil, err
+	}
+	if len(bodies) == 0 {
+		return nil, fmt.Errorf("no unsigned su3 bodies in %s", dir)
+	}
+	return bodies, nil
+}
+
+// signSu3Body signs an unsigned su3 body and returns the detached signature.
+func signSu3Body(body []byte, signerID string, key *rsa.PrivateKey) ([]byte, error) {
+	su3File := su3.New()
+	if err := su3File.UnmarshalBinary(body); nil != err {
+		return nil, err
+	}
+	if su3File.FileType != su3.FileTypeZIP || su3File.ContentType != su3.ContentTypeReseed {
+		return nil, fmt.Errorf("not a reseed su3 body")
+	}
+	if string(su3File.SignerID) != signerID {
+		return nil, fmt.Errorf("body is for signer %q, not %q", su3File.SignerID, signerID)
+	}
+	if len(su3File.Signature) != key.Size() {
+		return nil, fmt.Errorf("body has room for a %d bit signature, but the key is %d bits", len(su3File.Signature)*8, key.Size()*8)
+	}
+
+	if err := su3File.Sign(key); nil != err {
+		return nil, err
+	}
+	// the signature is only useful if it covers exactly the bytes we were given
+	if !bytes.Equal(su3File.BodyBytes(), body) {
+		return nil, fmt.Errorf("body is malformed")
+	}
+
+	return su3File.Signature, nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func TestNewSignCommand(t *testing.T) {
+	cmd := NewSignCommand()
+	if cmd.Name != "sign" {
+		t.Errorf("Expected command name 'sign', got %s", cmd.Name)
+	}
+	if cmd.Action == nil {
+		t.Error("Command action should not be nil")
+	}
+}
+
+func TestNewImportCommand(t *testing.T) {
+	cmd := NewImportCommand()
+	if cmd.Name != "import" {
+		t.Errorf("Expected command name 'import', got %s", cmd.Name)
+	}
+	if cmd.Action == nil {
+		t.Error("Command action should not be nil")
+	}
+}
+
+func TestSignAndImportSu3Body(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	// what 'export --unsigned' writes
+	unsigned := su3.New()
+	unsigned.FileType = su3.FileTypeZIP
+	unsigned.ContentType = su3.ContentTypeReseed
+	unsigned.SignerID = []byte("test@mail.i2p")
+	unsigned.Content = []byte("zipped routerInfos")
+	unsigned.ReserveSignature(key.Size())
+	body := unsigned.BodyBytes()
+
+	if _, err := signSu3Body(body, "other@mail.i2p", key); err == nil {
+		t.Error("signSu3Body() should refuse a body for another signer")
+	}
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	if _, err := signSu3Body(body, "test@mail.i2p", smallKey); err == nil {
+		t.Error("signSu3Body() should refuse a key of the wrong size")
+	}
+
+	sig, err := signSu3Body(body, "test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("signSu3Body() failed: %v", err)
+	}
+
+	data, err := attachSu3Signature(body, sig, "test@mail.i2p", cert)
+	if err != nil {
+		t.Fatalf("attachSu3Signature() failed: %v", err)
+	}
+	signed := su3.New()
+	if err := signed.UnmarshalBinary(data); err != nil {
+		t.Fatalf("Failed to parse signed su3: %v", err)
+	}
+	if err := signed.VerifySignature(cert); err != nil {
+		t.Errorf("Imported su3 should verify: %v", err)
+	}
+
+	sig[0] ^= 0xff
+	if _, err := attachSu3Signature(body, sig, "test@mail.i2p", cert); err == nil {
+		t.Error("attachSu3Signature() should reject a bad signature")
+	}
+	if _, err := attachSu3Signature(body, sig[1:], "test@mail.i2p", cert); err == nil {
+		t.Error("attachSu3Signature() should reject a short signature")
+	}
+}
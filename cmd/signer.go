@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/urfave/cli/v3"
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// NewSignerCommand creates a new CLI command for running a signing daemon,
+// which holds the su3 signing key so the reseed server doesn't have to.
+func NewSignerCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "signer",
+		Usage:  "Hold the su3 signing key and sign for a reseed server over a Unix socket",
+		Action: signerAction,
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "signer",
+				Value: getDefaultSigner(),
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			&cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key",
+			},
+			&cli.StringFlag{
+				Name:  "socket",
+				Value: "reseed-signer.sock",
+				Usage: "Path of the Unix socket to listen on",
+			},
+			&cli.IntSliceFlag{
+				Name:  "allowUid",
+				Usage: "User ID allowed to request signatures, may be repeated (default: only the user running the signer)",
+			},
+			&cli.IntSliceFlag{
+				Name:  "allowGid",
+				Usage: "Group ID allowed to request signatures, may be repeated",
+			},
+			&cli.IntFlag{
+				Name:  "rateLimit",
+				Value: 600,
+				Usage: "Maximum number of signatures per minute for each user (0 = unlimited)",
+			},
+			&cli.IntFlag{
+				Name:  "rateBurst",
+				Value: 400,
+				Usage: "Maximum number of signatures in a burst, enough for a full rebuild of the su3 set",
+			},
+			&cli.StringFlag{
+				Name:  "auditLog",
+				Value: "",
+				Usage: "Append a line for every signing request to this file (default: stdout)",
+			},
+		}, passphraseFlags()...),
+	}
+}
+
+func signerAction(c *cli.Context) error {
+	signerID, err := signerIDFromFlags(c)
+	if nil != err {
+		return err
+	}
+
+	signerKey := c.String("key")
+	// if no key is specified, default to the signerID.pem in the current dir
+	if signerKey == "" {
+		signerKey = signerFile(signerID) + ".pem"
+	}
+	privKey, err := loadPrivateKey(signerKey, keyProtectionFromFlags(c, false).passphrase())
+	if nil != err {
+		fmt.Printf("Unable to read signing key '%s'\n", signerKey)
+		return err
+	}
+
+	daemon := reseed.NewSignerDaemon(privKey)
+	daemon.AllowedUIDs = c.IntSlice("allowUid")
+	daemon.AllowedGIDs = c.IntSlice("allowGid")
+	daemon.RateLimit = c.Int("rateLimit")
+	daemon.RateBurst = c.Int("rateBurst")
+	if auditLog := c.String("auditLog"); auditLog != "" {
+		f, err := os.OpenFile(auditLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if nil != err {
+			return err
+		}
+		defer f.Close()
+		daemon.Audit = log.New(f, "", log.LstdFlags)
+	}
+
+	socket := c.String("socket")
+	log.Printf("Signing for %s on %s\n", signerID, socket)
+	return daemon.ListenAndServe(socket)
+}
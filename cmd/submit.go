@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/reseed"
+)
+
+// writeSubmissionBundle writes an su3 signing certificate the way the I2P
+// developers take it: PEM encoded and named after the signer ID in a reseed/
+// directory, as in the I2P source tree. SUBMIT.txt next to it lists the
+// fingerprints to check it by, followed by notes.
+func writeSubmissionBundle(dir, signerID string, cert *x509.Certificate, notes []string) error {
+	if err := os.MkdirAll(filepath.Join(dir, "reseed"), 0o755); nil != err {
+		return err
+	}
+	certName := reseed.SignerFilename(signerID)
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	if err := os.WriteFile(filepath.Join(dir, "reseed", certName), certPem, 0o644); nil != err {
+		return err
+	}
+
+	sha1Sum := sha1.Sum(cert.Raw)
+	var note strings.Builder
+	fmt.Fprintf(&note, "Reseed signing certificate for %s\n\n", signerID)
+	fmt.Fprintf(&note, "Certificate:          reseed/%s\n", certName)
+	fmt.Fprintf(&note, "Subject:              %s\n", cert.Subject)
+	fmt.Fprintf(&note, "Key:                  %s\n", publicKeyDescription(cert.PublicKey))
+	fmt.Fprintf(&note, "Valid from:           %s\n", cert.NotBefore.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&note, "Valid until:          %s\n", cert.NotAfter.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&note, "SHA-1:                %s\n", reseed.FormatFingerprint(sha1Sum[:]))
+	fmt.Fprintf(&note, "SHA-256:              %s\n", reseed.CertificateFingerprint(cert))
+	for _, line := range notes {
+		fmt.Fprintln(&note, line)
+	}
+	return os.WriteFile(filepath.Join(dir, "SUBMIT.txt"), []byte(note.String()), 0o644)
+}
+
+// publicKeyDescription names the algorithm and size of a public key.
+func publicKeyDescription(pub interface{}) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return fmt.Sprintf("%T", pub)
+	}
+}
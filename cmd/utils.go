@@ -2,14 +2,10 @@ package cmd
 
 import (
 	"bufio"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/asn1"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
@@ -19,28 +15,37 @@ import (
 
 	"i2pgit.org/idk/reseed-tools/reseed"
 	"i2pgit.org/idk/reseed-tools/su3"
-
-	"github.com/go-acme/lego/v4/certcrypto"
-	"github.com/go-acme/lego/v4/certificate"
-	"github.com/go-acme/lego/v4/challenge/http01"
-	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
-	"github.com/go-acme/lego/v4/lego"
-	"github.com/go-acme/lego/v4/registration"
 )
 
-func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
-	privPem, err := ioutil.ReadFile(path)
+// loadPrivateKey reads an RSA su3 signing key, which may be encrypted.
+func loadPrivateKey(path string, passphrase reseed.PassphraseFunc) (*rsa.PrivateKey, error) {
+	key, err := reseed.LoadPrivateKey(path, passphrase)
 	if nil != err {
 		return nil, err
 	}
 
-	privDer, _ := pem.Decode(privPem)
-	privKey, err := x509.ParsePKCS1PrivateKey(privDer.Bytes)
+	privKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA private key", path)
+	}
+
+	return privKey, nil
+}
+
+// loadCertificate reads a PEM encoded certificate, such as the signer
+// certificate written by createSigningCertificate.
+func loadCertificate(path string) (*x509.Certificate, error) {
+	certPem, err := ioutil.ReadFile(path)
 	if nil != err {
 		return nil, err
 	}
 
-	return privKey, nil
+	certDer, _ := pem.Decode(certPem)
+	if certDer == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+
+	return x509.ParseCertificate(certDer.Bytes)
 }
 
 // MyUser struct and methods moved to myuser.go
@@ -52,7 +57,7 @@ func signerFile(signerID string) string {
 	return strings.Replace(signerID, "@", "_at_", 1)
 }
 
-func getOrNewSigningCert(signerKey *string, signerID string, auto bool) (*rsa.PrivateKey, error) {
+func getOrNewSigningCert(signerKey *string, signerID string, auto bool, kp *keyProtection) (*rsa.PrivateKey, error) {
 	if _, err := os.Stat(*signerKey); nil != err {
 		fmt.Printf("Unable to read signing key '%s'\n", *signerKey)
 		if !auto {
@@ -63,171 +68,17 @@ func getOrNewSigningCert(signerKey *string, signerID string, auto bool) (*rsa.Pr
 				return nil, fmt.Errorf("A signing key is required")
 			}
 		}
-		if err := createSigningCertificate(signerID); nil != err {
+		if err := createSigningCertificate(signerID, kp); nil != err {
 			return nil, err
 		}
 
 		*signerKey = signerFile(signerID) + ".pem"
 	}
 
-	return loadPrivateKey(*signerKey)
+	return loadPrivateKey(*signerKey, kp.passphrase())
 }
 
-func checkUseAcmeCert(tlsHost, signer, cadirurl string, tlsCert, tlsKey *string, auto bool) error {
-	_, certErr := os.Stat(*tlsCert)
-	_, keyErr := os.Stat(*tlsKey)
-	if certErr != nil || keyErr != nil {
-		if certErr != nil {
-			fmt.Printf("Unable to read TLS certificate '%s'\n", *tlsCert)
-		}
-		if keyErr != nil {
-			fmt.Printf("Unable to read TLS key '%s'\n", *tlsKey)
-		}
-
-		if !auto {
-			fmt.Printf("Would you like to generate a new certificate with Let's Encrypt or a custom ACME server? '%s'? (y or n): ", tlsHost)
-			reader := bufio.NewReader(os.Stdin)
-			input, _ := reader.ReadString('\n')
-			if []byte(input)[0] != 'y' {
-				fmt.Println("Continuing without TLS")
-				return nil
-			}
-		}
-	} else {
-		TLSConfig := &tls.Config{}
-		TLSConfig.NextProtos = []string{"http/1.1"}
-		TLSConfig.Certificates = make([]tls.Certificate, 1)
-		var err error
-		TLSConfig.Certificates[0], err = tls.LoadX509KeyPair(*tlsCert, *tlsKey)
-		if err != nil {
-			return err
-		}
-		// Check if certificate expires within 48 hours (time until expiration < 48 hours)
-		if time.Until(TLSConfig.Certificates[0].Leaf.NotAfter) < (time.Hour * 48) {
-			ecder, err := ioutil.ReadFile(tlsHost + signer + ".acme.key")
-			if err != nil {
-				return err
-			}
-			privateKey, err := x509.ParseECPrivateKey(ecder)
-			if err != nil {
-				return err
-			}
-			user := NewMyUser(signer, privateKey)
-			config := lego.NewConfig(user)
-			config.CADirURL = cadirurl
-			config.Certificate.KeyType = certcrypto.RSA2048
-			client, err := lego.NewClient(config)
-			if err != nil {
-				return err
-			}
-			renewAcmeIssuedCert(client, *user, tlsHost, tlsCert, tlsKey)
-		} else {
-			return nil
-		}
-	}
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return err
-	}
-	ecder, err := x509.MarshalECPrivateKey(privateKey)
-	if err != nil {
-		return err
-	}
-	filename := tlsHost + signer + ".acme.key"
-	keypem, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
-	if err != nil {
-		return err
-	}
-	defer keypem.Close()
-	err = pem.Encode(keypem, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})
-	if err != nil {
-		return err
-	}
-	user := NewMyUser(signer, privateKey)
-	config := lego.NewConfig(user)
-	config.CADirURL = cadirurl
-	config.Certificate.KeyType = certcrypto.RSA2048
-	client, err := lego.NewClient(config)
-	if err != nil {
-		return err
-	}
-	return newAcmeIssuedCert(client, *user, tlsHost, tlsCert, tlsKey)
-}
-
-func renewAcmeIssuedCert(client *lego.Client, user MyUser, tlsHost string, tlsCert, tlsKey *string) error {
-	var err error
-	err = client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", "8000"))
-	if err != nil {
-		return err
-	}
-	err = client.Challenge.SetTLSALPN01Provider(tlsalpn01.NewProviderServer("", "8443"))
-	if err != nil {
-		return err
-	}
-
-	// New users will need to register
-	if user.Registration, err = client.Registration.QueryRegistration(); err != nil {
-		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			return err
-		}
-		user.Registration = reg
-	}
-	resource, err := client.Certificate.Get(tlsHost, true)
-	if err != nil {
-		return err
-	}
-	certificates, err := client.Certificate.Renew(*resource, true, false, "")
-	if err != nil {
-		return err
-	}
-
-	ioutil.WriteFile(tlsHost+".pem", certificates.PrivateKey, 0o600)
-	ioutil.WriteFile(tlsHost+".crt", certificates.Certificate, 0o600)
-	//	ioutil.WriteFile(tlsHost+".crl", certificates.PrivateKey, 0600)
-	*tlsCert = tlsHost + ".crt"
-	*tlsKey = tlsHost + ".pem"
-	return nil
-}
-
-func newAcmeIssuedCert(client *lego.Client, user MyUser, tlsHost string, tlsCert, tlsKey *string) error {
-	var err error
-	err = client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", "8000"))
-	if err != nil {
-		return err
-	}
-	err = client.Challenge.SetTLSALPN01Provider(tlsalpn01.NewProviderServer("", "8443"))
-	if err != nil {
-		return err
-	}
-
-	// New users will need to register
-	if user.Registration, err = client.Registration.QueryRegistration(); err != nil {
-		reg, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			return err
-		}
-		user.Registration = reg
-	}
-
-	request := certificate.ObtainRequest{
-		Domains: []string{tlsHost},
-		Bundle:  true,
-	}
-	certificates, err := client.Certificate.Obtain(request)
-	if err != nil {
-		return err
-	}
-
-	ioutil.WriteFile(tlsHost+".pem", certificates.PrivateKey, 0o600)
-	ioutil.WriteFile(tlsHost+".crt", certificates.Certificate, 0o600)
-	//	ioutil.WriteFile(tlsHost+".crl", certificates.PrivateKey, 0600)
-	*tlsCert = tlsHost + ".crt"
-	*tlsKey = tlsHost + ".pem"
-	return nil
-}
-
-func checkOrNewTLSCert(tlsHost string, tlsCert, tlsKey *string, auto bool) error {
+func checkOrNewTLSCert(tlsHost string, tlsCert, tlsKey *string, auto bool, kp *keyProtection) error {
 	_, certErr := os.Stat(*tlsCert)
 	_, keyErr := os.Stat(*tlsKey)
 	if certErr != nil || keyErr != nil {
@@ -248,7 +99,7 @@ func checkOrNewTLSCert(tlsHost string, tlsCert, tlsKey *string, auto bool) error
 			}
 		}
 
-		if err := createTLSCertificate(tlsHost); nil != err {
+		if err := createTLSCertificate(tlsHost, kp, nil); nil != err {
 			return err
 		}
 
@@ -259,21 +110,27 @@ func checkOrNewTLSCert(tlsHost string, tlsCert, tlsKey *string, auto bool) error
 	return nil
 }
 
-func createSigningCertificate(signerID string) error {
+func createSigningCertificate(signerID string, kp *keyProtection) error {
+	return createSigningCertificateFiles(signerID, signerFile(signerID), kp, nil)
+}
+
+// createSigningCertificateFiles generates a signing key and certificate for
+// signerID as opts asks, and saves them as base.pem, base.crt and base.crl.
+func createSigningCertificateFiles(signerID, base string, kp *keyProtection, opts *keygenOptions) error {
 	// generate private key
 	fmt.Println("Generating signing keys. This may take a minute...")
-	signerKey, err := rsa.GenerateKey(rand.Reader, 4096)
+	signerKey, err := opts.signerKey()
 	if err != nil {
 		return err
 	}
 
-	signerCert, err := su3.NewSigningCertificate(signerID, signerKey)
+	signerCert, err := su3.NewSigningCertificateWithOptions(signerID, signerKey, opts.certificate())
 	if nil != err {
 		return err
 	}
 
 	// save cert
-	certFile := signerFile(signerID) + ".crt"
+	certFile := base + ".crt"
 	certOut, err := os.Create(certFile)
 	if err != nil {
 		return fmt.Errorf("failed to open %s for writing: %v", certFile, err)
@@ -283,18 +140,22 @@ func createSigningCertificate(signerID string) error {
 	fmt.Println("\tSigning certificate saved to:", certFile)
 
 	// save signing private key
-	privFile := signerFile(signerID) + ".pem"
+	keyBlock, err := kp.keyBlock(signerKey, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(signerKey)})
+	if err != nil {
+		return err
+	}
+	privFile := base + ".pem"
 	keyOut, err := os.OpenFile(privFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to open %s for writing: %v", privFile, err)
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(signerKey)})
+	pem.Encode(keyOut, keyBlock)
 	pem.Encode(keyOut, &pem.Block{Type: "CERTIFICATE", Bytes: signerCert})
 	keyOut.Close()
 	fmt.Println("\tSigning private key saved to:", privFile)
 
 	// CRL
-	crlFile := signerFile(signerID) + ".crl"
+	crlFile := base + ".crl"
 	crlOut, err := os.OpenFile(crlFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to open %s for writing: %s", crlFile, err)
@@ -327,18 +188,20 @@ func createSigningCertificate(signerID string) error {
 	return nil
 }
 
-func createTLSCertificate(host string) error {
-	return CreateTLSCertificate(host)
+func CreateTLSCertificate(host string) error {
+	return createTLSCertificate(host, nil, nil)
 }
 
-func CreateTLSCertificate(host string) error {
+// createTLSCertificate generates a self-signed TLS certificate for host as
+// opts asks, and saves it as host.crt, host.pem and host.crl.
+func createTLSCertificate(host string, kp *keyProtection, opts *keygenOptions) error {
 	fmt.Println("Generating TLS keys. This may take a minute...")
-	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	priv, err := opts.tlsKey()
 	if err != nil {
 		return err
 	}
 
-	tlsCert, err := reseed.NewTLSCertificate(host, priv)
+	tlsCert, err := reseed.NewTLSCertificateWithOptions(priv, opts.certificate(), append([]string{host}, opts.altNames()...)...)
 	if nil != err {
 		return err
 	}
@@ -353,15 +216,27 @@ func CreateTLSCertificate(host string) error {
 	fmt.Printf("\tTLS certificate saved to: %s\n", host+".crt")
 
 	// save the TLS private key
+	plain, err := plainKeyBlock(priv)
+	if err != nil {
+		return err
+	}
+	keyBlock, err := kp.keyBlock(priv, plain)
+	if err != nil {
+		return err
+	}
+	ecParams, err := ecParametersBlock(priv)
+	if err != nil {
+		return err
+	}
 	privFile := host + ".pem"
 	keyOut, err := os.OpenFile(privFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to open %s for writing: %v", privFile, err)
 	}
-	secp384r1, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34}) // http://www.ietf.org/rfc/rfc5480.txt
-	pem.Encode(keyOut, &pem.Block{Type: "EC PARAMETERS", Bytes: secp384r1})
-	ecder, err := x509.MarshalECPrivateKey(priv)
-	pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})
+	if ecParams != nil {
+		pem.Encode(keyOut, ecParams)
+	}
+	pem.Encode(keyOut, keyBlock)
 	pem.Encode(keyOut, &pem.Block{Type: "CERTIFICATE", Bytes: tlsCert})
 
 	keyOut.Close()
@@ -400,3 +275,20 @@ func CreateTLSCertificate(host string) error {
 
 	return nil
 }
+
+// writeIssuedKey saves a PEM private key issued along with an ACME
+// certificate, encrypting it if kp asks for it.
+func writeIssuedKey(path string, keyPem []byte, kp *keyProtection) error {
+	if kp != nil && kp.Encrypt {
+		key, err := reseed.ParsePrivateKeyPEM(keyPem, nil)
+		if err != nil {
+			return err
+		}
+		keyBlock, err := kp.keyBlock(key, nil)
+		if err != nil {
+			return err
+		}
+		keyPem = pem.EncodeToMemory(keyBlock)
+	}
+	return ioutil.WriteFile(path, keyPem, 0o600)
+}
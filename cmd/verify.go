@@ -1,12 +1,21 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v3"
 	"i2pgit.org/idk/reseed-tools/reseed"
@@ -55,6 +64,14 @@ func NewSu3VerifyCommand() *cli.Command {
 				Value: filepath.Join(I2PHome(), "/certificates/reseed"),
 				Usage: "Path to the keystore",
 			},
+			&cli.StringFlag{
+				Name:  "log",
+				Usage: "Check that the su3 appears in this reseed server's transparency log (ex. https://reseed.example.com:8443)",
+			},
+			&cli.BoolFlag{
+				Name:  "logInsecureTLS",
+				Usage: "Don't verify the TLS certificate of the transparency log server. The tree head is still checked against the signer certificate.",
+			},
 		},
 	}
 }
@@ -98,9 +115,61 @@ func su3VerifyAction(c *cli.Context) error {
 
 	fmt.Printf("Signature is valid for signer '%s'\n", su3File.SignerID)
 
+	if logURL := c.String("log"); logURL != "" {
+		if err := verifyTransparencyLog(logURL, data, cert, c.Bool("logInsecureTLS")); nil != err {
+			fmt.Println(err)
+			return err
+		}
+	}
+
 	if c.Bool("extract") {
 		// @todo: don't assume zip
 		ioutil.WriteFile("extracted.zip", su3File.BodyBytes(), 0o755)
 	}
 	return nil
 }
+
+// verifyTransparencyLog fetches an inclusion proof for the su3 file from a
+// reseed server's transparency log and checks it against the signed tree head.
+func verifyTransparencyLog(logURL string, su3Bytes []byte, cert *x509.Certificate, insecure bool) error {
+	digest := sha256.Sum256(su3Bytes)
+	sum := hex.EncodeToString(digest[:])
+
+	u, err := url.Parse(strings.TrimSuffix(logURL, "/"))
+	if nil != err {
+		return err
+	}
+	if !strings.HasSuffix(u.Path, "/transparency") {
+		u.Path += "/transparency"
+	}
+	u.Path += "/proof"
+	u.RawQuery = url.Values{"sha256": {sum}}.Encode()
+
+	client := &http.Client{Timeout: time.Minute}
+	if insecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	resp, err := client.Get(u.String())
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("su3 %s is not in the transparency log: %s", sum, resp.Status)
+	}
+
+	var proof reseed.InclusionProof
+	if err := json.NewDecoder(resp.Body).Decode(&proof); nil != err {
+		return err
+	}
+	if err := proof.Head.VerifySignature(cert); nil != err {
+		return fmt.Errorf("transparency log tree head signature is invalid: %s", err)
+	}
+	if err := proof.Verify(sum); nil != err {
+		return err
+	}
+
+	fmt.Printf("su3 %s is entry %d of %d in the transparency log (root %s, signed %s)\n",
+		sum, proof.Index, proof.Head.TreeSize, proof.Head.RootHash, time.Unix(proof.Head.Timestamp, 0).UTC().Format(time.RFC3339))
+	return nil
+}
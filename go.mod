@@ -3,6 +3,7 @@ module i2pgit.org/idk/reseed-tools
 go 1.24.2
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/cretz/bine v0.2.0
 	github.com/eyedeekay/go-i2pd v0.0.0-20220213070306-9807541b2dfc
 	github.com/eyedeekay/unembed v0.0.0-20230123014222-9916b121855b
@@ -19,7 +20,11 @@ require (
 	github.com/throttled/throttled/v2 v2.7.1
 	github.com/urfave/cli/v3 v3.0.0-alpha
 	gitlab.com/golang-commonmark/markdown v0.0.0-20191127184510-91b5b3c99c19
+	golang.org/x/crypto v0.39.0
+	golang.org/x/sys v0.33.0
+	golang.org/x/term v0.32.0
 	golang.org/x/text v0.26.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -46,10 +51,8 @@ require (
 	go.opentelemetry.io/otel v1.36.0 // indirect
 	go.opentelemetry.io/otel/trace v1.36.0 // indirect
 	go.step.sm/crypto v0.67.0 // indirect
-	golang.org/x/crypto v0.39.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
 	golang.org/x/sync v0.15.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 	gopkg.in/square/go-jose.v2 v2.5.1 // indirect
 )
 
@@ -15,9 +15,6 @@ func main() {
 	// To enable it from within the process, set the environment variable before any use of TLS:
 	os.Setenv("GODEBUG", os.Getenv("GODEBUG")+",tls13=1")
 
-	// use at most half the cpu cores
-	runtime.GOMAXPROCS(runtime.NumCPU() / 2)
-
 	app := cli.NewApp()
 	app.Name = "reseed-tools"
 	app.Version = reseed.Version
@@ -27,11 +24,37 @@ func main() {
 		Email: "hankhill19580@gmail.com",
 	}
 	app.Authors = append(app.Authors, auth)
-	app.Flags = []cli.Flag{}
+	app.Flags = []cli.Flag{
+		&cli.IntFlag{
+			Name:    "maxprocs",
+			Value:   0,
+			EnvVars: []string{"RESEED_MAXPROCS"},
+			Usage:   "Maximum number of CPU cores to use (0 = half of the available cores)",
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		maxprocs := c.Int("maxprocs")
+		if maxprocs <= 0 {
+			// use at most half the cpu cores
+			maxprocs = runtime.NumCPU() / 2
+		}
+		runtime.GOMAXPROCS(maxprocs)
+		return nil
+	}
 	app.Commands = []*cli.Command{
 		cmd.NewReseedCommand(),
 		cmd.NewSu3VerifyCommand(),
+		cmd.NewExportCommand(),
+		cmd.NewSignCommand(),
+		cmd.NewImportCommand(),
+		cmd.NewSignerCommand(),
 		cmd.NewKeygenCommand(),
+		cmd.NewReencryptCommand(),
+		cmd.NewBackupCommand(),
+		cmd.NewRestoreCommand(),
+		cmd.NewPKCS12Command(),
+		cmd.NewCertsCommand(),
+		cmd.NewConfigCommand(),
 		cmd.NewShareCommand(),
 		cmd.NewVersionCommand(),
 		// cmd.NewSu3VerifyPublicCommand(),
@@ -0,0 +1,200 @@
+package reseed
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// BackupType is the PEM block type of an operator identity backup. The block
+// holds a zip archive of the backed up files and their manifest, sealed the
+// same way as an encrypted private key.
+const BackupType = "RESEED BACKUP"
+
+// backupVersion is the version of the manifest format.
+const backupVersion = 1
+
+// Kinds of files in a backup.
+const (
+	BackupSignerKey   = "signer-key"
+	BackupSignerCert  = "signer-cert"
+	BackupCRL         = "crl"
+	BackupRotation    = "rotation"
+	BackupTLSKey      = "tls-key"
+	BackupTLSCert     = "tls-cert"
+	BackupACMEKey     = "acme-key"
+	BackupACMEAccount = "acme-account"
+	BackupOnionKey    = "onion-key"
+	BackupI2PKeys     = "i2p-keys"
+	BackupOther       = "file"
+)
+
+// BackupManifest lists the files in a backup.
+type BackupManifest struct {
+	Version  int          `json:"version"`
+	Created  time.Time    `json:"created"`
+	SignerID string       `json:"signer,omitempty"`
+	Files    []BackupFile `json:"files"`
+}
+
+// BackupFile is a file in a backup.
+type BackupFile struct {
+	// Name is the file name, without any directory
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Mode is the permissions the file had
+	Mode os.FileMode `json:"mode"`
+	// SHA256 is the hex SHA-256 digest of the contents
+	SHA256 string `json:"sha256"`
+	// Key names the private key file of a certificate
+	Key string `json:"key,omitempty"`
+
+	Data []byte `json:"-"`
+}
+
+// NewBackupFile reads the file at filePath into a BackupFile of kind.
+func NewBackupFile(filePath, kind string) (BackupFile, error) {
+	info, err := os.Stat(filePath)
+	if nil != err {
+		return BackupFile{}, err
+	}
+	data, err := os.ReadFile(filePath)
+	if nil != err {
+		return BackupFile{}, err
+	}
+	sum := sha256.Sum256(data)
+	return BackupFile{
+		Name:   info.Name(),
+		Kind:   kind,
+		Mode:   info.Mode().Perm(),
+		SHA256: hex.EncodeToString(sum[:]),
+		Data:   data,
+	}, nil
+}
+
+// File returns the file named name, or nil.
+func (m *BackupManifest) File(name string) *BackupFile {
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return &m.Files[i]
+		}
+	}
+	return nil
+}
+
+// SealBackup archives the files of m and seals them with a key derived from
+// passphrase by kdf, returning the PEM encoded backup.
+func SealBackup(m *BackupManifest, passphrase []byte, kdf string) ([]byte, error) {
+	seen := make(map[string]bool)
+	for _, f := range m.Files {
+		if f.Name != path.Base(f.Name) || f.Name == "." || f.Name == "/" || f.Name == "manifest.json" {
+			return nil, fmt.Errorf("bad file name %q", f.Name)
+		}
+		if seen[f.Name] {
+			return nil, fmt.Errorf("%s is in the backup twice", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	m.Version = backupVersion
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if nil != err {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	entries := append([]BackupFile{{Name: "manifest.json", Mode: 0o644, Data: manifest}}, m.Files...)
+	for _, f := range entries {
+		header := &zip.FileHeader{Name: f.Name, Method: zip.Deflate}
+		header.SetModTime(m.Created)
+		header.SetMode(f.Mode)
+		w, err := zipWriter.CreateHeader(header)
+		if nil != err {
+			return nil, err
+		}
+		if _, err := w.Write(f.Data); nil != err {
+			return nil, err
+		}
+	}
+	if err := zipWriter.Close(); nil != err {
+		return nil, err
+	}
+
+	block, err := sealBlock(BackupType, buf.Bytes(), passphrase, kdf)
+	if nil != err {
+		return nil, err
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// OpenBackup opens a backup sealed by SealBackup, and checks every file in
+// it against the digest in the manifest.
+func OpenBackup(data, passphrase []byte) (*BackupManifest, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != BackupType {
+		return nil, errors.New("not a reseed backup")
+	}
+	archive, err := openBlock(block, passphrase, "backup")
+	if nil != err {
+		return nil, err
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if nil != err {
+		return nil, err
+	}
+	contents := make(map[string][]byte)
+	for _, f := range zipReader.File {
+		rc, err := f.Open()
+		if nil != err {
+			return nil, err
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if nil != err {
+			return nil, err
+		}
+		contents[f.Name] = data
+	}
+
+	var m BackupManifest
+	if err := json.Unmarshal(contents["manifest.json"], &m); nil != err {
+		return nil, fmt.Errorf("backup manifest: %s", err)
+	}
+	if m.Version != backupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+	}
+	for i := range m.Files {
+		f := &m.Files[i]
+		if f.Name != path.Base(f.Name) || f.Name == "." || f.Name == "/" {
+			return nil, fmt.Errorf("bad file name %q in backup", f.Name)
+		}
+		data, ok := contents[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s is missing from the backup", f.Name)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != f.SHA256 {
+			return nil, fmt.Errorf("%s does not match its digest in the backup manifest", f.Name)
+		}
+		f.Data = data
+	}
+	return &m, nil
+}
+
+// KeyMatchesCertificate reports whether key is the private key of cert.
+func KeyMatchesCertificate(key crypto.Signer, cert *x509.Certificate) bool {
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(cert.PublicKey)
+}
@@ -0,0 +1,88 @@
+package reseed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func TestSealBackup(t *testing.T) {
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "host.pem")
+	if err := os.WriteFile(keyPath, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewBackupFile(keyPath, BackupTLSKey)
+	if err != nil {
+		t.Fatalf("NewBackupFile failed: %v", err)
+	}
+	if f.Name != "host.pem" || f.Mode != 0o600 {
+		t.Errorf("Unexpected file %s with mode %v", f.Name, f.Mode)
+	}
+
+	m := &BackupManifest{Created: time.Now().UTC(), SignerID: "test@mail.i2p", Files: []BackupFile{f}}
+	data, err := SealBackup(m, []byte("backup"), KDFScrypt)
+	if err != nil {
+		t.Fatalf("SealBackup failed: %v", err)
+	}
+
+	got, err := OpenBackup(data, []byte("backup"))
+	if err != nil {
+		t.Fatalf("OpenBackup failed: %v", err)
+	}
+	if got.SignerID != m.SignerID || got.File("host.pem") == nil || string(got.File("host.pem").Data) != "key" {
+		t.Errorf("Unexpected manifest %+v", got)
+	}
+
+	if _, err := OpenBackup(data, []byte("wrong")); err == nil {
+		t.Error("Expected an error with the wrong passphrase")
+	}
+	block, _ := pem.Decode(data)
+	block.Bytes[len(block.Bytes)/2] ^= 1
+	if _, err := OpenBackup(pem.EncodeToMemory(block), []byte("backup")); err == nil {
+		t.Error("Expected an error for a modified backup")
+	}
+
+	m.Files = append(m.Files, f)
+	if _, err := SealBackup(m, []byte("backup"), KDFScrypt); err == nil {
+		t.Error("Expected an error for a file added twice")
+	}
+	m.Files = []BackupFile{{Name: "../host.pem"}}
+	if _, err := SealBackup(m, []byte("backup"), KDFScrypt); err == nil {
+		t.Error("Expected an error for a file name with a directory")
+	}
+}
+
+func TestKeyMatchesCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := NewTLSCertificateWithOptions(key, su3.CertificateOptions{}, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !KeyMatchesCertificate(key, cert) {
+		t.Error("Expected the key to match its certificate")
+	}
+	if KeyMatchesCertificate(other, cert) {
+		t.Error("Expected another key not to match")
+	}
+}
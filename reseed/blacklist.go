@@ -1,77 +1,401 @@
 package reseed
 
 import (
-	"errors"
+	"bufio"
+	"fmt"
 	"net"
+	"net/netip"
 	"os"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Blacklist holds the addresses whose connections are refused: single IPv4
+// and IPv6 addresses, CIDR ranges and ranges from one address to another,
+// each of which may expire.
 type Blacklist struct {
+	// blacklist holds every entry by its text, an address, a CIDR range
+	// such as 192.0.2.0/24 or a range such as 192.0.2.1-192.0.2.9
 	blacklist map[string]bool
-	m         sync.RWMutex
+	// entries are the address ranges of the entries which have one
+	entries map[string]blacklistEntry
+	// ranges are the unexpired address ranges, sorted and merged, and
+	// nextExpiry the time when one of them expires and they are built again
+	ranges     []addrRange
+	nextExpiry time.Time
+	m          sync.RWMutex
+}
+
+// blacklistEntry is the address range of an entry, and when it expires if
+// it does.
+type blacklistEntry struct {
+	addrRange
+	expires time.Time
+}
+
+// addrRange is the addresses from From to To, both included, of the same
+// family.
+type addrRange struct {
+	From netip.Addr
+	To   netip.Addr
+}
+
+// BlacklistStats describes what loading blacklist files found.
+type BlacklistStats struct {
+	Files int
+	// Addresses and Ranges are the single addresses and the address ranges
+	// loaded
+	Addresses int
+	Ranges    int
+	// Expired are the entries whose expiry had passed, which aren't loaded
+	Expired int
+	// Skipped are the lines which hold no address, such as the router
+	// hashes of an I2P blocklist
+	Skipped int
+}
+
+func (st BlacklistStats) String() string {
+	return fmt.Sprintf("%d addresses and %d ranges from %d files, %d expired and %d lines skipped", st.Addresses, st.Ranges, st.Files, st.Expired, st.Skipped)
 }
 
 func NewBlacklist() *Blacklist {
-	return &Blacklist{blacklist: make(map[string]bool), m: sync.RWMutex{}}
+	return &Blacklist{blacklist: make(map[string]bool), entries: make(map[string]blacklistEntry), m: sync.RWMutex{}}
 }
 
+// LoadFile blocks the entries of a blacklist file.
 func (s *Blacklist) LoadFile(file string) error {
-	if file != "" {
-		if content, err := os.ReadFile(file); err == nil {
-			for _, ip := range strings.Split(string(content), "\n") {
-				s.BlockIP(ip)
+	_, err := s.LoadFiles(file)
+	return err
+}
+
+// LoadFiles blocks the entries of each file. A file has an entry on each
+// line: an address, a CIDR range or a range from one address to another,
+// optionally after a label and a colon, as in the I2P blocklist.txt format.
+// An entry can end with expires= and a date or RFC 3339 time, after which
+// it isn't blocked. Lines starting with # are comments.
+func (s *Blacklist) LoadFiles(files ...string) (BlacklistStats, error) {
+	var stats BlacklistStats
+	now := time.Now()
+	s.m.Lock()
+	defer s.m.Unlock()
+	defer s.rebuild(now)
+
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		f, err := os.Open(file)
+		if nil != err {
+			return stats, err
+		}
+		stats.Files++
+		lines := 0
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			s.loadLine(scanner.Text(), now, &stats)
+			lines++
+		}
+		err = scanner.Err()
+		f.Close()
+		if nil != err {
+			return stats, fmt.Errorf("%s: %s", file, err)
+		}
+		if lines == 0 {
+			// an empty file blocks the empty address, as it always did
+			s.blacklist[""] = true
+		}
+	}
+	return stats, nil
+}
+
+// loadLine blocks the entry of one line of a blacklist file.
+func (s *Blacklist) loadLine(line string, now time.Time, stats *BlacklistStats) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return
+	}
+	for i := 1; i < len(line); i++ {
+		// a comment can follow an entry after a space or tab
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			line = strings.TrimSpace(line[:i])
+			break
+		}
+	}
+	if line == "" {
+		// no connection has the empty address, which blank lines have
+		// always blocked
+		s.blacklist[""] = true
+		return
+	}
+
+	var expires time.Time
+	if fields := strings.Fields(line); len(fields) > 1 && strings.HasPrefix(fields[len(fields)-1], "expires=") {
+		last := fields[len(fields)-1]
+		t, err := parseExpiry(strings.TrimPrefix(last, "expires="))
+		if nil != err {
+			stats.Skipped++
+			return
+		}
+		expires = t
+		line = strings.TrimSpace(strings.TrimSuffix(line, last))
+	}
+
+	key, r, err := parseBlacklistEntry(line)
+	if nil != err {
+		// an I2P blocklist entry is a label, a colon and the entry, with
+		// ; instead of : in IPv6 addresses
+		if i := strings.Index(line, ":"); i >= 0 {
+			key, r, err = parseBlacklistEntry(strings.ReplaceAll(line[i+1:], ";", ":"))
+		}
+	}
+	if nil != err {
+		stats.Skipped++
+		return
+	}
+	if !expires.IsZero() && !expires.After(now) {
+		stats.Expired++
+		return
+	}
+	if r.From == r.To {
+		stats.Addresses++
+	} else {
+		stats.Ranges++
+	}
+	s.blacklist[key] = true
+	s.entries[key] = blacklistEntry{addrRange: r, expires: expires}
+}
+
+// parseExpiry parses a date such as 2026-12-31 or an RFC 3339 time.
+func parseExpiry(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); nil == err {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseBlacklistEntry parses an address, a CIDR range or a range from one
+// address to another, returning the entry's text and its address range.
+func parseBlacklistEntry(entry string) (string, addrRange, error) {
+	if from, to, ok := strings.Cut(entry, "-"); ok {
+		f, err := parseAddr(strings.TrimSpace(from))
+		if nil != err {
+			return "", addrRange{}, err
+		}
+		t, err := parseAddr(strings.TrimSpace(to))
+		if nil != err {
+			return "", addrRange{}, err
+		}
+		if f.Is4() != t.Is4() || t.Less(f) {
+			return "", addrRange{}, fmt.Errorf("%s is not a range of addresses", entry)
+		}
+		if f == t {
+			return f.String(), addrRange{f, t}, nil
+		}
+		return f.String() + "-" + t.String(), addrRange{f, t}, nil
+	}
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if nil != err {
+			return "", addrRange{}, err
+		}
+		prefix = prefix.Masked()
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.String(), addrRange{prefix.Addr(), lastAddr(prefix)}, nil
+	}
+	addr, err := parseAddr(entry)
+	if nil != err {
+		return "", addrRange{}, err
+	}
+	return addr.String(), addrRange{addr, addr}, nil
+}
+
+// parseAddr parses an IPv4 or IPv6 address, without a zone and with IPv4
+// addresses mapped to IPv6 unmapped.
+func parseAddr(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if nil != err {
+		return netip.Addr{}, err
+	}
+	return addr.WithZone("").Unmap(), nil
+}
+
+// lastAddr returns the last address of prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// rebuild sorts and merges the address ranges of the entries which haven't
+// expired by now.
+func (s *Blacklist) rebuild(now time.Time) {
+	var ranges []addrRange
+	s.nextExpiry = time.Time{}
+	for _, e := range s.entries {
+		if !e.expires.IsZero() {
+			if !e.expires.After(now) {
+				continue
+			}
+			if s.nextExpiry.IsZero() || e.expires.Before(s.nextExpiry) {
+				s.nextExpiry = e.expires
 			}
-		} else {
-			return err
 		}
+		ranges = append(ranges, e.addrRange)
 	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From.Less(ranges[j].From) })
 
-	return nil
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && merged[n-1].From.Is4() == r.From.Is4() && !merged[n-1].To.Less(r.From) {
+			if merged[n-1].To.Less(r.To) {
+				merged[n-1].To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	s.ranges = merged
 }
 
 func (s *Blacklist) BlockIP(ip string) {
+	s.BlockUntil(ip, time.Time{})
+}
+
+// BlockUntil blocks an address, a CIDR range or a range from one address to
+// another until expires, or for good if expires is zero.
+func (s *Blacklist) BlockUntil(entry string, expires time.Time) {
 	s.m.Lock()
 	defer s.m.Unlock()
 
-	s.blacklist[ip] = true
+	key, r, err := parseBlacklistEntry(entry)
+	if nil != err {
+		// only the very same string is blocked
+		s.blacklist[entry] = true
+		return
+	}
+	s.blacklist[key] = true
+	s.entries[key] = blacklistEntry{addrRange: r, expires: expires}
+	s.rebuild(time.Now())
 }
 
-func (s *Blacklist) isBlocked(ip string) bool {
+// Len returns the number of entries blocked, expired or not.
+func (s *Blacklist) Len() int {
 	s.m.RLock()
 	defer s.m.RUnlock()
 
-	blocked, found := s.blacklist[ip]
+	n := len(s.blacklist)
+	if s.blacklist[""] {
+		n--
+	}
+	return n
+}
 
-	return found && blocked
+// Replace blocks the entries blocked by other instead of the current ones,
+// and returns how many were added and removed.
+func (s *Blacklist) Replace(other *Blacklist) (added, removed int) {
+	other.m.RLock()
+	blacklist := make(map[string]bool, len(other.blacklist))
+	for key, blocked := range other.blacklist {
+		blacklist[key] = blocked
+	}
+	entries := make(map[string]blacklistEntry, len(other.entries))
+	for key, e := range other.entries {
+		entries[key] = e
+	}
+	other.m.RUnlock()
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	for key := range blacklist {
+		if !s.blacklist[key] {
+			added++
+		}
+	}
+	for key := range s.blacklist {
+		if !blacklist[key] {
+			removed++
+		}
+	}
+	s.blacklist = blacklist
+	s.entries = entries
+	s.rebuild(time.Now())
+	return added, removed
+}
+
+func (s *Blacklist) isBlocked(ip string) bool {
+	now := time.Now()
+	s.m.RLock()
+	if !s.nextExpiry.IsZero() && !now.Before(s.nextExpiry) {
+		// an entry expired since the ranges were built
+		s.m.RUnlock()
+		s.m.Lock()
+		s.rebuild(now)
+		s.m.Unlock()
+		s.m.RLock()
+	}
+	defer s.m.RUnlock()
+
+	if _, ranged := s.entries[ip]; s.blacklist[ip] && !ranged {
+		return true
+	}
+	addr, err := parseAddr(ip)
+	if nil != err {
+		return false
+	}
+	i := sort.Search(len(s.ranges), func(i int) bool { return addr.Less(s.ranges[i].From) }) - 1
+	return i >= 0 && !s.ranges[i].To.Less(addr) && s.ranges[i].From.Is4() == addr.Is4()
 }
 
+// blacklistListener refuses the connections of blacklisted IP addresses.
+// Connections without an IP address, such as those of a Unix socket, I2P
+// or onion listener, are let through.
 type blacklistListener struct {
-	*net.TCPListener
+	net.Listener
 	blacklist *Blacklist
+	// blocked, if set, counts the refused connections
+	blocked *atomic.Int64
 }
 
+// Accept returns the next connection which isn't blacklisted. Refused
+// connections are closed without returning an error, as http.Server backs
+// off after one and a blacklisted host reconnecting would stall it.
 func (ln blacklistListener) Accept() (net.Conn, error) {
-	tc, err := ln.AcceptTCP()
-	if err != nil {
-		return nil, err
-	}
+	for {
+		conn, err := ln.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
 
-	ip, _, err := net.SplitHostPort(tc.RemoteAddr().String())
-	if err != nil {
-		tc.Close()
-		return tc, err
+		ip := remoteIP(conn.RemoteAddr())
+		if ip == "" || !ln.blacklist.isBlocked(ip) {
+			return conn, nil
+		}
+		conn.Close()
+		if ln.blocked != nil {
+			ln.blocked.Add(1)
+		}
 	}
+}
 
-	if ln.blacklist.isBlocked(ip) {
-		tc.Close()
-		return nil, errors.New("connection rejected: IP address is blacklisted")
+// remoteIP returns the IP address of addr, or "" if it has none.
+func remoteIP(addr net.Addr) string {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		return addr.IP.String()
+	case *net.UDPAddr:
+		return addr.IP.String()
 	}
-
-	return tc, err
+	return ""
 }
 
 func newBlacklistListener(ln net.Listener, bl *Blacklist) blacklistListener {
-	return blacklistListener{ln.(*net.TCPListener), bl}
+	return blacklistListener{Listener: ln, blacklist: bl}
 }
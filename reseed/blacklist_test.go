@@ -1,11 +1,13 @@
 package reseed
 
 import (
+	"errors"
 	"net"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -252,8 +254,8 @@ func TestNewBlacklistListener(t *testing.T) {
 		t.Error("blacklist reference not set correctly")
 	}
 
-	if blListener.TCPListener == nil {
-		t.Error("TCPListener not set correctly")
+	if blListener.Listener != listener {
+		t.Error("Listener not set correctly")
 	}
 }
 
@@ -294,7 +296,7 @@ func TestBlacklistListener_Accept_AllowedConnection(t *testing.T) {
 
 func TestBlacklistListener_Accept_BlockedConnection(t *testing.T) {
 	bl := NewBlacklist()
-	bl.BlockIP("127.0.0.1")
+	bl.BlockUntil("127.0.0.1", time.Now().Add(500*time.Millisecond))
 
 	// Create a test TCP listener
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
@@ -303,34 +305,53 @@ func TestBlacklistListener_Accept_BlockedConnection(t *testing.T) {
 	}
 	defer listener.Close()
 
+	var blocked atomic.Int64
 	blListener := newBlacklistListener(listener, bl)
+	blListener.blocked = &blocked
 
-	// Create a connection in a goroutine
+	accepted := make(chan net.Conn, 1)
 	go func() {
-		time.Sleep(10 * time.Millisecond)
-		conn, err := net.Dial("tcp", listener.Addr().String())
-		if err == nil {
-			// Connection might be closed immediately, but that's expected
-			conn.Close()
+		conn, err := blListener.Accept()
+		if err != nil {
+			t.Errorf("Accept() failed: %v", err)
 		}
+		accepted <- conn
 	}()
 
-	conn, err := blListener.Accept()
-	// For blocked connections, Accept should return an error
-	if err == nil {
-		t.Error("Accept() should return an error for blocked connections")
-		if conn != nil {
-			conn.Close()
-		}
+	// the blacklisted connection is closed and Accept keeps waiting
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
 	}
-
-	if conn != nil {
-		t.Error("Accept() should return nil connection for blocked IPs")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the blacklisted connection to be closed")
+	}
+	conn.Close()
+	select {
+	case conn := <-accepted:
+		t.Errorf("Accept() returned the blacklisted connection %v", conn)
+	default:
+	}
+	if blocked.Load() != 1 {
+		t.Errorf("Expected 1 blocked connection, got %d", blocked.Load())
 	}
 
-	// Check that the error message is appropriate
-	if err != nil && !strings.Contains(err.Error(), "blacklisted") {
-		t.Errorf("Expected error message to contain 'blacklisted', got: %v", err)
+	// once the entry expires, the next connection is accepted
+	time.Sleep(600 * time.Millisecond)
+	conn, err = net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	select {
+	case c := <-accepted:
+		if c == nil {
+			t.Fatal("Accept() returned a nil connection")
+		}
+		c.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept() didn't return the allowed connection")
 	}
 }
 
@@ -343,36 +364,31 @@ func TestBlacklistListener_Accept_ErrorBehavior(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create test listener: %v", err)
 	}
-	defer listener.Close()
 
 	blListener := newBlacklistListener(listener, bl)
 
-	// Create a connection from the blacklisted IP
+	// Create a connection from the blacklisted IP, then stop listening
 	go func() {
 		time.Sleep(10 * time.Millisecond)
 		conn, err := net.Dial("tcp", listener.Addr().String())
 		if err == nil {
-			defer conn.Close()
-			// Try to write some data to ensure connection is established
 			conn.Write([]byte("test"))
+			conn.Close()
 		}
+		time.Sleep(50 * time.Millisecond)
+		listener.Close()
 	}()
 
 	conn, err := blListener.Accept()
 
-	// Verify the error behavior
-	if err == nil {
-		t.Fatal("Expected error for blacklisted IP, got nil")
+	// only the listener's own error is returned
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Expected the listener's error, got %v", err)
 	}
 
 	if conn != nil {
 		t.Error("Expected nil connection for blacklisted IP, got non-nil")
 	}
-
-	expectedErrMsg := "connection rejected: IP address is blacklisted"
-	if err.Error() != expectedErrMsg {
-		t.Errorf("Expected error message '%s', got '%s'", expectedErrMsg, err.Error())
-	}
 }
 
 func TestBlacklist_ThreadSafety(t *testing.T) {
@@ -411,3 +427,127 @@ func TestBlacklist_ThreadSafety(t *testing.T) {
 
 	// If we get here without data races, the test passes
 }
+
+func TestBlacklist_Replace(t *testing.T) {
+	bl := NewBlacklist()
+	bl.BlockIP("192.168.1.1")
+	bl.BlockIP("192.168.1.2")
+
+	other := NewBlacklist()
+	other.BlockIP("192.168.1.2")
+	other.BlockIP("10.0.0.1")
+	other.BlockIP("10.0.0.2")
+
+	added, removed := bl.Replace(other)
+	if added != 2 || removed != 1 {
+		t.Errorf("Replace() = %d added, %d removed, want 2 and 1", added, removed)
+	}
+	if bl.Len() != 3 || bl.isBlocked("192.168.1.1") || !bl.isBlocked("10.0.0.1") {
+		t.Errorf("blacklist after Replace() = %v", bl.blacklist)
+	}
+
+	// the blacklists don't share their addresses afterwards
+	other.BlockIP("10.0.0.3")
+	if bl.isBlocked("10.0.0.3") {
+		t.Error("Replace() kept a reference to the other blacklist")
+	}
+}
+
+func TestBlacklist_Ranges(t *testing.T) {
+	bl := NewBlacklist()
+	bl.BlockIP("192.0.2.0/24")
+	bl.BlockIP("2001:db8:1:2::/64")
+	bl.BlockIP("198.51.100.10-198.51.100.20")
+	bl.BlockIP("203.0.113.7")
+
+	for ip, want := range map[string]bool{
+		"192.0.2.0":               true,
+		"192.0.2.255":             true,
+		"192.0.3.0":               false,
+		"::ffff:192.0.2.9":        true,
+		"2001:db8:1:2:ffff::1":    true,
+		"2001:db8:1:3::1":         false,
+		"198.51.100.9":            false,
+		"198.51.100.10":           true,
+		"198.51.100.20":           true,
+		"198.51.100.21":           false,
+		"203.0.113.7":             true,
+		"203.0.113.8":             false,
+		"fe80::1%eth0":            false,
+		"2001:db8:1:2::1%eth0":    true,
+		"not.an.ip":               false,
+		"::ffff:198.51.100.15":    true,
+		"2001:db8:1:2:0:0:0:abcd": true,
+	} {
+		if got := bl.isBlocked(ip); got != want {
+			t.Errorf("isBlocked(%q) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestBlacklist_LoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "blacklist.txt")
+	contents := "# blocked for abuse\r\n" +
+		"192.0.2.1\r\n" +
+		"198.51.100.0/24 # a whole network\r\n" +
+		"2001:db8::/32\texpires=2999-01-01\r\n" +
+		"203.0.113.1 expires=2000-01-01\r\n" +
+		"not an address\r\n"
+	if err := os.WriteFile(plain, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	i2p := filepath.Join(dir, "blocklist.txt")
+	contents = "# I2P blocklist\n" +
+		"Spammer:203.0.113.10-203.0.113.20\n" +
+		"Scanner:2001;db9::1\n" +
+		"Bad router:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"
+	if err := os.WriteFile(i2p, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bl := NewBlacklist()
+	stats, err := bl.LoadFiles(plain, i2p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := BlacklistStats{Files: 2, Addresses: 2, Ranges: 3, Expired: 1, Skipped: 2}
+	if stats != want {
+		t.Errorf("LoadFiles() = %+v, want %+v", stats, want)
+	}
+	for ip, want := range map[string]bool{
+		"192.0.2.1":        true,
+		"198.51.100.200":   true,
+		"2001:db8:ffff::1": true,
+		"203.0.113.1":      false,
+		"203.0.113.15":     true,
+		"2001:db9::1":      true,
+		"":                 false,
+	} {
+		if got := bl.isBlocked(ip); got != want {
+			t.Errorf("isBlocked(%q) = %v, want %v", ip, got, want)
+		}
+	}
+
+	if _, err := bl.LoadFiles(plain, filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("LoadFiles() of a missing file didn't fail")
+	}
+}
+
+func TestBlacklist_BlockUntil(t *testing.T) {
+	bl := NewBlacklist()
+	bl.BlockUntil("192.0.2.0/24", time.Now().Add(50*time.Millisecond))
+	bl.BlockUntil("198.51.100.1", time.Now().Add(50*time.Millisecond))
+	bl.BlockIP("192.0.2.128/25")
+	if !bl.isBlocked("192.0.2.1") || !bl.isBlocked("198.51.100.1") {
+		t.Fatal("an entry isn't blocked before it expires")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if bl.isBlocked("192.0.2.1") || bl.isBlocked("198.51.100.1") {
+		t.Error("an entry is blocked after it expired")
+	}
+	if !bl.isBlocked("192.0.2.200") {
+		t.Error("the range which doesn't expire isn't blocked")
+	}
+}
@@ -0,0 +1,229 @@
+package reseed
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ACMETLSALPNProtocol is the ALPN protocol of ACME TLS-ALPN-01 validation
+// (RFC 8737).
+const ACMETLSALPNProtocol = "acme-tls/1"
+
+// CertificateStore holds the certificates a TLS listener serves, so they can
+// be reloaded while the listener runs. Clients get the certificate for the
+// name they ask for with SNI, or else the first one added. The store also
+// answers ACME TLS-ALPN-01 validation on the same listener.
+type CertificateStore struct {
+	// Passphrase unlocks encrypted private keys
+	Passphrase PassphraseFunc
+
+	mu         sync.RWMutex
+	pairs      []*storedPair
+	challenges map[string]*tls.Certificate
+	listening  atomic.Bool
+}
+
+// storedPair is a certificate and the files it was loaded from.
+type storedPair struct {
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	// state is the size and modification time of both files when loaded
+	state string
+}
+
+func NewCertificateStore(passphrase PassphraseFunc) *CertificateStore {
+	return &CertificateStore{
+		Passphrase: passphrase,
+		challenges: make(map[string]*tls.Certificate),
+	}
+}
+
+// Add loads the certificate pair from certFile and keyFile and serves it
+// from now on, replacing the pair loaded from the same certFile. Nothing
+// changes if the pair can't be read.
+func (s *CertificateStore) Add(certFile, keyFile string) error {
+	pair, err := s.load(certFile, keyFile)
+	if nil != err {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.pairs {
+		if p.certFile == certFile {
+			s.pairs[i] = pair
+			return nil
+		}
+	}
+	s.pairs = append(s.pairs, pair)
+	return nil
+}
+
+func (s *CertificateStore) load(certFile, keyFile string) (*storedPair, error) {
+	state := pairState(certFile, keyFile)
+	cert, err := LoadX509KeyPair(certFile, keyFile, s.Passphrase)
+	if nil != err {
+		return nil, err
+	}
+	return &storedPair{certFile: certFile, keyFile: keyFile, cert: &cert, state: state}, nil
+}
+
+// pairState describes the size and modification time of a certificate
+// pair's files, to notice when they change.
+func pairState(certFile, keyFile string) string {
+	var b strings.Builder
+	for _, path := range []string{certFile, keyFile} {
+		info, err := os.Stat(path)
+		if nil != err {
+			b.WriteString("missing;")
+			continue
+		}
+		fmt.Fprintf(&b, "%d:%d;", info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// Reload loads every certificate pair again. A pair which can't be read
+// keeps being served as it was.
+func (s *CertificateStore) Reload() error {
+	return s.reload(false)
+}
+
+// Check loads every certificate pair again without serving them, to find
+// out whether Reload would fail.
+func (s *CertificateStore) Check() error {
+	s.mu.RLock()
+	pairs := append([]*storedPair(nil), s.pairs...)
+	s.mu.RUnlock()
+
+	var errs []error
+	for _, p := range pairs {
+		if _, err := s.load(p.certFile, p.keyFile); nil != err {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reload loads the certificate pairs again, or only those whose files
+// changed since they were loaded.
+func (s *CertificateStore) reload(changedOnly bool) error {
+	s.mu.RLock()
+	pairs := append([]*storedPair(nil), s.pairs...)
+	s.mu.RUnlock()
+
+	var errs []error
+	for _, p := range pairs {
+		if changedOnly && pairState(p.certFile, p.keyFile) == p.state {
+			continue
+		}
+		pair, err := s.load(p.certFile, p.keyFile)
+		if nil != err {
+			errs = append(errs, fmt.Errorf("keeping the loaded %s: %s", p.certFile, err))
+			continue
+		}
+		s.mu.Lock()
+		for i := range s.pairs {
+			if s.pairs[i] == p {
+				s.pairs[i] = pair
+			}
+		}
+		s.mu.Unlock()
+		log.Printf("Loaded TLS certificate %s, valid until %s\n", p.certFile, pair.cert.Leaf.NotAfter.UTC().Format(time.RFC3339))
+	}
+	return errors.Join(errs...)
+}
+
+// Watch reloads the certificate pairs whose files change, checking every
+// interval until ctx is done.
+func (s *CertificateStore) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.reload(true); nil != err {
+					log.Println(err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Len returns the number of certificate pairs in the store.
+func (s *CertificateStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.pairs)
+}
+
+// SetChallenge answers TLS-ALPN-01 validation of domain with cert.
+func (s *CertificateStore) SetChallenge(domain string, cert *tls.Certificate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.challenges[strings.ToLower(domain)] = cert
+}
+
+// ClearChallenge stops answering TLS-ALPN-01 validation of domain.
+func (s *CertificateStore) ClearChallenge(domain string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.challenges, strings.ToLower(domain))
+}
+
+// Listening reports whether a listener is serving the store, and so can
+// answer TLS-ALPN-01 validation.
+func (s *CertificateStore) Listening() bool {
+	return s.listening.Load()
+}
+
+// GetCertificate returns the certificate for the name the client asked for,
+// or else the first one, for tls.Config.
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.pairs) == 0 {
+		return nil, errors.New("no TLS certificate loaded")
+	}
+	if hello.ServerName != "" {
+		for _, p := range s.pairs {
+			if p.cert.Leaf != nil && nil == p.cert.Leaf.VerifyHostname(hello.ServerName) {
+				return p.cert, nil
+			}
+		}
+	}
+	return s.pairs[0].cert, nil
+}
+
+// GetConfigForClient answers TLS-ALPN-01 validation, which only offers the
+// acme-tls/1 protocol, with the challenge certificate. Any other client gets
+// the listener's own configuration.
+func (s *CertificateStore) GetConfigForClient(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+	if len(hello.SupportedProtos) != 1 || hello.SupportedProtos[0] != ACMETLSALPNProtocol {
+		return nil, nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cert, ok := s.challenges[strings.ToLower(hello.ServerName)]
+	if !ok {
+		return nil, errors.New("no ACME challenge for " + hello.ServerName)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		NextProtos:   []string{ACMETLSALPNProtocol},
+	}, nil
+}
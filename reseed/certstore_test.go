@@ -0,0 +1,193 @@
+package reseed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestPair writes a self-signed certificate for host, with cn as its
+// common name, and its key, returning their paths.
+func writeTestPair(t *testing.T, dir, host, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, host+".crt")
+	keyFile := filepath.Join(dir, host+".pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// servedCertificate connects to addr and returns the common name of the
+// certificate served, and the negotiated protocol.
+func servedCertificate(addr, serverName string, protos []string) (string, string, error) {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		ServerName:         serverName,
+		NextProtos:         protos,
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	return state.PeerCertificates[0].Subject.CommonName, state.NegotiatedProtocol, nil
+}
+
+func startTestTLSListener(t *testing.T, certs *CertificateStore) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate:     certs.GetCertificate,
+		GetConfigForClient: certs.GetConfigForClient,
+		NextProtos:         []string{"http/1.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestCertificateStore(t *testing.T) {
+	dir := t.TempDir()
+	certs := NewCertificateStore(nil)
+	if err := certs.Add(writeTestPair(t, dir, "reseed.example", "first")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := certs.Add(writeTestPair(t, dir, "other.example", "other")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := certs.Add(filepath.Join(dir, "missing.crt"), filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("Expected an error for a missing pair")
+	}
+	addr := startTestTLSListener(t, certs)
+
+	for _, tt := range []struct{ serverName, want string }{
+		{"reseed.example", "first"},
+		{"other.example", "other"},
+		{"unknown.example", "first"},
+		{"", "first"},
+	} {
+		if cn, _, err := servedCertificate(addr, tt.serverName, nil); err != nil || cn != tt.want {
+			t.Errorf("Expected %q for %q, got %q, %v", tt.want, tt.serverName, cn, err)
+		}
+	}
+
+	// replaced files are served after a reload, broken ones are not
+	writeTestPair(t, dir, "reseed.example", "second")
+	os.WriteFile(filepath.Join(dir, "other.example.pem"), []byte("broken"), 0o600)
+	if err := certs.Reload(); err == nil {
+		t.Error("Expected Reload to report the broken pair")
+	}
+	if cn, _, err := servedCertificate(addr, "reseed.example", nil); err != nil || cn != "second" {
+		t.Errorf("Expected the reloaded certificate, got %q, %v", cn, err)
+	}
+	if cn, _, err := servedCertificate(addr, "other.example", nil); err != nil || cn != "other" {
+		t.Errorf("Expected the broken pair to keep being served, got %q, %v", cn, err)
+	}
+}
+
+func TestCertificateStore_Watch(t *testing.T) {
+	dir := t.TempDir()
+	certs := NewCertificateStore(nil)
+	certFile, keyFile := writeTestPair(t, dir, "reseed.example", "first")
+	if err := certs.Add(certFile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	addr := startTestTLSListener(t, certs)
+	certs.Watch(t.Context(), 10*time.Millisecond)
+
+	writeTestPair(t, dir, "reseed.example", "second")
+	// make sure the modification time changes on coarse filesystems
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cn, _, err := servedCertificate(addr, "reseed.example", nil)
+		if err == nil && cn == "second" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the changed certificate to be reloaded, got %q, %v", cn, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCertificateStore_TLSALPNChallenge(t *testing.T) {
+	dir := t.TempDir()
+	certs := NewCertificateStore(nil)
+	if err := certs.Add(writeTestPair(t, dir, "reseed.example", "served")); err != nil {
+		t.Fatal(err)
+	}
+	addr := startTestTLSListener(t, certs)
+
+	challenge := testTLSCertificate(t, "challenge")
+	certs.SetChallenge("Reseed.Example", &challenge)
+	cn, proto, err := servedCertificate(addr, "reseed.example", []string{ACMETLSALPNProtocol})
+	if err != nil || cn != "challenge" || proto != ACMETLSALPNProtocol {
+		t.Fatalf("Expected the challenge certificate over %s, got %q over %q, %v", ACMETLSALPNProtocol, cn, proto, err)
+	}
+	if cn, _, err := servedCertificate(addr, "reseed.example", []string{"http/1.1"}); err != nil || cn != "served" {
+		t.Errorf("Expected the served certificate for other clients, got %q, %v", cn, err)
+	}
+
+	certs.ClearChallenge("reseed.example")
+	if _, _, err := servedCertificate(addr, "reseed.example", []string{ACMETLSALPNProtocol}); err == nil {
+		t.Error("Expected validation to fail without a challenge")
+	}
+}
+
+func testTLSCertificate(t *testing.T, cn string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := issueCertificate(t, cn, key, false, nil, nil)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
@@ -0,0 +1,187 @@
+package reseed
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+// DirReseeder serves su3 files which were built and signed elsewhere, so the
+// signing key never has to be on the serving host. Every su3 file in Path is
+// checked against Certificate before it is served, and Path is reloaded
+// whenever its su3 files change.
+type DirReseeder struct {
+	Path        string
+	SignerID    []byte
+	Certificate *x509.Certificate
+	// CheckInterval is how often Path is checked for changes
+	CheckInterval time.Duration
+
+	su3s  chan [][]byte
+	stop  <-chan struct{}
+	state string
+}
+
+func NewDirReseeder(path string, signerID []byte, cert *x509.Certificate) *DirReseeder {
+	return &DirReseeder{
+		Path:          path,
+		SignerID:      signerID,
+		Certificate:   cert,
+		CheckInterval: time.Minute,
+		su3s:          make(chan [][]byte),
+	}
+}
+
+// Start loads the su3 files in Path, and reloads them when they change
+// until ctx is done. The returned channel is closed when watching stopped.
+// No su3 file is served once ctx is done.
+func (rs *DirReseeder) Start(ctx context.Context) <-chan struct{} {
+	rs.stop = ctx.Done()
+	// atomic swapper
+	go swapSu3s(rs.su3s, rs.stop)
+
+	// init the cache
+	rs.state, _ = rs.dirState()
+	if err := rs.reload(); nil != err {
+		log.Println(err)
+	}
+
+	interval := rs.CheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				state, err := rs.dirState()
+				if nil != err {
+					log.Println(err)
+					continue
+				}
+				if state == rs.state {
+					continue
+				}
+				rs.state = state
+				if err := rs.reload(); nil != err {
+					log.Println(err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+func (rs *DirReseeder) PeerSu3Bytes(peer Peer) ([]byte, error) {
+	return peerSu3Bytes(rs.su3s, rs.stop, peer)
+}
+
+// su3Files returns the su3 files in Path, ordered by name.
+func (rs *DirReseeder) su3Files() ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(rs.Path)
+	if nil != err {
+		return nil, err
+	}
+	var files []os.DirEntry
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".su3") {
+			files = append(files, entry)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+	return files, nil
+}
+
+// dirState summarizes the names, sizes and modification times of the su3
+// files in Path, so changes can be noticed without reading them.
+func (rs *DirReseeder) dirState() (string, error) {
+	files, err := rs.su3Files()
+	if nil != err {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		info, err := file.Info()
+		if nil != err {
+			// removed since the directory was read
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", file.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// reload reads and verifies the su3 files in Path and serves those which
+// pass. If none pass, the current set is kept.
+func (rs *DirReseeder) reload() error {
+	log.Printf("Loading su3 files from %s...\n", rs.Path)
+
+	files, err := rs.su3Files()
+	if nil != err {
+		return fmt.Errorf("unable to read su3 directory: %s", err)
+	}
+
+	var newSu3s [][]byte
+	for _, file := range files {
+		path := filepath.Join(rs.Path, file.Name())
+		data, err := os.ReadFile(path)
+		if nil != err {
+			log.Println(err)
+			continue
+		}
+		if err := rs.verify(data); nil != err {
+			log.Printf("Not serving %s: %s\n", path, err)
+			continue
+		}
+		newSu3s = append(newSu3s, data)
+	}
+
+	if len(newSu3s) == 0 {
+		return errors.New("no valid su3 files in " + rs.Path + ", keeping the current set")
+	}
+
+	select {
+	case rs.su3s <- newSu3s:
+	case <-rs.stop:
+		return errReseederStopped
+	}
+	log.Printf("Serving %d of %d su3 files from %s\n", len(newSu3s), len(files), rs.Path)
+
+	return nil
+}
+
+// verify checks that data is a reseed su3 file from SignerID with a valid
+// signature from Certificate.
+func (rs *DirReseeder) verify(data []byte) error {
+	su3File := su3.New()
+	if err := su3File.UnmarshalBinary(data); nil != err {
+		return err
+	}
+	if su3File.FileType != su3.FileTypeZIP || su3File.ContentType != su3.ContentTypeReseed {
+		return errors.New("not a reseed su3 file")
+	}
+	if string(su3File.SignerID) != string(rs.SignerID) {
+		return fmt.Errorf("signed by %q, expected %q", su3File.SignerID, rs.SignerID)
+	}
+	// UnmarshalBinary ignores short reads and trailing bytes
+	if marshalled, _ := su3File.MarshalBinary(); len(marshalled) != len(data) {
+		return errors.New("file is truncated or has trailing data")
+	}
+	return su3File.VerifySignature(rs.Certificate)
+}
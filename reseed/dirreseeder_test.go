@@ -0,0 +1,129 @@
+package reseed
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func testSignedSu3(t *testing.T, key *rsa.PrivateKey, signerID, content string) []byte {
+	t.Helper()
+	su3File := su3.New()
+	su3File.FileType = su3.FileTypeZIP
+	su3File.ContentType = su3.ContentTypeReseed
+	su3File.SignerID = []byte(signerID)
+	su3File.Content = []byte(content)
+	if err := su3File.Sign(key); err != nil {
+		t.Fatalf("Failed to sign su3 file: %v", err)
+	}
+	data, err := su3File.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Failed to marshal su3 file: %v", err)
+	}
+	return data
+}
+
+func TestDirReseeder(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	dir := t.TempDir()
+	good := testSignedSu3(t, key, "test@mail.i2p", "good")
+	writes := map[string][]byte{
+		"i2pseeds-000.su3":      good,
+		"i2pseeds-001.su3":      testSignedSu3(t, otherKey, "test@mail.i2p", "wrong key"),
+		"i2pseeds-002.su3":      testSignedSu3(t, key, "other@mail.i2p", "wrong signer"),
+		"i2pseeds-003.su3":      good[:len(good)-10],
+		"i2pseeds-004.su3.sig":  []byte("not an su3 file"),
+		"i2pseeds-005.unsigned": []byte("not an su3 file"),
+	}
+	for name, data := range writes {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	rs := NewDirReseeder(dir, []byte("test@mail.i2p"), cert)
+	rs.CheckInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := rs.Start(ctx)
+
+	// only the validly signed file may be served, to every peer
+	for _, peer := range []Peer{"1.2.3.4", "5.6.7.8", "9.10.11.12"} {
+		data, err := rs.PeerSu3Bytes(peer)
+		if err != nil {
+			t.Fatalf("PeerSu3Bytes() failed: %v", err)
+		}
+		if !bytes.Equal(data, good) {
+			t.Errorf("Peer %s should get the only valid su3 file", peer)
+		}
+	}
+
+	// a new file is picked up without a restart
+	added := testSignedSu3(t, key, "test@mail.i2p", "added")
+	if err := os.WriteFile(filepath.Join(dir, "i2pseeds-006.su3"), added, 0o644); err != nil {
+		t.Fatalf("Failed to write su3 file: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		served := map[string]bool{}
+		for i := 0; i < 64; i++ {
+			data, _ := rs.PeerSu3Bytes(Peer(string(rune('a' + i))))
+			served[string(data)] = true
+		}
+		if served[string(added)] && served[string(good)] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the new su3 file to be served")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// emptying the directory keeps the last good set
+	for _, name := range []string{"i2pseeds-000.su3", "i2pseeds-006.su3"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("Failed to remove %s: %v", name, err)
+		}
+	}
+	if err := rs.reload(); err == nil {
+		t.Error("reload() should fail without any valid su3 files")
+	}
+	if _, err := rs.PeerSu3Bytes("1.2.3.4"); err != nil {
+		t.Errorf("The last good set should still be served: %v", err)
+	}
+
+	// nothing is served once the reseeder is stopped
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the reseeder to stop")
+	}
+	if _, err := rs.PeerSu3Bytes("1.2.3.4"); err != errReseederStopped {
+		t.Errorf("PeerSu3Bytes() after stopping = %v, want %v", err, errReseederStopped)
+	}
+}
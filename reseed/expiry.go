@@ -0,0 +1,246 @@
+package reseed
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Warning levels of a certificate nearing its expiry. The monitor logs a
+// certificate once when it reaches the notice level, and on every check from
+// the warning level on.
+const (
+	expiryOK = iota
+	expiryNotice
+	expiryWarning
+	expiryCritical
+	expiryExpired
+)
+
+var expiryLabels = []string{"", "Notice", "WARNING", "CRITICAL", "EXPIRED"}
+
+// expiryLevel returns the warning level of a certificate with left to live.
+func expiryLevel(left time.Duration) int {
+	switch {
+	case left <= 0:
+		return expiryExpired
+	case left <= 24*time.Hour:
+		return expiryCritical
+	case left <= 7*24*time.Hour:
+		return expiryWarning
+	case left <= 30*24*time.Hour:
+		return expiryNotice
+	default:
+		return expiryOK
+	}
+}
+
+// ExpiryMonitor watches the lifetimes of the certificates a running server
+// depends on. Certificates are read again on every check, so replacing a
+// file is noticed. A certificate with a renew function is renewed when it
+// gets within RenewBefore of its expiry.
+type ExpiryMonitor struct {
+	// Interval is how often the certificates are checked
+	Interval time.Duration
+	// RenewBefore is how long before its expiry a certificate is renewed
+	RenewBefore time.Duration
+
+	// mu guards certs, and checking is held while they are checked and
+	// renewed, which can take minutes
+	mu       sync.Mutex
+	checking sync.Mutex
+	certs    []*monitoredCert
+	now      func() time.Time
+}
+
+type monitoredCert struct {
+	name  string
+	path  string
+	renew func() error
+
+	notAfter time.Time
+	err      error
+	level    int
+}
+
+func NewExpiryMonitor() *ExpiryMonitor {
+	return &ExpiryMonitor{
+		Interval:    12 * time.Hour,
+		RenewBefore: 30 * 24 * time.Hour,
+		now:         time.Now,
+	}
+}
+
+// Add watches the certificate at path, described as name in the log. renew
+// may be nil; otherwise it is called to replace the certificate at path
+// before it expires. A path which is already watched is not added again.
+func (m *ExpiryMonitor) Add(name, path string, renew func() error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, cert := range m.certs {
+		if cert.path == path {
+			if cert.renew == nil {
+				cert.renew = renew
+			}
+			return
+		}
+	}
+	m.certs = append(m.certs, &monitoredCert{name: name, path: path, renew: renew})
+}
+
+// Start checks the certificates now and then every Interval, until ctx is
+// done.
+func (m *ExpiryMonitor) Start(ctx context.Context) {
+	m.Check()
+
+	interval := m.Interval
+	if interval <= 0 {
+		interval = 12 * time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				m.Check()
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Check reads every certificate, logs the ones nearing their expiry and
+// renews those which can be. The certificates are renewed without holding
+// the lock, so DaysLeft and Summary don't wait for a renewal, and a Check
+// while another runs returns at once.
+func (m *ExpiryMonitor) Check() {
+	if !m.checking.TryLock() {
+		log.Println("A certificate expiry check is already running")
+		return
+	}
+	defer m.checking.Unlock()
+
+	m.mu.Lock()
+	certs := make([]*monitoredCert, len(m.certs))
+	checked := make([]monitoredCert, len(m.certs))
+	for i, cert := range m.certs {
+		certs[i] = cert
+		checked[i] = *cert
+	}
+	m.mu.Unlock()
+
+	for i := range checked {
+		m.check(&checked[i])
+		m.mu.Lock()
+		certs[i].notAfter, certs[i].err, certs[i].level = checked[i].notAfter, checked[i].err, checked[i].level
+		m.mu.Unlock()
+	}
+}
+
+// check reads cert, renewing it if it's due, and logs its expiry. cert is
+// a copy which the caller stores.
+func (m *ExpiryMonitor) check(cert *monitoredCert) {
+	cert.notAfter, cert.err = certificateNotAfter(cert.path)
+	if nil != cert.err {
+		log.Printf("Unable to check the expiry of %s: %s\n", cert.name, cert.err)
+		return
+	}
+	left := cert.notAfter.Sub(m.now())
+
+	if cert.renew != nil && left < m.RenewBefore {
+		log.Printf("Renewing %s, which expires %s\n", cert.name, cert.notAfter.UTC().Format(time.RFC3339))
+		if err := cert.renew(); nil != err {
+			log.Printf("Unable to renew %s: %s\n", cert.name, err)
+		} else if notAfter, err := certificateNotAfter(cert.path); nil != err {
+			log.Printf("Unable to read the renewed %s: %s\n", cert.name, err)
+		} else {
+			log.Printf("Renewed %s, it now expires %s\n", cert.name, notAfter.UTC().Format(time.RFC3339))
+			cert.notAfter = notAfter
+			left = notAfter.Sub(m.now())
+		}
+	}
+
+	level := expiryLevel(left)
+	if level > cert.level || level >= expiryWarning {
+		if level == expiryExpired {
+			log.Printf("%s: %s expired on %s\n", expiryLabels[level], cert.name, cert.notAfter.UTC().Format(time.RFC3339))
+		} else {
+			log.Printf("%s: %s expires on %s, in %s\n", expiryLabels[level], cert.name, cert.notAfter.UTC().Format(time.RFC3339), formatDaysLeft(left))
+		}
+	}
+	cert.level = level
+}
+
+// DaysLeft returns the whole days left until each certificate expires, by
+// name, as of the last check. Expired certificates have negative days left.
+func (m *ExpiryMonitor) DaysLeft() map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	days := make(map[string]int)
+	for _, cert := range m.certs {
+		if nil == cert.err && !cert.notAfter.IsZero() {
+			days[cert.name] = int(math.Floor(cert.notAfter.Sub(m.now()).Hours() / 24))
+		}
+	}
+	return days
+}
+
+// Summary describes the days left on each certificate, for the stats log.
+func (m *ExpiryMonitor) Summary() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var parts []string
+	for _, cert := range m.certs {
+		switch {
+		case nil != cert.err:
+			parts = append(parts, cert.name+": unreadable")
+		case cert.notAfter.IsZero():
+			parts = append(parts, cert.name+": unchecked")
+		default:
+			days := int(math.Floor(cert.notAfter.Sub(m.now()).Hours() / 24))
+			parts = append(parts, fmt.Sprintf("%s: %dd", cert.name, days))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatDaysLeft(left time.Duration) string {
+	days := int(left.Hours() / 24)
+	if days == 0 {
+		return "less than a day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+// certificateNotAfter returns the expiry of the first certificate in the PEM
+// file at path, which may also hold a private key.
+func certificateNotAfter(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if nil != err {
+		return time.Time{}, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return time.Time{}, fmt.Errorf("no certificate found in %s", path)
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if nil != err {
+			return time.Time{}, err
+		}
+		return cert.NotAfter, nil
+	}
+}
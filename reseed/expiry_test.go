@@ -0,0 +1,173 @@
+package reseed
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeCertificateExpiring(t *testing.T, path string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpiryLevel(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		left time.Duration
+		want int
+	}{
+		{90 * day, expiryOK},
+		{30 * day, expiryNotice},
+		{7 * day, expiryWarning},
+		{time.Hour, expiryCritical},
+		{0, expiryExpired},
+		{-day, expiryExpired},
+	}
+	for _, tt := range tests {
+		if got := expiryLevel(tt.left); got != tt.want {
+			t.Errorf("expiryLevel(%s) = %d, want %d", tt.left, got, tt.want)
+		}
+	}
+}
+
+func TestExpiryMonitor(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	signerPath := filepath.Join(dir, "signer.crt")
+	tlsPath := filepath.Join(dir, "example.com.crt")
+	writeCertificateExpiring(t, signerPath, start.Add(20*24*time.Hour))
+	writeCertificateExpiring(t, tlsPath, start.Add(60*24*time.Hour))
+
+	renewals := 0
+	m := NewExpiryMonitor()
+	m.now = func() time.Time { return now }
+	m.Add("signer certificate", signerPath, nil)
+	m.Add("TLS certificate", tlsPath, func() error {
+		renewals++
+		writeCertificateExpiring(t, tlsPath, now.Add(90*24*time.Hour))
+		return nil
+	})
+	m.Add("again", signerPath, nil)
+	m.Add("missing", filepath.Join(dir, "missing.crt"), nil)
+
+	m.Check()
+	days := m.DaysLeft()
+	if days["signer certificate"] != 20 || days["TLS certificate"] != 60 || len(days) != 2 {
+		t.Errorf("Unexpected days left %v", days)
+	}
+	if renewals != 0 {
+		t.Errorf("Expected no renewal 60 days before expiry, got %d", renewals)
+	}
+	if !strings.Contains(logged.String(), "Notice: signer certificate expires") {
+		t.Errorf("Expected a notice for the signer certificate, got %q", logged.String())
+	}
+	if got := m.Summary(); got != "signer certificate: 20d, TLS certificate: 60d, missing: unreadable" {
+		t.Errorf("Unexpected summary %q", got)
+	}
+
+	// a notice is only logged once
+	logged.Reset()
+	m.Check()
+	if strings.Contains(logged.String(), "signer certificate") {
+		t.Errorf("Expected the notice not to repeat, got %q", logged.String())
+	}
+
+	// warnings repeat on every check, and renewal starts within RenewBefore
+	now = start.Add(45 * 24 * time.Hour)
+	logged.Reset()
+	m.Check()
+	m.Check()
+	if n := strings.Count(logged.String(), "EXPIRED: signer certificate"); n != 2 {
+		t.Errorf("Expected the expiry to be logged on every check, got %q", logged.String())
+	}
+	if renewals != 1 {
+		t.Errorf("Expected one renewal, got %d", renewals)
+	}
+	if days := m.DaysLeft(); days["TLS certificate"] != 90 || days["signer certificate"] != -25 {
+		t.Errorf("Unexpected days left after renewal %v", days)
+	}
+}
+
+// TestExpiryMonitor_RenewUnlocked checks that a slow renewal doesn't block
+// the stats or another check.
+func TestExpiryMonitor_RenewUnlocked(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	path := filepath.Join(dir, "example.com.crt")
+	writeCertificateExpiring(t, path, now.Add(10*24*time.Hour))
+
+	renewing := make(chan bool)
+	release := make(chan bool)
+	m := NewExpiryMonitor()
+	m.now = func() time.Time { return now }
+	m.Add("TLS certificate", path, func() error {
+		renewing <- true
+		<-release
+		writeCertificateExpiring(t, path, now.Add(90*24*time.Hour))
+		return nil
+	})
+
+	done := make(chan bool)
+	go func() {
+		m.Check()
+		close(done)
+	}()
+	<-renewing
+
+	returned := make(chan string)
+	go func() {
+		m.Check()
+		returned <- m.Summary()
+	}()
+	select {
+	case got := <-returned:
+		if got != "TLS certificate: unchecked" {
+			t.Errorf("Unexpected summary during the renewal %q", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Check() and Summary() waited for the renewal")
+	}
+
+	close(release)
+	<-done
+	if days := m.DaysLeft(); days["TLS certificate"] != 90 {
+		t.Errorf("Unexpected days left after renewal %v", days)
+	}
+}
@@ -0,0 +1,76 @@
+package reseed
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// Identity is who a request comes from, as far as its transport can tell.
+type Identity struct {
+	// Peer chooses the su3 file served to the request
+	Peer Peer
+	// Client is what the rate limits count the request for
+	Client string
+	// Shared is set when Client stands for every peer of the transport,
+	// which then share the SharedRateLimit budget
+	Shared bool
+}
+
+// Identifier tells who the requests on a listener come from. byPrefix is
+// the server's RateLimitByPrefix.
+type Identifier func(r *http.Request, byPrefix bool) Identity
+
+// IdentifyByAddress identifies peers by their IP address, for TCP, TLS and
+// Unix socket listeners.
+func IdentifyByAddress(r *http.Request, byPrefix bool) Identity {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); nil == err {
+		host = h
+	}
+	return Identity{Peer: Peer(host), Client: rateLimitKey(r.RemoteAddr, byPrefix)}
+}
+
+// IdentifyI2PDestination identifies I2P peers by the hash of their
+// destination, as a b32.i2p address.
+func IdentifyI2PDestination(r *http.Request, byPrefix bool) Identity {
+	b32 := strings.ToLower(r.RemoteAddr)
+	if !strings.HasSuffix(b32, ".b32.i2p") {
+		addr, err := i2pkeys.NewI2PAddrFromString(r.RemoteAddr)
+		if nil != err {
+			return IdentifyByAddress(r, byPrefix)
+		}
+		b32 = addr.Base32()
+	}
+	return Identity{Peer: Peer(b32), Client: b32}
+}
+
+// IdentifyOnionStream identifies onion peers by the Tor stream, the
+// connection, a request came on. Tor doesn't tell an onion service who its
+// peers are, so each stream is served a su3 file of its own, and all onion
+// peers share one rate limit budget. Its listener needs
+// ListenerPolicy.StreamToken.
+func IdentifyOnionStream(r *http.Request, byPrefix bool) Identity {
+	stream, _ := r.Context().Value(streamKey{}).(string)
+	return Identity{Peer: Peer("onion " + stream), Client: "onion", Shared: true}
+}
+
+// streamKey finds the random token of a request's connection in its
+// context.
+type streamKey struct{}
+
+// withStreamToken gives the context of a new connection its random token.
+func withStreamToken(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, streamKey{}, SecureRandomAlphaString())
+}
+
+// identify returns who r comes from, as the listener it came on tells.
+func (srv *Server) identify(r *http.Request) Identity {
+	if l, ok := r.Context().Value(servedListenerKey{}).(*servedListener); ok && l.identify != nil {
+		return l.identify(r, srv.RateLimitByPrefix)
+	}
+	return IdentifyByAddress(r, srv.RateLimitByPrefix)
+}
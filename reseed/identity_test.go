@@ -0,0 +1,148 @@
+package reseed
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+func TestIdentifyI2PDestination(t *testing.T) {
+	dest, err := i2pkeys.NewI2PAddrFromBytes(SecureRandomBytes(391))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]string{
+		dest.Base64():          dest.Base32(),
+		dest.Base32():          dest.Base32(),
+		"192.0.2.1:1234":       "192.0.2.1",
+		"not a destination at": "not a destination at",
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		id := IdentifyI2PDestination(r, false)
+		if string(id.Peer) != want || id.Client != want || id.Shared {
+			t.Errorf("IdentifyI2PDestination(%.20s...) = %+v, want %s", addr, id, want)
+		}
+	}
+}
+
+// peerRecorder serves an su3 file and records the peers it was served to.
+type peerRecorder struct {
+	mu    sync.Mutex
+	peers []Peer
+}
+
+func (p *peerRecorder) PeerSu3Bytes(peer Peer) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.peers = append(p.peers, peer)
+	return []byte("su3"), nil
+}
+
+// TestIdentifyOnionStream checks that onion peers are told apart by stream
+// and share one rate limit budget.
+func TestIdentifyOnionStream(t *testing.T) {
+	recorder := &peerRecorder{}
+	srv := NewServer("", false)
+	srv.Reseeder = recorder
+	srv.SharedRateLimit = 2
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeListener(Listener{Listener: ln, Name: "Onion", Policy: ListenerPolicy{Identify: IdentifyOnionStream, StreamToken: true}})
+	defer srv.Shutdown(t.Context())
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+"/i2pseeds.su3", nil)
+		req.Header.Set("User-Agent", I2pUserAgent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("status codes %v, want 200 200 429 from the shared budget", codes)
+	}
+	if len(recorder.peers) != 2 || recorder.peers[0] == recorder.peers[1] {
+		t.Errorf("peers %q, want one for each stream", recorder.peers)
+	}
+}
+
+// TestIdentifyOnionStream_SharedBurst checks that one onion peer can't spend
+// more of the shared budget at once than SharedRateBurst.
+func TestIdentifyOnionStream_SharedBurst(t *testing.T) {
+	srv := NewServer("", false)
+	srv.Reseeder = staticReseeder("su3")
+	srv.SharedRateLimit = 120
+	srv.SharedRateBurst = 3
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeListener(Listener{Listener: ln, Name: "Onion", Policy: ListenerPolicy{Identify: IdentifyOnionStream, StreamToken: true}})
+	defer srv.Shutdown(t.Context())
+
+	var codes []int
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+"/i2pseeds.su3", nil)
+		req.Header.Set("User-Agent", I2pUserAgent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+	}
+	if codes[2] != http.StatusOK || codes[3] != http.StatusTooManyRequests {
+		t.Errorf("status codes %v, want 429 after a burst of 3", codes)
+	}
+}
+
+// TestIdentifyOnionStream_SharedBudget checks that the su3 and web requests
+// of onion peers draw on the same budget.
+func TestIdentifyOnionStream_SharedBudget(t *testing.T) {
+	srv := NewServer("", false)
+	srv.Reseeder = staticReseeder("su3")
+	srv.SharedRateLimit = 2
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeListener(Listener{Listener: ln, Name: "Onion", Policy: ListenerPolicy{Identify: IdentifyOnionStream, StreamToken: true}})
+	defer srv.Shutdown(t.Context())
+
+	get := func(path, userAgent string) int {
+		t.Helper()
+		req, _ := http.NewRequest("GET", "http://"+ln.Addr().String()+path, nil)
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get("/i2pseeds.su3", I2pUserAgent); code != http.StatusOK {
+		t.Fatalf("su3 request: status %d", code)
+	}
+	if code := get("/", "Mozilla/5.0"); code == http.StatusTooManyRequests {
+		t.Fatalf("web request: status %d", code)
+	}
+	if code := get("/i2pseeds.su3", I2pUserAgent); code != http.StatusTooManyRequests {
+		t.Errorf("su3 request after a web request: status %d, want 429 from the shared budget", code)
+	}
+	if code := get("/", "Mozilla/5.0"); code != http.StatusTooManyRequests {
+		t.Errorf("web request after the budget was spent: status %d, want 429", code)
+	}
+}
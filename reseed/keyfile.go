@@ -0,0 +1,267 @@
+package reseed
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptedPrivateKeyType is the PEM block type of a passphrase encrypted
+// private key. The block holds a PKCS#8 private key sealed with
+// XChaCha20-Poly1305 under a key derived from the passphrase, and its headers
+// record the KDF, its parameters, the salt and the nonce.
+const EncryptedPrivateKeyType = "RESEED ENCRYPTED PRIVATE KEY"
+
+// Key derivation functions for encrypted private keys.
+const (
+	KDFScrypt   = "scrypt"
+	KDFArgon2id = "argon2id"
+)
+
+const encryptedKeyCipher = "xchacha20-poly1305"
+
+// PassphraseFunc returns the passphrase for an encrypted private key. It is
+// only called when an encrypted key is found.
+type PassphraseFunc func() ([]byte, error)
+
+// ErrNoPassphrase is returned when a key is encrypted but no passphrase
+// was given.
+var ErrNoPassphrase = errors.New("private key is encrypted, but no passphrase was given")
+
+// EncryptPrivateKey seals key in an EncryptedPrivateKeyType PEM block with a
+// key derived from passphrase by kdf.
+func EncryptPrivateKey(key crypto.PrivateKey, passphrase []byte, kdf string) (*pem.Block, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if nil != err {
+		return nil, err
+	}
+	return sealBlock(EncryptedPrivateKeyType, der, passphrase, kdf)
+}
+
+// DecryptPrivateKey opens an EncryptedPrivateKeyType PEM block.
+func DecryptPrivateKey(block *pem.Block, passphrase []byte) (crypto.PrivateKey, error) {
+	if block.Type != EncryptedPrivateKeyType {
+		return nil, fmt.Errorf("not an encrypted private key: %s", block.Type)
+	}
+	der, err := openBlock(block, passphrase, "encrypted private key")
+	if nil != err {
+		return nil, err
+	}
+	return x509.ParsePKCS8PrivateKey(der)
+}
+
+// sealBlock seals data in a PEM block of blockType with a key derived from
+// passphrase by kdf. The headers record everything but the passphrase needed
+// to open it again.
+func sealBlock(blockType string, data, passphrase []byte, kdf string) (*pem.Block, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase cannot be empty")
+	}
+
+	var params string
+	switch kdf {
+	case KDFScrypt, "":
+		kdf, params = KDFScrypt, "N=32768,r=8,p=1"
+	case KDFArgon2id:
+		params = "t=3,m=65536,p=4"
+	default:
+		return nil, fmt.Errorf("unknown KDF %q", kdf)
+	}
+
+	salt := make([]byte, 16)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(salt); nil != err {
+		return nil, err
+	}
+	if _, err := rand.Read(nonce); nil != err {
+		return nil, err
+	}
+
+	block := &pem.Block{
+		Type: blockType,
+		Headers: map[string]string{
+			"KDF":        kdf,
+			"KDF-Params": params,
+			"Salt":       hex.EncodeToString(salt),
+			"Cipher":     encryptedKeyCipher,
+			"Nonce":      hex.EncodeToString(nonce),
+		},
+	}
+	aead, err := encryptedKeyAEAD(block, passphrase, "")
+	if nil != err {
+		return nil, err
+	}
+	block.Bytes = aead.Seal(nil, nonce, data, encryptedKeyAD(block))
+	return block, nil
+}
+
+// openBlock opens a PEM block sealed by sealBlock. what names its contents
+// in errors.
+func openBlock(block *pem.Block, passphrase []byte, what string) ([]byte, error) {
+	if block.Headers["Cipher"] != encryptedKeyCipher {
+		return nil, fmt.Errorf("unknown cipher %q", block.Headers["Cipher"])
+	}
+	nonce, err := hex.DecodeString(block.Headers["Nonce"])
+	if nil != err || len(nonce) != chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("%s has a bad nonce", what)
+	}
+	aead, err := encryptedKeyAEAD(block, passphrase, what)
+	if nil != err {
+		return nil, err
+	}
+	data, err := aead.Open(nil, nonce, block.Bytes, encryptedKeyAD(block))
+	if nil != err {
+		return nil, fmt.Errorf("wrong passphrase, or the %s has been modified", what)
+	}
+	return data, nil
+}
+
+// encryptedKeyAEAD derives the key for a sealed block from passphrase, using
+// the KDF recorded in its headers.
+func encryptedKeyAEAD(block *pem.Block, passphrase []byte, what string) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if nil != err || len(salt) < 16 {
+		return nil, fmt.Errorf("%s has a bad salt", what)
+	}
+	params, err := parseKDFParams(block.Headers["KDF-Params"])
+	if nil != err {
+		return nil, err
+	}
+
+	var key []byte
+	switch block.Headers["KDF"] {
+	case KDFScrypt:
+		key, err = scrypt.Key(passphrase, salt, params["N"], params["r"], params["p"], chacha20poly1305.KeySize)
+		if nil != err {
+			return nil, err
+		}
+	case KDFArgon2id:
+		t, m, p := params["t"], params["m"], params["p"]
+		if t < 1 || m < 8 || p < 1 || p > 255 {
+			return nil, fmt.Errorf("%s has bad argon2id parameters", what)
+		}
+		key = argon2.IDKey(passphrase, salt, uint32(t), uint32(m), uint8(p), chacha20poly1305.KeySize)
+	default:
+		return nil, fmt.Errorf("unknown KDF %q", block.Headers["KDF"])
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// encryptedKeyAD binds the KDF and its parameters to the ciphertext, so
+// they can't be weakened without the passphrase.
+func encryptedKeyAD(block *pem.Block) []byte {
+	return []byte(block.Type + "\n" + block.Headers["KDF"] + "\n" + block.Headers["KDF-Params"] + "\n" + block.Headers["Salt"] + "\n" + block.Headers["Cipher"])
+}
+
+// parseKDFParams parses KDF parameters written as "a=1,b=2".
+func parseKDFParams(s string) (map[string]int, error) {
+	params := make(map[string]int)
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad KDF parameters %q", s)
+		}
+		n, err := strconv.Atoi(v)
+		if nil != err {
+			return nil, fmt.Errorf("bad KDF parameters %q", s)
+		}
+		params[k] = n
+	}
+	return params, nil
+}
+
+// ParsePrivateKeyPEM returns the first private key in data. It reads PKCS#1
+// RSA, SEC1 EC, PKCS#8 and encrypted private keys, and skips other blocks
+// such as certificates. passphrase may be nil if the key is not encrypted.
+func ParsePrivateKeyPEM(data []byte, passphrase PassphraseFunc) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key found")
+		}
+
+		var key crypto.PrivateKey
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case EncryptedPrivateKeyType:
+			if passphrase == nil {
+				return nil, ErrNoPassphrase
+			}
+			var pass []byte
+			if pass, err = passphrase(); nil != err {
+				return nil, err
+			}
+			key, err = DecryptPrivateKey(block, pass)
+		default:
+			continue
+		}
+		if nil != err {
+			return nil, err
+		}
+
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		case ed25519.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	}
+}
+
+// LoadPrivateKey reads the first private key from the PEM file at path.
+func LoadPrivateKey(path string, passphrase PassphraseFunc) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+	key, err := ParsePrivateKeyPEM(data, passphrase)
+	if nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return key, nil
+}
+
+// LoadX509KeyPair is like tls.LoadX509KeyPair, but also reads encrypted
+// private keys.
+func LoadX509KeyPair(certFile, keyFile string, passphrase PassphraseFunc) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if nil != err {
+		return tls.Certificate{}, err
+	}
+	key, err := LoadPrivateKey(keyFile, passphrase)
+	if nil != err {
+		return tls.Certificate{}, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if nil != err {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
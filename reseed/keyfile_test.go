@@ -0,0 +1,153 @@
+package reseed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func staticPassphrase(pass string) PassphraseFunc {
+	return func() ([]byte, error) { return []byte(pass), nil }
+}
+
+func TestEncryptPrivateKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	for _, kdf := range []string{KDFScrypt, KDFArgon2id} {
+		t.Run(kdf, func(t *testing.T) {
+			block, err := EncryptPrivateKey(key, []byte("correct horse"), kdf)
+			if err != nil {
+				t.Fatalf("EncryptPrivateKey failed: %v", err)
+			}
+			if block.Type != EncryptedPrivateKeyType || block.Headers["KDF"] != kdf {
+				t.Fatalf("Unexpected block %s with KDF %q", block.Type, block.Headers["KDF"])
+			}
+			data := pem.EncodeToMemory(block)
+
+			got, err := ParsePrivateKeyPEM(data, staticPassphrase("correct horse"))
+			if err != nil {
+				t.Fatalf("ParsePrivateKeyPEM failed: %v", err)
+			}
+			if !key.Equal(got) {
+				t.Error("Decrypted key does not match")
+			}
+
+			if _, err := ParsePrivateKeyPEM(data, staticPassphrase("wrong")); err == nil {
+				t.Error("Expected an error with the wrong passphrase")
+			}
+			if _, err := ParsePrivateKeyPEM(data, nil); !errors.Is(err, ErrNoPassphrase) {
+				t.Errorf("Expected ErrNoPassphrase, got %v", err)
+			}
+
+			// weakening the KDF parameters must be detected
+			weak, _ := pem.Decode(data)
+			if kdf == KDFScrypt {
+				weak.Headers["KDF-Params"] = "N=2,r=8,p=1"
+			} else {
+				weak.Headers["KDF-Params"] = "t=1,m=8,p=1"
+			}
+			if _, err := DecryptPrivateKey(weak, []byte("correct horse")); err == nil {
+				t.Error("Expected an error with modified KDF parameters")
+			}
+		})
+	}
+
+	if _, err := EncryptPrivateKey(key, nil, KDFScrypt); err == nil {
+		t.Error("Expected an error with an empty passphrase")
+	}
+	if _, err := EncryptPrivateKey(key, []byte("x"), "md5"); err == nil {
+		t.Error("Expected an error with an unknown KDF")
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecDer, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	pkcs8Der, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"PKCS1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})},
+		// keys written by keygen --tlsHost start with the curve parameters
+		{"SEC1", append(pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: []byte{6, 5, 43, 129, 4, 0, 34}}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecDer})...)},
+		{"PKCS8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Der})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePrivateKeyPEM(tt.data, nil); err != nil {
+				t.Errorf("ParsePrivateKeyPEM failed: %v", err)
+			}
+		})
+	}
+
+	if _, err := ParsePrivateKeyPEM([]byte("not a key"), nil); err == nil {
+		t.Error("Expected an error without a private key")
+	}
+}
+
+func TestLoadX509KeyPair(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "reseed.example"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDer, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	block, err := EncryptPrivateKey(key, []byte("secret"), KDFScrypt)
+	if err != nil {
+		t.Fatalf("EncryptPrivateKey failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "host.crt")
+	keyFile := filepath.Join(dir, "host.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDer}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600)
+
+	cert, err := LoadX509KeyPair(certFile, keyFile, staticPassphrase("secret"))
+	if err != nil {
+		t.Fatalf("LoadX509KeyPair failed: %v", err)
+	}
+	if !key.Equal(cert.PrivateKey) {
+		t.Error("Loaded key does not match")
+	}
+	if _, err := LoadX509KeyPair(certFile, keyFile, nil); err == nil {
+		t.Error("Expected an error without a passphrase")
+	}
+}
@@ -1,15 +1,154 @@
 package reseed
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
 
 	"github.com/cretz/bine/tor"
 	"github.com/go-i2p/i2pkeys"
 	"github.com/go-i2p/onramp"
 )
 
+// Listener is one of the listeners a Server serves on. Every listener
+// shares the server's handlers, blacklist, rate limits and stats.
+type Listener struct {
+	net.Listener
+	// Name names the listener in logs and stats, such as "HTTPS" or "I2P"
+	Name string
+	// Policy is what the server does differently on this listener
+	Policy ListenerPolicy
+}
+
+// ListenerPolicy is how a Server treats the requests of one listener, where
+// its transport needs something the others don't.
+type ListenerPolicy struct {
+	// TrustProxy takes the client address from X-Forwarded-For, for a
+	// listener which only a reverse proxy can reach
+	TrustProxy bool
+	// Identify tells who requests come from, by their IP address if nil
+	Identify Identifier
+	// StreamToken gives each connection a random token, which
+	// IdentifyOnionStream tells streams apart by
+	StreamToken bool
+}
+
+// ListenerStats counts what a Server did on one of its listeners.
+type ListenerStats struct {
+	Name string
+	// Requests is the number of HTTP requests
+	Requests int64
+	// Su3Served is the number of su3 files served
+	Su3Served int64
+	// Blocked is the number of connections refused by the blacklist
+	Blocked int64
+}
+
+type listenerStats struct {
+	requests  atomic.Int64
+	su3Served atomic.Int64
+	blocked   atomic.Int64
+}
+
+// servedListener is the listener a request came from, found in its context
+// with servedListenerKey.
+type servedListener struct {
+	stats    *listenerStats
+	identify Identifier
+}
+
+type servedListenerKey struct{}
+
+// ServeListener serves l until it fails or the server is shut down. It can
+// be called for any number of listeners, each in its own goroutine.
+func (srv *Server) ServeListener(l Listener) error {
+	stats := srv.listenerStats(l.Name)
+	served := &servedListener{stats: stats, identify: l.Policy.Identify}
+	handler := srv.Handler
+	if l.Policy.TrustProxy {
+		handler = proxiedMiddleware(handler)
+	}
+	hs := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			stats.requests.Add(1)
+			handler.ServeHTTP(w, r)
+		}),
+		TLSConfig:         srv.TLSConfig,
+		ReadHeaderTimeout: srv.ReadHeaderTimeout,
+		ReadTimeout:       srv.ReadTimeout,
+		WriteTimeout:      srv.WriteTimeout,
+		ErrorLog:          srv.ErrorLog,
+		BaseContext: func(net.Listener) context.Context {
+			return context.WithValue(context.Background(), servedListenerKey{}, served)
+		},
+	}
+	if l.Policy.StreamToken {
+		hs.ConnContext = withStreamToken
+	}
+
+	srv.sessionsMutex.Lock()
+	if srv.closed {
+		srv.sessionsMutex.Unlock()
+		l.Close()
+		return http.ErrServerClosed
+	}
+	srv.served = append(srv.served, hs)
+	srv.sessionsMutex.Unlock()
+
+	var ln net.Listener = l.Listener
+	if srv.Blacklist != nil {
+		bl := newBlacklistListener(ln, srv.Blacklist)
+		bl.blocked = &stats.blocked
+		ln = bl
+	}
+	return hs.Serve(ln)
+}
+
+// listenerStats returns the stats of the listener called name, shared by
+// the listeners of the same name.
+func (srv *Server) listenerStats(name string) *listenerStats {
+	srv.statsMutex.Lock()
+	defer srv.statsMutex.Unlock()
+	for i, n := range srv.statsNames {
+		if n == name {
+			return srv.stats[i]
+		}
+	}
+	stats := &listenerStats{}
+	srv.statsNames = append(srv.statsNames, name)
+	srv.stats = append(srv.stats, stats)
+	return stats
+}
+
+// Stats returns the stats of each listener, in the order they were first
+// served.
+func (srv *Server) Stats() []ListenerStats {
+	srv.statsMutex.Lock()
+	defer srv.statsMutex.Unlock()
+	var all []ListenerStats
+	for i, stats := range srv.stats {
+		all = append(all, ListenerStats{
+			Name:      srv.statsNames[i],
+			Requests:  stats.requests.Load(),
+			Su3Served: stats.su3Served.Load(),
+			Blocked:   stats.blocked.Load(),
+		})
+	}
+	return all
+}
+
+// countSu3Served counts an su3 file served for the listener of ctx.
+func countSu3Served(ctx context.Context) {
+	if l, ok := ctx.Value(servedListenerKey{}).(*servedListener); ok {
+		l.stats.su3Served.Add(1)
+	}
+}
+
 func (srv *Server) ListenAndServe() error {
 	addr := srv.Addr
 	if addr == "" {
@@ -20,7 +159,22 @@ func (srv *Server) ListenAndServe() error {
 		return err
 	}
 
-	return srv.Serve(newBlacklistListener(ln, srv.Blacklist))
+	return srv.ServeListener(Listener{Listener: ln, Name: "HTTP"})
+}
+
+// ListenAndServeUnix serves plain HTTP on a Unix domain socket at path, for
+// a reverse proxy on the same host. The client address is taken from
+// X-Forwarded-For, as a socket has none.
+func (srv *Server) ListenAndServeUnix(path string) error {
+	// a socket left behind by a previous run
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return srv.ServeListener(Listener{Listener: ln, Name: "Unix", Policy: ListenerPolicy{TrustProxy: true}})
 }
 
 func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
@@ -37,20 +191,34 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 		srv.TLSConfig.NextProtos = []string{"http/1.1"}
 	}
 
-	var err error
-	srv.TLSConfig.Certificates = make([]tls.Certificate, 1)
-	srv.TLSConfig.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
-		return err
+	if srv.Certificates == nil {
+		srv.Certificates = NewCertificateStore(srv.KeyPassphrase)
+	}
+	if certFile != "" {
+		if err := srv.Certificates.Add(certFile, keyFile); err != nil {
+			return err
+		}
+	}
+	if srv.Certificates.Len() == 0 {
+		return errors.New("no TLS certificate to serve")
 	}
+	srv.TLSConfig.GetCertificate = srv.Certificates.GetCertificate
+	srv.TLSConfig.GetConfigForClient = srv.Certificates.GetConfigForClient
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
+	if srv.TLSProfile.SessionTicketRotation > 0 {
+		if err := rotateSessionTicketKeys(srv.done, srv.TLSConfig, srv.TLSProfile.SessionTicketRotation); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+	srv.Certificates.listening.Store(true)
+	defer srv.Certificates.listening.Store(false)
 
-	tlsListener := tls.NewListener(newBlacklistListener(ln, srv.Blacklist), srv.TLSConfig)
-	return srv.Serve(tlsListener)
+	return srv.ServeListener(Listener{Listener: tls.NewListener(ln, srv.TLSConfig), Name: "HTTPS"})
 }
 
 func (srv *Server) ListenAndServeOnionTLS(startConf *tor.StartConf, listenConf *tor.ListenConf, certFile, keyFile string) error {
@@ -60,13 +228,16 @@ func (srv *Server) ListenAndServeOnionTLS(startConf *tor.StartConf, listenConf *
 	if err != nil {
 		return err
 	}
+	if err := srv.keepSession(srv.Onion); err != nil {
+		return err
+	}
 	srv.OnionListener, err = srv.Onion.ListenTLS()
 	if err != nil {
 		return err
 	}
 	log.Printf("Onionv3 server started on https://%v.onion\n", srv.OnionListener.Addr().String())
 
-	return srv.Serve(srv.OnionListener)
+	return srv.ServeListener(Listener{Listener: srv.OnionListener, Name: "Onion", Policy: ListenerPolicy{Identify: IdentifyOnionStream, StreamToken: true}})
 }
 
 func (srv *Server) ListenAndServeOnion(startConf *tor.StartConf, listenConf *tor.ListenConf) error {
@@ -76,13 +247,16 @@ func (srv *Server) ListenAndServeOnion(startConf *tor.StartConf, listenConf *tor
 	if err != nil {
 		return err
 	}
+	if err := srv.keepSession(srv.Onion); err != nil {
+		return err
+	}
 	srv.OnionListener, err = srv.Onion.Listen()
 	if err != nil {
 		return err
 	}
 	log.Printf("Onionv3 server started on http://%v.onion\n", srv.OnionListener.Addr().String())
 
-	return srv.Serve(srv.OnionListener)
+	return srv.ServeListener(Listener{Listener: srv.OnionListener, Name: "Onion", Policy: ListenerPolicy{Identify: IdentifyOnionStream, StreamToken: true}})
 }
 
 func (srv *Server) ListenAndServeI2PTLS(samaddr string, I2PKeys i2pkeys.I2PKeys, certFile, keyFile string) error {
@@ -92,12 +266,15 @@ func (srv *Server) ListenAndServeI2PTLS(samaddr string, I2PKeys i2pkeys.I2PKeys,
 	if err != nil {
 		return err
 	}
+	if err := srv.keepSession(srv.Garlic); err != nil {
+		return err
+	}
 	srv.I2PListener, err = srv.Garlic.ListenTLS()
 	if err != nil {
 		return err
 	}
 	log.Printf("I2P server started on https://%v\n", srv.I2PListener.Addr().(i2pkeys.I2PAddr).Base32())
-	return srv.Serve(srv.I2PListener)
+	return srv.ServeListener(Listener{Listener: srv.I2PListener, Name: "I2P", Policy: ListenerPolicy{Identify: IdentifyI2PDestination}})
 }
 
 func (srv *Server) ListenAndServeI2P(samaddr string, I2PKeys i2pkeys.I2PKeys) error {
@@ -107,10 +284,13 @@ func (srv *Server) ListenAndServeI2P(samaddr string, I2PKeys i2pkeys.I2PKeys) er
 	if err != nil {
 		return err
 	}
+	if err := srv.keepSession(srv.Garlic); err != nil {
+		return err
+	}
 	srv.I2PListener, err = srv.Garlic.Listen()
 	if err != nil {
 		return err
 	}
 	log.Printf("I2P server started on http://%v.b32.i2p\n", srv.I2PListener.Addr().(i2pkeys.I2PAddr).Base32())
-	return srv.Serve(srv.I2PListener)
+	return srv.ServeListener(Listener{Listener: srv.I2PListener, Name: "I2P", Policy: ListenerPolicy{Identify: IdentifyI2PDestination}})
 }
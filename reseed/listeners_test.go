@@ -0,0 +1,101 @@
+package reseed
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type staticReseeder []byte
+
+func (s staticReseeder) PeerSu3Bytes(peer Peer) ([]byte, error) {
+	return s, nil
+}
+
+// TestServer_ServeListeners checks that one server serves a TCP listener
+// and a Unix socket with its blacklist and stats, and stops both.
+func TestServer_ServeListeners(t *testing.T) {
+	srv := NewServer("", false)
+	srv.Reseeder = staticReseeder("su3")
+	srv.Blacklist = NewBlacklist()
+	srv.SetRateLimits(0, 0, 0, 0)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "reseed.sock")
+	done := make(chan error, 2)
+	go func() { done <- srv.ServeListener(Listener{Listener: tcp, Name: "HTTP"}) }()
+	go func() { done <- srv.ListenAndServeUnix(socket) }()
+
+	get := func(client *http.Client, url string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("User-Agent", I2pUserAgent)
+		req.Header.Set("X-Forwarded-For", "192.0.2.1")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "su3" {
+			t.Errorf("GET %s = %d %q", url, resp.StatusCode, body)
+		}
+	}
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the Unix socket isn't listening")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	get(http.DefaultClient, "http://"+tcp.Addr().String()+"/i2pseeds.su3")
+	get(unixClient, "http://unix/i2pseeds.su3")
+
+	// a blacklisted connection is refused without stopping the listener
+	srv.Blacklist.BlockIP("127.0.0.1")
+	if resp, err := http.Get("http://" + tcp.Addr().String() + "/i2pseeds.su3"); err == nil {
+		resp.Body.Close()
+		t.Error("a blacklisted address was served")
+	}
+	get(unixClient, "http://unix/i2pseeds.su3")
+
+	stats := make(map[string]ListenerStats)
+	for _, s := range srv.Stats() {
+		stats[s.Name] = s
+	}
+	if len(stats) != 2 || stats["HTTP"].Su3Served != 1 || stats["HTTP"].Blocked != 1 ||
+		stats["Unix"].Requests != 2 || stats["Unix"].Su3Served != 2 {
+		t.Errorf("Stats() = %+v", srv.Stats())
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if srv.done.Err() == nil {
+		t.Error("Shutdown() didn't stop the loops of the listeners")
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != http.ErrServerClosed {
+			t.Errorf("ServeListener() = %v after Shutdown", err)
+		}
+	}
+	if err := srv.ServeListener(Listener{Listener: tcp, Name: "HTTP"}); err != http.ErrServerClosed {
+		t.Errorf("ServeListener() = %v after Shutdown", err)
+	}
+}
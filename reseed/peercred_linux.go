@@ -0,0 +1,37 @@
+//go:build linux
+// +build linux
+
+package reseed
+
+import (
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentials returns the credentials of the process on the other end of
+// a Unix domain socket, as recorded by the kernel when it connected.
+func peerCredentials(conn net.Conn) (peerCred, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return peerCred{}, errors.New("not a unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if nil != err {
+		return peerCred{}, err
+	}
+
+	var ucred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); nil != err {
+		return peerCred{}, err
+	}
+	if nil != credErr {
+		return peerCred{}, credErr
+	}
+
+	return peerCred{UID: int(ucred.Uid), GID: int(ucred.Gid), PID: int(ucred.Pid)}, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package reseed
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials is only implemented on Linux. Elsewhere every connection
+// to the signing daemon is refused.
+func peerCredentials(conn net.Conn) (peerCred, error) {
+	return peerCred{}, errors.New("peer credentials are not supported on this platform")
+}
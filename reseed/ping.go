@@ -87,7 +87,7 @@ func PingEverybody() []string {
 	}
 	lastPing = time.Now()
 	var nonerrs []string
-	for _, urlInput := range AllReseeds {
+	for _, urlInput := range Reseeds() {
 		err := PingWriteContent(urlInput)
 		if err == nil {
 			nonerrs = append(nonerrs, urlInput)
@@ -0,0 +1,95 @@
+package reseed
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// pkcs12Iterations is the PBKDF2 and MAC iteration count of the bundles
+// written, rather than the 2048 of OpenSSL.
+const pkcs12Iterations = 100000
+
+// EncodePKCS12 bundles key and its certificate chain, leaf first, in a
+// PKCS#12 file protected by password. Bundles are written the way current
+// Java and OpenSSL write them by default: PBES2 with PBKDF2-HMAC-SHA-256
+// and AES-256-CBC, and a HMAC-SHA-256 MAC.
+func EncodePKCS12(key crypto.PrivateKey, chain []*x509.Certificate, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("PKCS#12 password cannot be empty")
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("a PKCS#12 bundle needs the key's certificate")
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok || !KeyMatchesCertificate(signer, chain[0]) {
+		return nil, errors.New("the private key does not match the first certificate")
+	}
+	return pkcs12.Modern.WithIterations(pkcs12Iterations).Encode(key, chain[0], chain[1:], string(password))
+}
+
+// DecodePKCS12 reads the private key and certificates of a PKCS#12 bundle
+// protected by password, after checking its MAC. Bundles using the older
+// 3DES and RC2 encryption and a SHA-1 MAC, like those of older Java
+// keystores, can be read too. The key's certificate comes first; OrderChain
+// puts the others in chain order.
+func DecodePKCS12(data []byte, password []byte) (crypto.Signer, []*x509.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, string(password))
+	if nil != err {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported private key %T in the PKCS#12 bundle", key)
+	}
+	return signer, append([]*x509.Certificate{cert}, caCerts...), nil
+}
+
+// OrderChain puts certs in chain order, starting with the certificate of key
+// and followed by the certificate which issued each. Every certificate must
+// belong to the chain, and every signature in it must check out.
+func OrderChain(key crypto.Signer, certs []*x509.Certificate) ([]*x509.Certificate, error) {
+	remaining := append([]*x509.Certificate{}, certs...)
+	take := func(match func(*x509.Certificate) bool) *x509.Certificate {
+		for i, cert := range remaining {
+			if match(cert) {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				return cert
+			}
+		}
+		return nil
+	}
+
+	leaf := take(func(cert *x509.Certificate) bool { return KeyMatchesCertificate(key, cert) })
+	if leaf == nil {
+		return nil, errors.New("no certificate matches the private key")
+	}
+	chain := []*x509.Certificate{leaf}
+	for {
+		last := chain[len(chain)-1]
+		if bytes.Equal(last.RawIssuer, last.RawSubject) {
+			// self-signed certificates, like su3 signing certificates, need
+			// not be CAs
+			if err := last.CheckSignature(last.SignatureAlgorithm, last.RawTBSCertificate, last.Signature); nil != err {
+				return nil, fmt.Errorf("%s: %s", last.Subject, err)
+			}
+			break
+		}
+		issuer := take(func(cert *x509.Certificate) bool {
+			return bytes.Equal(cert.RawSubject, last.RawIssuer) && last.CheckSignatureFrom(cert) == nil
+		})
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+	}
+
+	if len(remaining) > 0 {
+		return nil, fmt.Errorf("the certificate %s is not part of the chain of %s", remaining[0].Subject, leaf.Subject)
+	}
+	return chain, nil
+}
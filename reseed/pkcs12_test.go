@@ -0,0 +1,198 @@
+package reseed
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+// issueCertificate issues a certificate for key, signed by parent and
+// parentKey, or self-signed if parent is nil.
+func issueCertificate(t *testing.T, cn string, key *ecdsa.PrivateKey, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := su3.NewSigningCertificate("test@mail.i2p", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := EncodePKCS12(rsaKey, []*x509.Certificate{cert}, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("EncodePKCS12 failed: %v", err)
+	}
+	key, certs, err := DecodePKCS12(data, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("DecodePKCS12 failed: %v", err)
+	}
+	if !rsaKey.Equal(key) || len(certs) != 1 || !certs[0].Equal(cert) {
+		t.Error("Decoded key or certificate does not match")
+	}
+
+	if _, _, err := DecodePKCS12(data, []byte("wrong")); err == nil {
+		t.Error("Expected an error with the wrong password")
+	}
+	data[len(data)-40] ^= 1
+	if _, _, err := DecodePKCS12(data, []byte("changeit")); err == nil {
+		t.Error("Expected an error for a modified bundle")
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncodePKCS12(other, []*x509.Certificate{cert}, []byte("changeit")); err == nil {
+		t.Error("Expected an error for a key which doesn't match the certificate")
+	}
+}
+
+// TestDecodePKCS12_OpenSSL reads bundles written by OpenSSL 3.0:
+//
+//	openssl pkcs12 -export -inkey signer.pem -in signer.crt -out openssl-modern.p12
+//	openssl pkcs12 -export -legacy -inkey signer.pem -in signer.crt -out openssl-legacy.p12
+//	openssl pkcs12 -export -keypbe PBE-SHA1-3DES -certpbe PBE-SHA1-3DES -macalg sha1 -inkey signer.pem -in signer.crt -out openssl-3des.p12
+//	openssl pkcs12 -export -inkey leaf.key -in leaf.crt -certfile ca.crt -out openssl-chain.p12
+//
+// all with the password changeit. The legacy bundle uses RC2 for the
+// certificates, 3DES for the key and a SHA-1 MAC, as Java 8 keytool does.
+func TestDecodePKCS12_OpenSSL(t *testing.T) {
+	for _, tc := range []struct {
+		file  string
+		chain []string
+	}{
+		{"openssl-modern.p12", []string{"test@mail.i2p"}},
+		{"openssl-legacy.p12", []string{"test@mail.i2p"}},
+		{"openssl-3des.p12", []string{"test@mail.i2p"}},
+		{"openssl-chain.p12", []string{"reseed.example.org", "Test CA"}},
+	} {
+		data, err := os.ReadFile(filepath.Join("testdata", tc.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, certs, err := DecodePKCS12(data, []byte("changeit"))
+		if err != nil {
+			t.Errorf("%s: DecodePKCS12 failed: %v", tc.file, err)
+			continue
+		}
+		chain, err := OrderChain(key, certs)
+		if err != nil {
+			t.Errorf("%s: OrderChain failed: %v", tc.file, err)
+			continue
+		}
+		var names []string
+		for _, cert := range chain {
+			names = append(names, cert.Subject.CommonName)
+		}
+		if strings.Join(names, ", ") != strings.Join(tc.chain, ", ") {
+			t.Errorf("%s: chain %v, want %v", tc.file, names, tc.chain)
+		}
+		if _, _, err := DecodePKCS12(data, []byte("wrong")); err == nil {
+			t.Errorf("%s: expected an error with the wrong password", tc.file)
+		}
+	}
+}
+
+// TestEncodePKCS12_OpenSSL checks that OpenSSL reads the bundles written by
+// EncodePKCS12, when it is installed.
+func TestEncodePKCS12_OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl is not installed")
+	}
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := issueCertificate(t, "Test CA", caKey, true, nil, nil)
+	leaf := issueCertificate(t, "reseed.example.org", leafKey, false, ca, caKey)
+
+	data, err := EncodePKCS12(leafKey, []*x509.Certificate{leaf, ca}, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("EncodePKCS12 failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.p12")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:changeit", "-nodes").CombinedOutput()
+	if err != nil {
+		t.Fatalf("openssl pkcs12 failed: %v\n%s", err, out)
+	}
+	if n := strings.Count(string(out), "BEGIN CERTIFICATE"); n != 2 || !strings.Contains(string(out), "BEGIN PRIVATE KEY") {
+		t.Errorf("openssl read %d certificates, want 2 and the key:\n%s", n, out)
+	}
+}
+
+func TestOrderChain(t *testing.T) {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leafKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	strayKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := issueCertificate(t, "ca", caKey, true, nil, nil)
+	leaf := issueCertificate(t, "leaf", leafKey, false, ca, caKey)
+	stray := issueCertificate(t, "stray", strayKey, true, nil, nil)
+
+	chain, err := OrderChain(leafKey, []*x509.Certificate{ca, leaf})
+	if err != nil {
+		t.Fatalf("OrderChain failed: %v", err)
+	}
+	if len(chain) != 2 || chain[0] != leaf || chain[1] != ca {
+		t.Errorf("Expected the leaf and then the CA, got %v", chain)
+	}
+
+	// a round trip keeps the whole chain
+	data, err := EncodePKCS12(leafKey, chain, []byte("changeit"))
+	if err != nil {
+		t.Fatalf("EncodePKCS12 failed: %v", err)
+	}
+	_, certs, err := DecodePKCS12(data, []byte("changeit"))
+	if err != nil || len(certs) != 2 {
+		t.Fatalf("Expected 2 certificates, got %d: %v", len(certs), err)
+	}
+
+	if _, err := OrderChain(leafKey, []*x509.Certificate{leaf, ca, stray}); err == nil {
+		t.Error("Expected an error for a certificate outside the chain")
+	}
+	if _, err := OrderChain(strayKey, []*x509.Certificate{leaf, ca}); err == nil {
+		t.Error("Expected an error when no certificate matches the key")
+	}
+}
@@ -0,0 +1,295 @@
+package reseed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	throttled "github.com/throttled/throttled/v2"
+)
+
+// RateLimitStore holds the rate limit state of each client in memory. It can
+// be saved to a file and loaded again, so a restart doesn't give every
+// client a fresh quota.
+type RateLimitStore struct {
+	mu      sync.Mutex
+	maxKeys int
+	entries map[string]rateLimitEntry
+	now     func() time.Time
+}
+
+// rateLimitEntry is the theoretical arrival time of a client's next request,
+// as the GCRA rate limiter tracks it, and when it can be forgotten.
+type rateLimitEntry struct {
+	TAT     int64 `json:"tat"`
+	Expires int64 `json:"expires"`
+}
+
+var _ throttled.GCRAStore = (*RateLimitStore)(nil)
+
+// NewRateLimitStore creates an empty store of up to maxKeys clients.
+func NewRateLimitStore(maxKeys int) *RateLimitStore {
+	return &RateLimitStore{
+		maxKeys: maxKeys,
+		entries: make(map[string]rateLimitEntry),
+		now:     time.Now,
+	}
+}
+
+// LoadRateLimitStore loads the store saved at path, or creates an empty one
+// if there is no file yet.
+func LoadRateLimitStore(path string, maxKeys int) (*RateLimitStore, error) {
+	s := NewRateLimitStore(maxKeys)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.entries); nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	s.prune()
+	return s, nil
+}
+
+// GetWithTime returns the value of key, or -1 if it isn't in the store, and
+// the current time.
+func (s *RateLimitStore) GetWithTime(key string) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, ok := s.entries[key]
+	if !ok || e.Expires <= now.UnixNano() {
+		return -1, now, nil
+	}
+	return e.TAT, now, nil
+}
+
+// SetIfNotExistsWithTTL sets key to value for ttl, unless it is set.
+func (s *RateLimitStore) SetIfNotExistsWithTTL(key string, value int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if e, ok := s.entries[key]; ok && e.Expires > now.UnixNano() {
+		return false, nil
+	}
+	if len(s.entries) >= s.maxKeys && s.maxKeys > 0 {
+		s.evict(now)
+	}
+	s.entries[key] = rateLimitEntry{TAT: value, Expires: now.Add(ttl).UnixNano()}
+	return true, nil
+}
+
+// CompareAndSwapWithTTL sets key to new for ttl if it is old.
+func (s *RateLimitStore) CompareAndSwapWithTTL(key string, old, new int64, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	e, ok := s.entries[key]
+	if !ok || e.Expires <= now.UnixNano() || e.TAT != old {
+		return false, nil
+	}
+	s.entries[key] = rateLimitEntry{TAT: new, Expires: now.Add(ttl).UnixNano()}
+	return true, nil
+}
+
+// Len returns the number of clients in the store.
+func (s *RateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// evict makes room for a new client: the expired ones are forgotten, and
+// if that isn't enough, some of the others.
+func (s *RateLimitStore) evict(now time.Time) {
+	for key, e := range s.entries {
+		if e.Expires <= now.UnixNano() {
+			delete(s.entries, key)
+		}
+	}
+	excess := len(s.entries) - s.maxKeys + 1 + s.maxKeys/100
+	for key := range s.entries {
+		if excess <= 0 {
+			break
+		}
+		delete(s.entries, key)
+		excess--
+	}
+}
+
+func (s *RateLimitStore) prune() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UnixNano()
+	for key, e := range s.entries {
+		if e.Expires <= now {
+			delete(s.entries, key)
+		}
+	}
+	if s.maxKeys > 0 && len(s.entries) > s.maxKeys {
+		s.evict(s.now())
+	}
+}
+
+// Save writes the clients which aren't expired to path.
+func (s *RateLimitStore) Save(path string) error {
+	s.prune()
+	s.mu.Lock()
+	data, err := json.Marshal(s.entries)
+	s.mu.Unlock()
+	if nil != err {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); nil != err {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Persist saves the store to path every interval, until ctx is done.
+func (s *RateLimitStore) Persist(ctx context.Context, path string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Save(path); nil != err {
+					log.Println("Saving the rate limits:", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// SetRateLimits changes the number of su3 and web requests each client may
+// make per hour, and how many of them at once, while the server runs.
+func (srv *Server) SetRateLimits(request, requestBurst, web, webBurst int) {
+	srv.rateLimitMutex.Lock()
+	defer srv.rateLimitMutex.Unlock()
+	srv.RequestRateLimit = request
+	srv.RequestRateBurst = requestBurst
+	srv.WebRateLimit = web
+	srv.WebRateBurst = webBurst
+	srv.limiters = nil
+}
+
+// limiter returns the rate limiter of the "su3" or "web" requests, or of
+// the "shared" budget, or nil if they aren't limited.
+func (srv *Server) limiter(kind string) (*throttled.GCRARateLimiter, error) {
+	srv.rateLimitMutex.Lock()
+	defer srv.rateLimitMutex.Unlock()
+	if limiter, ok := srv.limiters[kind]; ok {
+		return limiter, nil
+	}
+
+	perHour, burst := srv.RequestRateLimit, srv.RequestRateBurst
+	switch kind {
+	case "web":
+		perHour, burst = srv.WebRateLimit, srv.WebRateBurst
+	case "shared":
+		perHour, burst = srv.SharedRateLimit, srv.SharedRateBurst
+	}
+	var limiter *throttled.GCRARateLimiter
+	if perHour > 0 {
+		// without a burst, requests are spread evenly over the hour
+		maxBurst := 0
+		if burst > perHour {
+			burst = perHour
+		}
+		if burst > 0 {
+			maxBurst = burst - 1
+		}
+		var err error
+		limiter, err = throttled.NewGCRARateLimiter(srv.RateLimitStore, throttled.RateQuota{
+			MaxRate:  throttled.PerHour(perHour),
+			MaxBurst: maxBurst,
+		})
+		if nil != err {
+			return nil, err
+		}
+	}
+	if srv.limiters == nil {
+		srv.limiters = make(map[string]*throttled.GCRARateLimiter)
+	}
+	srv.limiters[kind] = limiter
+	return limiter, nil
+}
+
+// rateLimitMiddleware limits the "su3" or "web" requests of each client, as
+// its listener identifies it, answering those over the limit with 429 Too
+// Many Requests.
+func (srv *Server) rateLimitMiddleware(kind string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			id := srv.identify(r)
+			budget := kind
+			if id.Shared {
+				budget = "shared"
+			}
+			limiter, err := srv.limiter(budget)
+			if nil != err {
+				log.Println("Rate limit:", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// the su3 and web requests of shared clients draw on one budget
+			limited, result, err := limiter.RateLimit(budget+" "+id.Client, 1)
+			if nil != err {
+				log.Println("Rate limit:", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(result.ResetAfter.Seconds()))))
+			if limited {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+				http.Error(w, "429 Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// rateLimitKey returns the client a request from remoteAddr counts for: its
+// IP address, or with byPrefix the IPv4 /24 or IPv6 /64 it is in.
+func rateLimitKey(remoteAddr string, byPrefix bool) string {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); nil == err {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return strings.ToLower(host)
+	}
+	if !byPrefix {
+		return ip.String()
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
@@ -0,0 +1,127 @@
+package reseed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	for _, tc := range []struct {
+		addr     string
+		byPrefix bool
+		want     string
+	}{
+		{"192.0.2.10:1234", false, "192.0.2.10"},
+		{"192.0.2.10:1234", true, "192.0.2.0/24"},
+		{"[2001:db8:1:2:3:4:5:6]:443", false, "2001:db8:1:2:3:4:5:6"},
+		{"[2001:db8:1:2:3:4:5:6]:443", true, "2001:db8:1:2::/64"},
+		{"198.51.100.7", true, "198.51.100.0/24"},
+		{"Some.B32.I2P", true, "some.b32.i2p"},
+	} {
+		if got := rateLimitKey(tc.addr, tc.byPrefix); got != tc.want {
+			t.Errorf("rateLimitKey(%q, %v) = %s, want %s", tc.addr, tc.byPrefix, got, tc.want)
+		}
+	}
+}
+
+func TestServer_RateLimits(t *testing.T) {
+	srv := NewServer("", false)
+	srv.Reseeder = staticReseeder("su3")
+	srv.RateLimitByPrefix = true
+	srv.SetRateLimits(2, 2, 30, 0)
+
+	get := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/i2pseeds.su3", nil)
+		req.RemoteAddr = addr
+		req.Header.Set("User-Agent", I2pUserAgent)
+		w := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// the addresses of one /24 share their limit
+	for _, addr := range []string{"192.0.2.1:1000", "192.0.2.2:1000"} {
+		if w := get(addr); w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", addr, w.Code)
+		}
+	}
+	w := get("192.0.2.3:1000")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status %d, want 429", w.Code)
+	}
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry <= 0 || retry > 1800 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if w := get("198.51.100.1:1000"); w.Code != http.StatusOK {
+		t.Errorf("another /24: status %d", w.Code)
+	}
+
+	// new limits take effect at once, keeping the state of each client
+	srv.SetRateLimits(3, 1, 30, 0)
+	if w := get("192.0.2.3:1000"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("a burst of 1 allowed another request at once: status %d", w.Code)
+	}
+	// without a burst, a single request may come at once
+	srv.SetRateLimits(4, 0, 30, 0)
+	if w := get("203.0.113.1:1000"); w.Code != http.StatusOK {
+		t.Errorf("first request without a burst: status %d", w.Code)
+	}
+	if w := get("203.0.113.1:1000"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second request without a burst: status %d, want 429", w.Code)
+	}
+
+	srv.SetRateLimits(0, 0, 30, 0)
+	if w := get("192.0.2.3:1000"); w.Code != http.StatusOK {
+		t.Errorf("without a limit: status %d", w.Code)
+	}
+}
+
+func TestRateLimitStore_SaveLoad(t *testing.T) {
+	now := time.Now()
+	s := NewRateLimitStore(10)
+	s.now = func() time.Time { return now }
+	s.SetIfNotExistsWithTTL("su3 192.0.2.1", 42, time.Hour)
+	s.SetIfNotExistsWithTTL("su3 192.0.2.2", 43, time.Second)
+	if ok, _ := s.SetIfNotExistsWithTTL("su3 192.0.2.1", 44, time.Hour); ok {
+		t.Error("SetIfNotExistsWithTTL() replaced a set key")
+	}
+	if ok, _ := s.CompareAndSwapWithTTL("su3 192.0.2.1", 42, 45, time.Hour); !ok {
+		t.Error("CompareAndSwapWithTTL() didn't swap")
+	}
+
+	now = now.Add(time.Minute)
+	path := filepath.Join(t.TempDir(), "ratelimits.json")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRateLimitStore(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.now = s.now
+	if v, _, _ := loaded.GetWithTime("su3 192.0.2.1"); v != 45 {
+		t.Errorf("loaded value = %d, want 45", v)
+	}
+	if loaded.Len() != 1 {
+		t.Errorf("the expired client was saved: %d clients", loaded.Len())
+	}
+
+	if s, err := LoadRateLimitStore(filepath.Join(t.TempDir(), "none.json"), 10); err != nil || s.Len() != 0 {
+		t.Errorf("LoadRateLimitStore() of a missing file = %v, %v", s, err)
+	}
+}
+
+func TestRateLimitStore_Evict(t *testing.T) {
+	s := NewRateLimitStore(100)
+	for i := 0; i < 300; i++ {
+		s.SetIfNotExistsWithTTL(strconv.Itoa(i), 1, time.Hour)
+	}
+	if s.Len() > 100 {
+		t.Errorf("store holds %d clients, more than 100", s.Len())
+	}
+}
@@ -0,0 +1,69 @@
+package reseed
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// riCacheEntry holds the result of parsing a single routerInfo file. Files
+// which failed to parse or were rejected as less-useful are cached too, with
+// a nil info, so that they are not parsed again until they change on disk.
+type riCacheEntry struct {
+	Size    int64
+	ModTime time.Time
+	Info    *routerInfo
+}
+
+// routerInfoCache caches parsed routerInfos keyed by path. An entry is only
+// valid while the size and modification time of the file are unchanged.
+type routerInfoCache struct {
+	entries map[string]riCacheEntry
+	m       sync.RWMutex
+}
+
+func newRouterInfoCache() *routerInfoCache {
+	return &routerInfoCache{entries: make(map[string]riCacheEntry)}
+}
+
+// Get returns the cached entry for path if the file has not changed since it
+// was parsed.
+func (c *routerInfoCache) Get(path string, f os.FileInfo) (riCacheEntry, bool) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	entry, ok := c.entries[path]
+	if !ok || entry.Size != f.Size() || !entry.ModTime.Equal(f.ModTime()) {
+		return riCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// Put records the result of parsing path.
+func (c *routerInfoCache) Put(path string, f os.FileInfo, ri *routerInfo) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.entries[path] = riCacheEntry{Size: f.Size(), ModTime: f.ModTime(), Info: ri}
+}
+
+// Prune drops every entry whose path is not in keep, so that deleted files do
+// not pin their data in memory.
+func (c *routerInfoCache) Prune(keep map[string]os.FileInfo) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for path := range c.entries {
+		if _, ok := keep[path]; !ok {
+			delete(c.entries, path)
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *routerInfoCache) Len() int {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	return len(c.entries)
+}
@@ -0,0 +1,126 @@
+package reseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRouterInfoCache_GetPut(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "routerInfo-test.dat")
+	if err := os.WriteFile(path, []byte("dummy router info data"), 0o644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+
+	cache := newRouterInfoCache()
+	if _, ok := cache.Get(path, info); ok {
+		t.Fatal("Empty cache should not return an entry")
+	}
+
+	ri := &routerInfo{Name: info.Name(), ModTime: info.ModTime(), Data: []byte("data")}
+	cache.Put(path, info, ri)
+
+	entry, ok := cache.Get(path, info)
+	if !ok {
+		t.Fatal("Cache should return the entry for an unchanged file")
+	}
+	if entry.Info != ri {
+		t.Error("Cache returned a different routerInfo than was stored")
+	}
+
+	// Changing the modification time must invalidate the entry
+	later := info.ModTime().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to set mod time: %v", err)
+	}
+	changed, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+	if _, ok := cache.Get(path, changed); ok {
+		t.Error("Cache should not return an entry for a file with a new mod time")
+	}
+
+	// Changing the size must invalidate the entry
+	if err := os.WriteFile(path, []byte("longer dummy router info data"), 0o644); err != nil {
+		t.Fatalf("Failed to rewrite test file: %v", err)
+	}
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Failed to set mod time: %v", err)
+	}
+	resized, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat test file: %v", err)
+	}
+	if _, ok := cache.Get(path, resized); ok {
+		t.Error("Cache should not return an entry for a file with a new size")
+	}
+}
+
+func TestRouterInfoCache_Prune(t *testing.T) {
+	tempDir := t.TempDir()
+	cache := newRouterInfoCache()
+
+	keep := make(map[string]os.FileInfo)
+	for _, name := range []string{"routerInfo-a.dat", "routerInfo-b.dat", "routerInfo-c.dat"} {
+		path := filepath.Join(tempDir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Failed to stat test file: %v", err)
+		}
+		cache.Put(path, info, nil)
+		if name != "routerInfo-b.dat" {
+			keep[path] = info
+		}
+	}
+
+	cache.Prune(keep)
+
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries after pruning, got %d", cache.Len())
+	}
+}
+
+func TestLocalNetDb_RouterInfosCachesParseResults(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, name := range []string{"routerInfo-test1.dat", "routerInfo-test2.dat", "not-a-routerinfo.txt"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("dummy router info data"), 0o644); err != nil {
+			t.Fatalf("Failed to create test file %s: %v", name, err)
+		}
+	}
+
+	netdb := NewLocalNetDb(tempDir, 72*time.Hour)
+	netdb.Workers = 2
+
+	// The dummy data can't be parsed, but the failures should be cached
+	ris, err := netdb.RouterInfos()
+	if err != nil {
+		t.Fatalf("RouterInfos() failed: %v", err)
+	}
+	if len(ris) != 0 {
+		t.Errorf("Expected no usable routerInfos, got %d", len(ris))
+	}
+	if netdb.cache.Len() != 2 {
+		t.Errorf("Expected 2 cached entries, got %d", netdb.cache.Len())
+	}
+
+	// Removed files should be dropped from the cache on the next load
+	if err := os.Remove(filepath.Join(tempDir, "routerInfo-test1.dat")); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	if _, err := netdb.RouterInfos(); err != nil {
+		t.Fatalf("RouterInfos() failed: %v", err)
+	}
+	if netdb.cache.Len() != 1 {
+		t.Errorf("Expected 1 cached entry after removal, got %d", netdb.cache.Len())
+	}
+}
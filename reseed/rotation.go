@@ -0,0 +1,114 @@
+package reseed
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KeyRotation records a signing key and the successor which replaces it at
+// Cutover. The successor's certificate has to ship with I2P routers before
+// the cutover, so until then the reseeder keeps signing with the old key.
+type KeyRotation struct {
+	SignerID string      `json:"signer"`
+	Current  RotationKey `json:"current"`
+	Next     RotationKey `json:"next"`
+	Cutover  time.Time   `json:"cutover"`
+	Created  time.Time   `json:"created"`
+}
+
+// RotationKey is a signing key and certificate taking part in a rotation.
+type RotationKey struct {
+	Key         string `json:"key"`
+	Certificate string `json:"certificate"`
+	// Fingerprint is the SHA-256 fingerprint of the certificate
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"not_after"`
+}
+
+// Active returns the key to sign with at t.
+func (r *KeyRotation) Active(t time.Time) RotationKey {
+	if t.Before(r.Cutover) {
+		return r.Current
+	}
+	return r.Next
+}
+
+// NewRotationKey describes the key at keyPath and its certificate cert,
+// read from certPath. The paths are stored as absolute paths.
+func NewRotationKey(keyPath, certPath string, cert *x509.Certificate) (RotationKey, error) {
+	keyPath, err := filepath.Abs(keyPath)
+	if nil != err {
+		return RotationKey{}, err
+	}
+	certPath, err = filepath.Abs(certPath)
+	if nil != err {
+		return RotationKey{}, err
+	}
+	return RotationKey{
+		Key:         keyPath,
+		Certificate: certPath,
+		Fingerprint: CertificateFingerprint(cert),
+		NotAfter:    cert.NotAfter.UTC(),
+	}, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of cert as colon
+// separated hex.
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return FormatFingerprint(sum[:])
+}
+
+// FormatFingerprint formats a certificate digest as colon separated hex.
+func FormatFingerprint(sum []byte) string {
+	hexSum := strings.ToUpper(hex.EncodeToString(sum))
+	pairs := make([]string, 0, len(sum))
+	for i := 0; i < len(hexSum); i += 2 {
+		pairs = append(pairs, hexSum[i:i+2])
+	}
+	return strings.Join(pairs, ":")
+}
+
+// RotationPath returns where the key rotation of signer is recorded.
+func (ks *KeyStore) RotationPath(signer string) string {
+	return filepath.Join(ks.Path, SignerFilenameFromID(signer)+".rotation.json")
+}
+
+// LoadRotation reads the key rotation recorded for signer. The error wraps
+// os.ErrNotExist if there is none.
+func (ks *KeyStore) LoadRotation(signer string) (*KeyRotation, error) {
+	data, err := os.ReadFile(ks.RotationPath(signer))
+	if nil != err {
+		return nil, err
+	}
+	var r KeyRotation
+	if err := json.Unmarshal(data, &r); nil != err {
+		return nil, fmt.Errorf("%s: %s", ks.RotationPath(signer), err)
+	}
+	if r.SignerID != signer {
+		return nil, fmt.Errorf("%s is a key rotation for %s, not %s", ks.RotationPath(signer), r.SignerID, signer)
+	}
+	return &r, nil
+}
+
+// SaveRotation records r in the keystore, replacing any earlier rotation of
+// the same signer.
+func (ks *KeyStore) SaveRotation(r *KeyRotation) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if nil != err {
+		return err
+	}
+	path := ks.RotationPath(r.SignerID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); nil != err {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,144 @@
+package reseed
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func TestKeyRotation_Active(t *testing.T) {
+	cutover := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := &KeyRotation{
+		Current: RotationKey{Key: "old.pem"},
+		Next:    RotationKey{Key: "new.pem"},
+		Cutover: cutover,
+	}
+	if got := r.Active(cutover.Add(-time.Second)).Key; got != "old.pem" {
+		t.Errorf("Expected old.pem before the cutover, got %s", got)
+	}
+	if got := r.Active(cutover).Key; got != "new.pem" {
+		t.Errorf("Expected new.pem at the cutover, got %s", got)
+	}
+}
+
+func TestKeyStore_Rotation(t *testing.T) {
+	ks := NewKeyStore(t.TempDir())
+	if _, err := ks.LoadRotation("test@mail.i2p"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected os.ErrNotExist, got %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	next, err := NewRotationKey("test.pem", "test.crt", cert)
+	if err != nil {
+		t.Fatalf("NewRotationKey failed: %v", err)
+	}
+	if !strings.HasPrefix(next.Key, "/") || len(next.Fingerprint) != 95 {
+		t.Errorf("Unexpected rotation key %+v", next)
+	}
+
+	r := &KeyRotation{
+		SignerID: "test@mail.i2p",
+		Next:     next,
+		Cutover:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if err := ks.SaveRotation(r); err != nil {
+		t.Fatalf("SaveRotation failed: %v", err)
+	}
+	got, err := ks.LoadRotation("test@mail.i2p")
+	if err != nil {
+		t.Fatalf("LoadRotation failed: %v", err)
+	}
+	if got.Next != r.Next || !got.Cutover.Equal(r.Cutover) {
+		t.Errorf("Expected %+v, got %+v", r, got)
+	}
+}
+
+func TestReseederImpl_SignerCutover(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	rs := &ReseederImpl{SigningKey: oldKey, NextSigner: newKey, Cutover: time.Now().Add(time.Hour)}
+	if rs.signer() != oldKey {
+		t.Error("Expected the old key before the cutover")
+	}
+	if rs.cutoverTimer() == nil {
+		t.Error("Expected a cutover timer before the cutover")
+	}
+
+	rs.Cutover = time.Now().Add(-time.Second)
+	if rs.signer() != newKey {
+		t.Error("Expected the new key after the cutover")
+	}
+	if rs.cutoverTimer() != nil {
+		t.Error("Expected no cutover timer after the cutover")
+	}
+}
+
+// TestReseederImpl_CutoverTransparencyLog checks that the tree heads are
+// signed with the successor key after the cutover.
+func TestReseederImpl_CutoverTransparencyLog(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", newKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	newCert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	tl, err := OpenTransparencyLog(filepath.Join(t.TempDir(), "transparency.log"), oldKey, []byte("test@mail.i2p"))
+	if err != nil {
+		t.Fatalf("OpenTransparencyLog() failed: %v", err)
+	}
+	if err := tl.Append(testLogEntries(3, 0)); err != nil {
+		t.Fatalf("Append() failed: %v", err)
+	}
+
+	rs := NewReseeder(NewLocalNetDb(t.TempDir(), 72*time.Hour))
+	rs.SigningKey = oldKey
+	rs.NextSigner = newKey
+	rs.Cutover = time.Now().Add(-time.Second)
+	rs.TransparencyLog = tl
+	rs.cutover()
+
+	head := tl.Head()
+	if err := head.VerifySignature(newCert); err != nil {
+		t.Errorf("Tree head after the cutover should verify with the new certificate: %v", err)
+	}
+	if head.TreeSize != 3 {
+		t.Errorf("Expected a tree head of 3 entries, got %d", head.TreeSize)
+	}
+}
@@ -2,8 +2,11 @@ package reseed
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +14,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,7 +22,6 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/justinas/alice"
 	throttled "github.com/throttled/throttled/v2"
-	"github.com/throttled/throttled/v2/store"
 )
 
 // Constants moved to constants.go
@@ -26,7 +29,7 @@ import (
 type Server struct {
 	*http.Server
 
-	Reseeder  *ReseederImpl
+	Reseeder  Reseeder
 	Blacklist *Blacklist
 
 	ServerListener net.Listener
@@ -39,27 +42,73 @@ type Server struct {
 	OnionListener net.Listener
 	Onion         *onramp.Onion
 
+	// KeyPassphrase unlocks an encrypted TLS private key
+	KeyPassphrase PassphraseFunc
+	// Certificates are served by ListenAndServeTLS, and can be reloaded
+	// while it runs
+	Certificates *CertificateStore
+	// TLSProfile is the TLS policy of ListenAndServeTLS, set with
+	// UseTLSProfile
+	TLSProfile TLSProfile
+
+	// RequestRateLimit and WebRateLimit are the su3 and web requests each
+	// client may make per hour, 0 for no limit, and RequestRateBurst and
+	// WebRateBurst how many of them may come at once, one if 0, so the
+	// requests are spread evenly over the hour.
+	// Change them with SetRateLimits while the server runs.
 	RequestRateLimit int
 	WebRateLimit     int
+	RequestRateBurst int
+	WebRateBurst     int
+	// SharedRateLimit is the su3 and web requests per hour which all the
+	// peers of a transport that can't tell them apart share, such as those
+	// of the onion listener, and SharedRateBurst how many of them may come
+	// at once, so one peer can't spend the whole budget
+	SharedRateLimit int
+	SharedRateBurst int
+	// RateLimitByPrefix counts the requests from each IPv4 /24 and IPv6 /64
+	// together, rather than those of each address
+	RateLimitByPrefix bool
+	// RateLimitStore holds the rate limit state of each client
+	RateLimitStore throttled.GCRAStore
+	rateLimitMutex sync.Mutex
+	limiters       map[string]*throttled.GCRARateLimiter
+
 	acceptables      map[string]time.Time
 	acceptablesMutex sync.RWMutex
+
+	// sessions are the I2P and onion sessions closed by Shutdown, and
+	// served the HTTP servers of each listener
+	sessions      []io.Closer
+	served        []*http.Server
+	sessionsMutex sync.Mutex
+	closed        bool
+	// done is cancelled by Shutdown, stopping the loops of the listeners
+	done context.Context
+	stop context.CancelFunc
+
+	// stats are the stats of each listener, in the order of statsNames
+	stats      []*listenerStats
+	statsNames []string
+	statsMutex sync.Mutex
 }
 
 func NewServer(prefix string, trustProxy bool) *Server {
-	config := &tls.Config{
-		MinVersion:               tls.VersionTLS13,
-		PreferServerCipherSuites: true,
-		CipherSuites: []uint16{
-			tls.TLS_AES_256_GCM_SHA384,
-			tls.TLS_CHACHA20_POLY1305_SHA256,
-		},
-		CurvePreferences: []tls.CurveID{tls.CurveP384, tls.CurveP521}, // default CurveP256 removed
+	h := &http.Server{TLSConfig: &tls.Config{}}
+	server := Server{
+		Server:           h,
+		Reseeder:         nil,
+		RequestRateLimit: 4,
+		WebRateLimit:     30,
+		SharedRateLimit:  120,
+		SharedRateBurst:  10,
+		RateLimitStore:   NewRateLimitStore(400000),
 	}
-	h := &http.Server{TLSConfig: config}
-	server := Server{Server: h, Reseeder: nil}
+	server.done, server.stop = context.WithCancel(context.Background())
+	server.UseTLSProfile(ModernTLSProfile)
 
-	th := throttled.RateLimit(throttled.PerHour(4), &throttled.VaryBy{RemoteAddr: true}, store.NewMemStore(200000))
-	thw := throttled.RateLimit(throttled.PerHour(30), &throttled.VaryBy{RemoteAddr: true}, store.NewMemStore(200000))
+	th := server.rateLimitMiddleware("su3")
+	thw := server.rateLimitMiddleware("web")
 
 	middlewareChain := alice.New()
 	if trustProxy {
@@ -74,13 +123,59 @@ func NewServer(prefix string, trustProxy bool) *Server {
 	})
 
 	mux := http.NewServeMux()
-	mux.Handle("/", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, thw.Throttle, server.browsingMiddleware).Then(errorHandler))
-	mux.Handle(prefix+"/i2pseeds.su3", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, verifyMiddleware, th.Throttle).Then(http.HandlerFunc(server.reseedHandler)))
+	mux.Handle("/", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, thw, server.browsingMiddleware).Then(errorHandler))
+	mux.Handle(prefix+"/i2pseeds.su3", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, verifyMiddleware, th).Then(http.HandlerFunc(server.reseedHandler)))
+	mux.Handle(prefix+"/transparency/", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, thw).Then(http.StripPrefix(prefix+"/transparency", http.HandlerFunc(server.transparencyHandler))))
 	server.Handler = mux
 
 	return &server
 }
 
+// Shutdown stops the server gracefully on every listener: no new
+// connections are accepted, requests in progress are finished unless ctx is
+// done first, and then the I2P and onion sessions are closed.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	srv.sessionsMutex.Lock()
+	srv.closed = true
+	served := srv.served
+	sessions := srv.sessions
+	srv.served = nil
+	srv.sessions = nil
+	srv.sessionsMutex.Unlock()
+	srv.stop()
+
+	errs := []error{srv.Server.Shutdown(ctx)}
+	for _, hs := range served {
+		errs = append(errs, hs.Shutdown(ctx))
+	}
+	for _, session := range sessions {
+		errs = append(errs, session.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// keepSession records an I2P or onion session for Shutdown to close. If the
+// server was already shut down, the session is closed at once.
+func (srv *Server) keepSession(session io.Closer) error {
+	srv.sessionsMutex.Lock()
+	defer srv.sessionsMutex.Unlock()
+	if srv.closed {
+		session.Close()
+		return http.ErrServerClosed
+	}
+	srv.sessions = append(srv.sessions, session)
+	return nil
+}
+
+// UseTLSProfile sets the TLS policy of the HTTPS listener.
+func (srv *Server) UseTLSProfile(p TLSProfile) {
+	if srv.TLSConfig == nil {
+		srv.TLSConfig = &tls.Config{}
+	}
+	srv.TLSProfile = p
+	p.Apply(srv.TLSConfig)
+}
+
 // See use of crypto/rand on:
 // https://stackoverflow.com/questions/22892120/how-to-generate-a-random-string-of-a-fixed-length-in-go
 // Constants moved to constants.go
@@ -185,19 +280,13 @@ func (srv *Server) checkAcceptableUnsafe(val string) bool {
 }
 
 func (srv *Server) reseedHandler(w http.ResponseWriter, r *http.Request) {
-	var peer Peer
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		peer = Peer(ip)
-	} else {
-		peer = Peer(r.RemoteAddr)
-	}
-
-	su3Bytes, err := srv.Reseeder.PeerSu3Bytes(peer)
+	su3Bytes, err := srv.Reseeder.PeerSu3Bytes(srv.identify(r).Peer)
 	if nil != err {
 		log.Println("Error serving su3:", err)
 		http.Error(w, "500 Unable to serve su3", http.StatusInternalServerError)
 		return
 	}
+	countSu3Served(r.Context())
 
 	w.Header().Set("Content-Disposition", "attachment; filename=i2pseeds.su3")
 	w.Header().Set("Content-Type", "application/octet-stream")
@@ -206,6 +295,67 @@ func (srv *Server) reseedHandler(w http.ResponseWriter, r *http.Request) {
 	io.Copy(w, bytes.NewReader(su3Bytes))
 }
 
+// transparencyHandler serves the signed tree head, inclusion and consistency
+// proofs and the entries of the transparency log.
+func (srv *Server) transparencyHandler(w http.ResponseWriter, r *http.Request) {
+	// only su3 sets built by this server are logged
+	rs, ok := srv.Reseeder.(*ReseederImpl)
+	if !ok || rs.TransparencyLog == nil {
+		http.NotFound(w, r)
+		return
+	}
+	tl := rs.TransparencyLog
+
+	var body interface{}
+	switch r.URL.Path {
+	case "/head":
+		body = tl.Head()
+	case "/proof":
+		proof, err := tl.Prove(strings.ToLower(r.FormValue("sha256")))
+		if nil != err {
+			http.Error(w, "404 "+err.Error(), http.StatusNotFound)
+			return
+		}
+		body = proof
+	case "/consistency":
+		from, err := strconv.Atoi(r.FormValue("from"))
+		if nil != err {
+			http.Error(w, "400 Bad Request", http.StatusBadRequest)
+			return
+		}
+		path, head, err := tl.Consistency(from)
+		if nil != err {
+			http.Error(w, "400 "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = struct {
+			From int            `json:"from"`
+			Path []string       `json:"path"`
+			Head SignedTreeHead `json:"head"`
+		}{from, path, head}
+	case "/entries":
+		start, _ := strconv.Atoi(r.FormValue("start"))
+		end, err := strconv.Atoi(r.FormValue("end"))
+		if nil != err {
+			end = tl.Size()
+		}
+		entries, err := tl.Entries(start, end)
+		if nil != err {
+			http.Error(w, "400 "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = entries
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(body); nil != err {
+		log.Println(err)
+	}
+}
+
 func disableKeepAliveMiddleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Connection", "close")
@@ -1,6 +1,8 @@
 package reseed
 
 import (
+	"context"
+	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
 	"errors"
@@ -10,7 +12,8 @@ import (
 	"math/rand"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -34,20 +37,92 @@ func (p Peer) Hash() int {
 	return int(crc32.ChecksumIEEE(c))
 }
 
-/*type Reseeder interface {
+// Reseeder serves su3 files to peers.
+type Reseeder interface {
 	// get an su3 file (bytes) for a peer
 	PeerSu3Bytes(peer Peer) ([]byte, error)
-}*/
+}
 
 type ReseederImpl struct {
-	netdb *LocalNetDbImpl
-	su3s  chan [][]byte
-
-	SigningKey      *rsa.PrivateKey
-	SignerID        []byte
+	netdb   *LocalNetDbImpl
+	su3s    chan [][]byte
+	stop    <-chan struct{}
+	bundles []Su3Bundle
+
+	SigningKey *rsa.PrivateKey
+	// Signer, if set, is used instead of SigningKey, for example a
+	// RemoteSigner whose key is held by a signing daemon
+	Signer   crypto.Signer
+	SignerID []byte
+	// NextSigner, if set, replaces the signer from Cutover on, when the
+	// whole su3 set is rebuilt with it
+	NextSigner      crypto.Signer
+	Cutover         time.Time
 	NumRi           int
 	RebuildInterval time.Duration
 	NumSu3          int
+	// NumBuilders is the number of goroutines building su3 files in parallel
+	NumBuilders int
+	// ChurnThreshold is the number of added, changed or expired routerInfos
+	// which triggers an incremental rebuild when the netDb is being watched
+	ChurnThreshold int
+	// CheckInterval is how often the churn is compared to ChurnThreshold
+	CheckInterval time.Duration
+	// Deterministic makes builds reproducible: routerInfos are ordered by
+	// name, selected with a PRNG seeded with Seed, and BuildTime is used for
+	// the su3 versions, zip timestamps and routerInfo ages
+	Deterministic bool
+	Seed          int64
+	// BuildTime is the time Build and Plan stamp deterministic builds with,
+	// the current time if it is zero. A started reseeder ignores it: each
+	// rebuild uses the current time and logs it for auditors, so routerInfos
+	// which go stale while serving are dropped.
+	BuildTime time.Time
+	// TransparencyLog, if set, records every su3 file before it is served
+	TransparencyLog *TransparencyLog
+	// SignatureSize is the size in bytes of the signature reserved in each
+	// su3 file when SigningKey is nil, so the files can be signed offline
+	SignatureSize int
+
+	buildTime time.Time
+	// serving is set by Start
+	serving bool
+	// selection is set by SetSelection and taken by the rebuild loop
+	selection      *Selection
+	selectionMutex sync.Mutex
+}
+
+// Selection is how many su3 files are built with how many routerInfos each,
+// and when they are rebuilt. It can be changed while the reseeder runs.
+type Selection struct {
+	NumRi           int
+	NumSu3          int
+	NumBuilders     int
+	RebuildInterval time.Duration
+	// ChurnThreshold only matters if the reseeder was started watching the
+	// netDb
+	ChurnThreshold int
+}
+
+// Su3Bundle is a signed su3 file in a set, along with the routerInfos it
+// contains and when it was built.
+type Su3Bundle struct {
+	Data        []byte
+	Built       time.Time
+	RouterInfos []string
+}
+
+// builtSu3 is an su3 file fresh from a builder, before it is marshalled.
+type builtSu3 struct {
+	Index       int
+	File        *su3.File
+	RouterInfos []string
+}
+
+// su3Seeds is the set of routerInfos chosen for the Index'th su3 file.
+type su3Seeds struct {
+	Index int
+	Seeds []routerInfo
 }
 
 func NewReseeder(netdb *LocalNetDbImpl) *ReseederImpl {
@@ -56,20 +131,20 @@ func NewReseeder(netdb *LocalNetDbImpl) *ReseederImpl {
 		su3s:            make(chan [][]byte),
 		NumRi:           77,
 		RebuildInterval: 90 * time.Hour,
+		NumBuilders:     3,
+		CheckInterval:   time.Minute,
 	}
 }
 
-func (rs *ReseederImpl) Start() chan bool {
+// Start builds the su3 set, and keeps it fresh until ctx is done. The
+// returned channel is closed when the rebuild loop has stopped, which waits
+// for a rebuild in progress to be published and logged. No su3 file is
+// served once ctx is done.
+func (rs *ReseederImpl) Start(ctx context.Context) <-chan struct{} {
+	rs.stop = ctx.Done()
+	rs.serving = true
 	// atomic swapper
-	go func() {
-		var m [][]byte
-		for {
-			select {
-			case m = <-rs.su3s:
-			case rs.su3s <- m:
-			}
-		}
-	}()
+	go swapSu3s(rs.su3s, rs.stop)
 
 	// init the cache
 	err := rs.rebuild()
@@ -77,33 +152,154 @@ func (rs *ReseederImpl) Start() chan bool {
 		log.Println(err)
 	}
 
+	done := make(chan struct{})
+	cutover := rs.cutoverTimer()
+	if rs.netdb.Churn() >= 0 && rs.ChurnThreshold > 0 {
+		if rs.Deterministic {
+			// a partially refreshed set can't be reproduced from one snapshot
+			log.Println("Deterministic builds always rebuild the whole su3 set, not watching the netDb")
+		} else {
+			go func() {
+				defer close(done)
+				rs.refreshLoop(ctx, cutover)
+			}()
+			return done
+		}
+	}
+
 	ticker := time.NewTicker(rs.RebuildInterval)
-	quit := make(chan bool)
 	go func() {
+		defer close(done)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
+				if rs.applySelection() {
+					ticker.Reset(rs.RebuildInterval)
+				}
 				err := rs.rebuild()
 				if nil != err {
 					log.Println(err)
 				}
-			case <-quit:
-				ticker.Stop()
+			case <-cutover:
+				rs.cutover()
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
-	return quit
+	return done
 }
 
-func (rs *ReseederImpl) rebuild() error {
-	log.Println("Rebuilding su3 cache...")
+// SetSelection changes the selection of routerInfos from the next rebuild
+// on. The rebuild interval in use is kept until then.
+func (rs *ReseederImpl) SetSelection(s Selection) {
+	rs.selectionMutex.Lock()
+	defer rs.selectionMutex.Unlock()
+	rs.selection = &s
+}
+
+// applySelection takes the selection set since the last rebuild, if there
+// is one, and reports whether it changed RebuildInterval.
+func (rs *ReseederImpl) applySelection() bool {
+	rs.selectionMutex.Lock()
+	s := rs.selection
+	rs.selection = nil
+	rs.selectionMutex.Unlock()
+	if s == nil {
+		return false
+	}
 
+	changed := s.RebuildInterval != rs.RebuildInterval
+	rs.NumRi = s.NumRi
+	rs.NumSu3 = s.NumSu3
+	rs.NumBuilders = s.NumBuilders
+	rs.RebuildInterval = s.RebuildInterval
+	rs.ChurnThreshold = s.ChurnThreshold
+	return changed
+}
+
+// cutoverTimer fires when the reseeder switches to NextSigner. It never
+// fires if there is no switch ahead.
+func (rs *ReseederImpl) cutoverTimer() <-chan time.Time {
+	if rs.NextSigner == nil || rs.Cutover.IsZero() {
+		return nil
+	}
+	until := time.Until(rs.Cutover)
+	if until <= 0 {
+		return nil
+	}
+	log.Printf("Switching to the successor signing key at %s\n", rs.Cutover.UTC().Format(time.RFC3339))
+	return time.After(until)
+}
+
+// cutover rebuilds the whole su3 set with NextSigner, so no file signed with
+// the old key is served after Cutover. The transparency log signs its tree
+// heads with NextSigner too, as verifiers check them against the su3 signer.
+func (rs *ReseederImpl) cutover() {
+	log.Println("Cutover reached, re-signing the su3 set with the successor signing key")
+	if rs.TransparencyLog != nil {
+		if err := rs.TransparencyLog.SetSigningKey(rs.NextSigner); nil != err {
+			log.Println(err)
+		}
+	}
+	if err := rs.rebuild(); nil != err {
+		log.Println(err)
+	}
+}
+
+// refreshLoop incrementally rebuilds the su3 set whenever the netDb churn
+// passes ChurnThreshold or the oldest su3 file is older than RebuildInterval,
+// until ctx is done.
+func (rs *ReseederImpl) refreshLoop(ctx context.Context, cutover <-chan time.Time) {
+	interval := rs.CheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rs.applySelection()
+			if rs.netdb.Churn() < rs.ChurnThreshold && rs.oldestBundleAge() < rs.RebuildInterval {
+				continue
+			}
+			if err := rs.refresh(rs.netdb.TakeChanges()); nil != err {
+				log.Println(err)
+			}
+		case <-cutover:
+			rs.cutover()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (rs *ReseederImpl) oldestBundleAge() time.Duration {
+	var oldest time.Duration
+	for _, b := range rs.bundles {
+		if age := time.Since(b.Built); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
+// routerInfos returns the routerInfos available for building su3 files.
+func (rs *ReseederImpl) routerInfos() ([]routerInfo, error) {
 	// get all RIs from netdb provider
 	ris, err := rs.netdb.RouterInfos()
 	if nil != err {
-		return fmt.Errorf("unable to get routerInfos: %s", err)
+		return nil, fmt.Errorf("unable to get routerInfos: %s", err)
+	}
+
+	if rs.Deterministic {
+		// RouterInfos() returns routerInfos in map order
+		sort.Slice(ris, func(i, j int) bool { return ris[i].Name < ris[j].Name })
+		rng := rand.New(rand.NewSource(rs.Seed))
+		rng.Shuffle(len(ris), func(i, j int) { ris[i], ris[j] = ris[j], ris[i] })
 	}
 
 	// use only 75% of routerInfos
@@ -111,68 +307,306 @@ func (rs *ReseederImpl) rebuild() error {
 
 	// fail if we don't have enough RIs to make a single reseed file
 	if rs.NumRi > len(ris) {
-		return fmt.Errorf("not enough routerInfos - have: %d, need: %d", len(ris), rs.NumRi)
+		return nil, fmt.Errorf("not enough routerInfos - have: %d, need: %d", len(ris), rs.NumRi)
+	}
+
+	return ris, nil
+}
+
+func (rs *ReseederImpl) rebuild() error {
+	log.Println("Rebuilding su3 cache...")
+
+	// a full rebuild supersedes any churn seen so far
+	rs.netdb.TakeChanges()
+
+	bundles, err := rs.Build()
+	if nil != err {
+		return err
+	}
+
+	// use this new set of su3s
+	if err := rs.publish(bundles); nil != err {
+		return err
+	}
+
+	log.Println("Done rebuilding.")
+
+	return nil
+}
+
+// Build runs the rebuild pipeline once and returns a complete signed su3 set
+// without serving it.
+func (rs *ReseederImpl) Build() ([]Su3Bundle, error) {
+	rs.prepareBuild()
+
+	ris, err := rs.routerInfos()
+	if nil != err {
+		return nil, err
+	}
+
+	numSu3s := rs.numSu3s(len(ris))
+	log.Printf("Building %d su3 files each containing %d out of %d routerInfos.\n", numSu3s, rs.NumRi, len(ris))
+
+	built, err := rs.buildSu3s(ris, numSu3s)
+	if nil != err {
+		return nil, err
+	}
+
+	return rs.marshalSu3s(built)
+}
+
+// Plan selects routerInfos the same way Build does, without zipping or
+// signing anything. It returns the names of the routerInfos each su3 file
+// would contain and the number of routerInfos available.
+func (rs *ReseederImpl) Plan() ([][]string, int, error) {
+	rs.prepareBuild()
+
+	ris, err := rs.routerInfos()
+	if nil != err {
+		return nil, 0, err
+	}
+
+	var plan [][]string
+	for job := range rs.seedsProducer(ris, rs.numSu3s(len(ris))) {
+		names := make([]string, len(job.Seeds))
+		for i, seed := range job.Seeds {
+			names[i] = seed.Name
+		}
+		plan = append(plan, names)
+	}
+	return plan, len(ris), nil
+}
+
+// prepareBuild fixes the build time of a deterministic build.
+func (rs *ReseederImpl) prepareBuild() {
+	if !rs.Deterministic {
+		return
+	}
+	rs.buildTime = rs.BuildTime
+	if rs.buildTime.IsZero() || rs.serving {
+		rs.buildTime = time.Now().Truncate(time.Second)
+	}
+	rs.netdb.AsOf = rs.buildTime
+	log.Printf("Deterministic build with seed %d at build time %d (%s)\n", rs.Seed, rs.buildTime.Unix(), rs.buildTime.UTC().Format(time.RFC3339))
+}
+
+// refresh rebuilds only the su3 files which contain changed or expired
+// routerInfos or are older than RebuildInterval, plus enough of the oldest
+// remaining files to make room for newly added routerInfos.
+func (rs *ReseederImpl) refresh(changes NetDbChanges) error {
+	if len(rs.bundles) == 0 {
+		return rs.rebuild()
+	}
+
+	ris, err := rs.routerInfos()
+	if nil != err {
+		return err
+	}
+	if rs.numSu3s(len(ris)) != len(rs.bundles) {
+		return rs.rebuild()
+	}
+
+	var stale, fresh []int
+	for i, b := range rs.bundles {
+		if time.Since(b.Built) >= rs.RebuildInterval || b.containsAny(changes) {
+			stale = append(stale, i)
+		} else {
+			fresh = append(fresh, i)
+		}
+	}
+
+	// replace a share of the remaining files proportional to the share of the
+	// netDb which is new, oldest first
+	extra := (len(rs.bundles)*len(changes.Added) + len(ris) - 1) / len(ris)
+	if extra > len(fresh) {
+		extra = len(fresh)
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return rs.bundles[fresh[i]].Built.Before(rs.bundles[fresh[j]].Built)
+	})
+	stale = append(stale, fresh[:extra]...)
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	log.Printf("Refreshing %d of %d su3 files (%d added, %d changed, %d expired routerInfos)...\n",
+		len(stale), len(rs.bundles), len(changes.Added), len(changes.Changed), len(changes.Expired))
+
+	built, err := rs.buildSu3s(ris, len(stale))
+	if nil != err {
+		return err
+	}
+
+	next := make([]Su3Bundle, len(rs.bundles))
+	copy(next, rs.bundles)
+	replaced, err := rs.marshalSu3s(built)
+	if nil != err {
+		return err
+	}
+	for i, b := range replaced {
+		next[stale[i]] = b
+	}
+	if err := rs.logBundles(next, stale[:len(replaced)]); nil != err {
+		return err
+	}
+	rs.bundles = next
+	rs.swap()
+
+	log.Println("Done refreshing.")
+
+	return nil
+}
+
+func (b Su3Bundle) containsAny(changes NetDbChanges) bool {
+	for _, name := range b.RouterInfos {
+		if changes.Stale(name) {
+			return true
+		}
 	}
+	return false
+}
 
+// buildSu3s builds n su3 files from ris using NumBuilders parallel builders.
+func (rs *ReseederImpl) buildSu3s(ris []routerInfo, n int) ([]builtSu3, error) {
 	// build a pipeline ris -> seeds -> su3
-	seedsChan := rs.seedsProducer(ris)
+	seedsChan := rs.seedsProducer(ris, n)
 	// fan-in multiple builders
-	su3Chan := fanIn(rs.su3Builder(seedsChan), rs.su3Builder(seedsChan), rs.su3Builder(seedsChan))
+	numBuilders := rs.NumBuilders
+	if numBuilders < 1 {
+		numBuilders = 1
+	}
+	// every file in a set is signed with the same key, even if the build
+	// runs past the cutover
+	signer := rs.signer()
+	builders := make([]<-chan builtSu3, numBuilders)
+	for i := range builders {
+		builders[i] = rs.su3Builder(seedsChan, signer)
+	}
+	su3Chan := fanIn(builders...)
 
-	// read from su3 chan and append to su3s slice
-	var newSu3s [][]byte
+	// read from su3 chan and put each su3 in the order it was seeded, so the
+	// set doesn't depend on which builder finished first
+	slots := make([]*builtSu3, n)
 	for gs := range su3Chan {
-		data, err := gs.MarshalBinary()
-		if nil != err {
-			return err
+		gs := gs
+		slots[gs.Index] = &gs
+	}
+	built := make([]builtSu3, 0, n)
+	for _, gs := range slots {
+		if gs != nil {
+			built = append(built, *gs)
 		}
-
-		newSu3s = append(newSu3s, data)
 	}
 
-	// use this new set of su3s
-	rs.su3s <- newSu3s
+	return built, nil
+}
 
-	log.Println("Done rebuilding.")
+// publish replaces the whole su3 set with bundles.
+func (rs *ReseederImpl) publish(bundles []Su3Bundle) error {
+	slots := make([]int, len(bundles))
+	for i := range slots {
+		slots[i] = i
+	}
+	if err := rs.logBundles(bundles, slots); nil != err {
+		return err
+	}
+	rs.bundles = bundles
+	rs.swap()
+	return nil
+}
 
+// logBundles records the su3 files in the given slots of set in the
+// transparency log. Nothing may be served which hasn't been logged.
+func (rs *ReseederImpl) logBundles(set []Su3Bundle, slots []int) error {
+	if rs.TransparencyLog == nil || len(slots) == 0 {
+		return nil
+	}
+	var seed *int64
+	if rs.Deterministic {
+		seed = &rs.Seed
+	}
+	entries := make([]LogEntry, len(slots))
+	for i, slot := range slots {
+		b := set[slot]
+		entries[i] = NewLogEntry(b.Data, rs.SignerID, b.Built, len(set), slot, len(b.RouterInfos), seed)
+	}
+	if err := rs.TransparencyLog.Append(entries); nil != err {
+		return fmt.Errorf("unable to update transparency log: %s", err)
+	}
 	return nil
 }
 
-func (rs *ReseederImpl) seedsProducer(ris []routerInfo) <-chan []routerInfo {
-	lenRis := len(ris)
+// swap hands the current su3 set to the swapper for serving.
+func (rs *ReseederImpl) swap() {
+	newSu3s := make([][]byte, len(rs.bundles))
+	for i, b := range rs.bundles {
+		newSu3s[i] = b.Data
+	}
+	select {
+	case rs.su3s <- newSu3s:
+	case <-rs.stop:
+	}
+}
 
-	// if NumSu3 is not specified, then we determine the "best" number based on the number of RIs
-	var numSu3s int
-	if rs.NumSu3 != 0 {
-		numSu3s = rs.NumSu3
-	} else {
-		switch {
-		case lenRis > 4000:
-			numSu3s = 300
-		case lenRis > 3000:
-			numSu3s = 200
-		case lenRis > 2000:
-			numSu3s = 100
-		case lenRis > 1000:
-			numSu3s = 75
-		default:
-			numSu3s = 50
+func (rs *ReseederImpl) marshalSu3s(built []builtSu3) ([]Su3Bundle, error) {
+	now := time.Now()
+	if rs.Deterministic {
+		now = rs.buildTime
+	}
+	bundles := make([]Su3Bundle, 0, len(built))
+	for _, gs := range built {
+		data, err := gs.File.MarshalBinary()
+		if nil != err {
+			return nil, err
 		}
+		bundles = append(bundles, Su3Bundle{Data: data, Built: now, RouterInfos: gs.RouterInfos})
 	}
+	return bundles, nil
+}
 
-	log.Printf("Building %d su3 files each containing %d out of %d routerInfos.\n", numSu3s, rs.NumRi, lenRis)
+// numSu3s returns NumSu3, or if it is not specified the "best" number of su3
+// files based on the number of RIs.
+func (rs *ReseederImpl) numSu3s(lenRis int) int {
+	if rs.NumSu3 != 0 {
+		return rs.NumSu3
+	}
+	switch {
+	case lenRis > 4000:
+		return 300
+	case lenRis > 3000:
+		return 200
+	case lenRis > 2000:
+		return 100
+	case lenRis > 1000:
+		return 75
+	default:
+		return 50
+	}
+}
+
+func (rs *ReseederImpl) seedsProducer(ris []routerInfo, numSu3s int) <-chan su3Seeds {
+	lenRis := len(ris)
 
-	out := make(chan []routerInfo)
+	out := make(chan su3Seeds)
+
+	perm := rand.Perm
+	if rs.Deterministic {
+		perm = rand.New(rand.NewSource(rs.Seed)).Perm
+	}
 
 	go func() {
 		for i := 0; i < numSu3s; i++ {
 			var seeds []routerInfo
-			unsorted := rand.Perm(lenRis)
+			unsorted := perm(lenRis)
 			for z := 0; z < rs.NumRi; z++ {
 				seeds = append(seeds, ris[unsorted[z]])
 			}
+			if rs.Deterministic {
+				sort.Slice(seeds, func(i, j int) bool { return seeds[i].Name < seeds[j].Name })
+			}
 
-			out <- seeds
+			out <- su3Seeds{Index: i, Seeds: seeds}
 		}
 		close(out)
 	}()
@@ -180,17 +614,21 @@ func (rs *ReseederImpl) seedsProducer(ris []routerInfo) <-chan []routerInfo {
 	return out
 }
 
-func (rs *ReseederImpl) su3Builder(in <-chan []routerInfo) <-chan *su3.File {
-	out := make(chan *su3.File)
+func (rs *ReseederImpl) su3Builder(in <-chan su3Seeds, signer crypto.Signer) <-chan builtSu3 {
+	out := make(chan builtSu3)
 	go func() {
-		for seeds := range in {
-			gs, err := rs.createSu3(seeds)
+		for job := range in {
+			gs, err := rs.createSu3(job.Seeds, signer)
 			if nil != err {
 				log.Println(err)
 				continue
 			}
 
-			out <- gs
+			names := make([]string, len(job.Seeds))
+			for i, seed := range job.Seeds {
+				names[i] = seed.Name
+			}
+			out <- builtSu3{Index: job.Index, File: gs, RouterInfos: names}
 		}
 		close(out)
 	}()
@@ -198,8 +636,41 @@ func (rs *ReseederImpl) su3Builder(in <-chan []routerInfo) <-chan *su3.File {
 }
 
 func (rs *ReseederImpl) PeerSu3Bytes(peer Peer) ([]byte, error) {
-	m := <-rs.su3s
-	defer func() { rs.su3s <- m }()
+	return peerSu3Bytes(rs.su3s, rs.stop, peer)
+}
+
+// errReseederStopped is returned for su3 requests once the reseeder stopped.
+var errReseederStopped = errors.New("reseeder stopped")
+
+// swapSu3s holds the su3 set being served, handing it out and taking a new
+// one in turn over su3s, until stop is closed.
+func swapSu3s(su3s chan [][]byte, stop <-chan struct{}) {
+	var m [][]byte
+	for {
+		select {
+		case m = <-su3s:
+		case su3s <- m:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// peerSu3Bytes returns the su3 file assigned to peer from the set held by
+// swapSu3s, so a peer always gets the same file until the set changes.
+func peerSu3Bytes(su3s chan [][]byte, stop <-chan struct{}, peer Peer) ([]byte, error) {
+	var m [][]byte
+	select {
+	case m = <-su3s:
+	case <-stop:
+		return nil, errReseederStopped
+	}
+	defer func() {
+		select {
+		case su3s <- m:
+		case <-stop:
+		}
+	}()
 
 	if len(m) == 0 {
 		return nil, errors.New("404")
@@ -208,23 +679,60 @@ func (rs *ReseederImpl) PeerSu3Bytes(peer Peer) ([]byte, error) {
 	return m[peer.Hash()%len(m)], nil
 }
 
-func (rs *ReseederImpl) createSu3(seeds []routerInfo) (*su3.File, error) {
-	su3File := su3.New()
+// createSu3 builds an su3 file of seeds signed by signer, or with room for a
+// signature if signer is nil.
+func (rs *ReseederImpl) createSu3(seeds []routerInfo, signer crypto.Signer) (*su3.File, error) {
+	var su3File *su3.File
+	var zipTime time.Time
+	if rs.Deterministic {
+		su3File = su3.NewAt(rs.buildTime)
+		zipTime = rs.buildTime
+	} else {
+		su3File = su3.New()
+	}
 	su3File.FileType = su3.FileTypeZIP
 	su3File.ContentType = su3.ContentTypeReseed
 
-	zipped, err := zipSeeds(seeds)
+	zipped, err := zipSeedsAt(seeds, zipTime)
 	if nil != err {
 		return nil, err
 	}
 	su3File.Content = zipped
 
 	su3File.SignerID = rs.SignerID
-	su3File.Sign(rs.SigningKey)
+	if signer != nil {
+		// use the signature type I2P expects for the size of the key
+		if pub, ok := signer.Public().(*rsa.PublicKey); ok {
+			su3File.SignatureType = su3.RSASigType(pub.Size())
+		}
+		if err := su3File.SignWith(signer); nil != err {
+			return nil, err
+		}
+	} else if rs.SignatureSize > 0 {
+		su3File.SignatureType = su3.RSASigType(rs.SignatureSize)
+		su3File.ReserveSignature(rs.SignatureSize)
+	} else {
+		return nil, errors.New("no signing key")
+	}
 
 	return su3File, nil
 }
 
+// signer returns NextSigner once Cutover has passed, and otherwise Signer,
+// or SigningKey if Signer is not set.
+func (rs *ReseederImpl) signer() crypto.Signer {
+	if rs.NextSigner != nil && !rs.Cutover.IsZero() && !time.Now().Before(rs.Cutover) {
+		return rs.NextSigner
+	}
+	if rs.Signer != nil {
+		return rs.Signer
+	}
+	if rs.SigningKey != nil {
+		return rs.SigningKey
+	}
+	return nil
+}
+
 /*type NetDbProvider interface {
 	// Get all router infos
 	RouterInfos() ([]routerInfo, error)
@@ -233,21 +741,32 @@ func (rs *ReseederImpl) createSu3(seeds []routerInfo) (*su3.File, error) {
 type LocalNetDbImpl struct {
 	Path             string
 	MaxRouterInfoAge time.Duration
+	// Workers is the number of goroutines used to read and parse routerInfos
+	Workers int
+	// AsOf, if set, is the time routerInfo ages are measured from instead of
+	// the current time
+	AsOf time.Time
+
+	cache   *routerInfoCache
+	watcher *netDbWatcher
 }
 
 func NewLocalNetDb(path string, maxAge time.Duration) *LocalNetDbImpl {
 	return &LocalNetDbImpl{
 		Path:             path,
 		MaxRouterInfoAge: maxAge,
+		Workers:          runtime.NumCPU(),
+		cache:            newRouterInfoCache(),
 	}
 }
 
 func (db *LocalNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
-	r, _ := regexp.Compile("^routerInfo-[A-Za-z0-9-=~]+.dat$")
-
 	files := make(map[string]os.FileInfo)
 	walkpath := func(path string, f os.FileInfo, err error) error {
-		if r.MatchString(f.Name()) {
+		if err != nil {
+			return nil
+		}
+		if routerInfoPattern.MatchString(f.Name()) {
 			files[path] = f
 		}
 		return nil
@@ -255,43 +774,104 @@ func (db *LocalNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
 
 	filepath.Walk(db.Path, walkpath)
 
-	for path, file := range files {
-		riBytes, err := os.ReadFile(path)
-		if nil != err {
-			log.Println(err)
-			continue
-		}
+	if db.cache == nil {
+		db.cache = newRouterInfoCache()
+	}
+	db.cache.Prune(files)
 
-		// ignore outdate routerInfos
-		age := time.Since(file.ModTime())
-		if age > db.MaxRouterInfoAge {
-			continue
-		}
-		riStruct, remainder, err := router_info.ReadRouterInfo(riBytes)
-		if err != nil {
-			log.Println("RouterInfo Parsing Error:", err)
-			log.Println("Leftover Data(for debugging):", remainder)
-			continue
-		}
+	workers := db.Workers
+	if workers < 1 {
+		workers = 1
+	}
 
-		// skip crappy routerInfos
-		if riStruct.Reachable() && riStruct.UnCongested() && riStruct.GoodVersion() {
-			routerInfos = append(routerInfos, routerInfo{
-				Name:    file.Name(),
-				ModTime: file.ModTime(),
-				Data:    riBytes,
-				RI:      &riStruct,
-			})
-		} else {
-			log.Println("Skipped less-useful RouterInfo Capabilities:", riStruct.RouterCapabilities(), riStruct.RouterVersion())
+	type job struct {
+		path string
+		file os.FileInfo
+	}
+	jobs := make(chan job)
+	results := make(chan *routerInfo)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if ri := db.loadRouterInfo(j.path, j.file); ri != nil {
+					results <- ri
+				}
+			}
+		}()
+	}
+	go func() {
+		for path, file := range files {
+			// ignore outdate routerInfos
+			age := db.now().Sub(file.ModTime())
+			if age > db.MaxRouterInfoAge {
+				continue
+			}
+			jobs <- job{path, file}
 		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for ri := range results {
+		routerInfos = append(routerInfos, *ri)
 	}
 
 	return
 }
 
-func fanIn(inputs ...<-chan *su3.File) <-chan *su3.File {
-	out := make(chan *su3.File, len(inputs))
+func (db *LocalNetDbImpl) now() time.Time {
+	if db.AsOf.IsZero() {
+		return time.Now()
+	}
+	return db.AsOf
+}
+
+// loadRouterInfo returns the parsed routerInfo at path, or nil if it could not
+// be read, could not be parsed, or is not useful for reseeding. Parse results
+// are cached until the file changes.
+func (db *LocalNetDbImpl) loadRouterInfo(path string, file os.FileInfo) *routerInfo {
+	if entry, ok := db.cache.Get(path, file); ok {
+		return entry.Info
+	}
+
+	riBytes, err := os.ReadFile(path)
+	if nil != err {
+		log.Println(err)
+		return nil
+	}
+
+	riStruct, remainder, err := router_info.ReadRouterInfo(riBytes)
+	if err != nil {
+		log.Println("RouterInfo Parsing Error:", err)
+		log.Println("Leftover Data(for debugging):", remainder)
+		db.cache.Put(path, file, nil)
+		return nil
+	}
+
+	// skip crappy routerInfos
+	if !(riStruct.Reachable() && riStruct.UnCongested() && riStruct.GoodVersion()) {
+		log.Println("Skipped less-useful RouterInfo Capabilities:", riStruct.RouterCapabilities(), riStruct.RouterVersion())
+		db.cache.Put(path, file, nil)
+		return nil
+	}
+
+	ri := &routerInfo{
+		Name:    file.Name(),
+		ModTime: file.ModTime(),
+		Data:    riBytes,
+		RI:      &riStruct,
+	}
+	db.cache.Put(path, file, ri)
+	return ri
+}
+
+func fanIn(inputs ...<-chan builtSu3) <-chan builtSu3 {
+	out := make(chan builtSu3, len(inputs))
 
 	var wg sync.WaitGroup
 	wg.Add(len(inputs))
@@ -303,7 +883,7 @@ func fanIn(inputs ...<-chan *su3.File) <-chan *su3.File {
 
 	// fan-in all the inputs to a single output
 	for _, input := range inputs {
-		go func(in <-chan *su3.File) {
+		go func(in <-chan builtSu3) {
 			defer wg.Done()
 			for n := range in {
 				out <- n
@@ -1,6 +1,11 @@
 package reseed
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -116,3 +121,144 @@ func TestLocalNetDb_DefaultValues(t *testing.T) {
 		})
 	}
 }
+
+func TestReseeder_DeterministicBuild(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	var ris []routerInfo
+	for i := 0; i < 20; i++ {
+		ris = append(ris, routerInfo{
+			Name:    fmt.Sprintf("routerInfo-%02d.dat", i),
+			ModTime: time.Unix(int64(1700000000+i), 0),
+			Data:    []byte(fmt.Sprintf("router info %d", i)),
+		})
+	}
+
+	build := func(seed int64, builders int) [][]byte {
+		rs := NewReseeder(NewLocalNetDb(t.TempDir(), 72*time.Hour))
+		rs.SigningKey = key
+		rs.SignerID = []byte("test@mail.i2p")
+		rs.NumRi = 5
+		rs.NumBuilders = builders
+		rs.Deterministic = true
+		rs.Seed = seed
+		rs.buildTime = time.Unix(1700001000, 0)
+
+		built, err := rs.buildSu3s(ris, 10)
+		if err != nil {
+			t.Fatalf("buildSu3s() failed: %v", err)
+		}
+		bundles, err := rs.marshalSu3s(built)
+		if err != nil {
+			t.Fatalf("marshalSu3s() failed: %v", err)
+		}
+		var set [][]byte
+		for _, b := range bundles {
+			set = append(set, b.Data)
+		}
+		return set
+	}
+
+	first := build(42, 1)
+	second := build(42, 8)
+	if len(first) != 10 || len(second) != 10 {
+		t.Fatalf("Expected 10 su3 files, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if !bytes.Equal(first[i], second[i]) {
+			t.Errorf("su3 file %d differs between builds with the same seed", i)
+		}
+	}
+
+	other := build(43, 1)
+	same := true
+	for i := range first {
+		if !bytes.Equal(first[i], other[i]) {
+			same = false
+		}
+	}
+	if same {
+		t.Error("Builds with different seeds should select different routerInfos")
+	}
+}
+
+// TestReseeder_DeterministicServeDropsStale checks that a started
+// deterministic reseeder measures routerInfo ages from each rebuild, not from
+// BuildTime, so routerInfos which go stale while serving are dropped.
+func TestReseeder_DeterministicServeDropsStale(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	tempDir := t.TempDir()
+	netdb := NewLocalNetDb(tempDir, 72*time.Hour)
+	for name, age := range map[string]time.Duration{
+		"routerInfo-fresh1.dat": time.Hour,
+		"routerInfo-fresh2.dat": time.Hour,
+		// fresh at BuildTime, stale now
+		"routerInfo-stale.dat": 73 * time.Hour,
+	} {
+		path := filepath.Join(tempDir, name)
+		writeRouterInfo(t, path, name, age)
+		file, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the data can't be parsed, so put what a parse would give
+		netdb.cache.Put(path, file, &routerInfo{Name: name, ModTime: file.ModTime(), Data: []byte(name)})
+	}
+
+	rs := NewReseeder(netdb)
+	rs.SigningKey = key
+	rs.SignerID = []byte("test@mail.i2p")
+	rs.NumRi = 2
+	rs.NumSu3 = 10
+	rs.Deterministic = true
+	rs.Seed = 42
+	rs.BuildTime = time.Now().Add(-2 * time.Hour)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := rs.Start(ctx)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	if len(rs.bundles) != 10 {
+		t.Fatalf("Expected 10 su3 files, got %d", len(rs.bundles))
+	}
+	for i, b := range rs.bundles {
+		for _, name := range b.RouterInfos {
+			if name == "routerInfo-stale.dat" {
+				t.Errorf("su3 file %d contains a routerInfo which went stale after BuildTime", i)
+			}
+		}
+	}
+}
+
+func TestReseeder_SetSelection(t *testing.T) {
+	rs := NewReseeder(NewLocalNetDb(t.TempDir(), 72*time.Hour))
+	if rs.applySelection() {
+		t.Error("applySelection() changed the interval without a selection")
+	}
+
+	rs.SetSelection(Selection{NumRi: 50, NumSu3: 10, NumBuilders: 2, RebuildInterval: 90 * time.Hour})
+	if rs.NumRi != 77 {
+		t.Error("SetSelection() applied the selection before the next rebuild")
+	}
+	if rs.applySelection() {
+		t.Error("applySelection() reported an unchanged interval as changed")
+	}
+	if rs.NumRi != 50 || rs.NumSu3 != 10 || rs.NumBuilders != 2 {
+		t.Errorf("selection not applied: %d routerInfos, %d su3 files, %d builders", rs.NumRi, rs.NumSu3, rs.NumBuilders)
+	}
+
+	rs.SetSelection(Selection{NumRi: 50, NumBuilders: 2, RebuildInterval: time.Hour})
+	if !rs.applySelection() || rs.RebuildInterval != time.Hour {
+		t.Error("applySelection() didn't change the rebuild interval")
+	}
+}
@@ -5,6 +5,7 @@ package reseed
 
 import (
 	"strings"
+	"sync"
 )
 
 // AllReseeds contains the list of all available reseed servers.
@@ -23,6 +24,29 @@ var AllReseeds = []string{
 	"https://www2.mk16.de/",
 }
 
+var (
+	reseeds      []string
+	reseedsMutex sync.RWMutex
+)
+
+// SetReseeds replaces the reseed servers pinged for the homepage, which are
+// AllReseeds until it is called.
+func SetReseeds(urls []string) {
+	reseedsMutex.Lock()
+	defer reseedsMutex.Unlock()
+	reseeds = append([]string(nil), urls...)
+}
+
+// Reseeds returns the reseed servers pinged for the homepage.
+func Reseeds() []string {
+	reseedsMutex.RLock()
+	defer reseedsMutex.RUnlock()
+	if reseeds == nil {
+		return AllReseeds
+	}
+	return reseeds
+}
+
 // SignerFilenameFromID creates a filename-safe version of a signer ID.
 // Moved from: utils.go
 func SignerFilenameFromID(signerID string) string {
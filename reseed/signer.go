@@ -0,0 +1,98 @@
+package reseed
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// signerTimeout bounds a whole exchange with the signing daemon.
+const signerTimeout = 30 * time.Second
+
+// signerRequest is sent to the signing daemon, one JSON object per line.
+type signerRequest struct {
+	// Op is "public" to fetch the public key or "sign" to sign Digest
+	Op     string      `json:"op"`
+	Hash   crypto.Hash `json:"hash,omitempty"`
+	Digest []byte      `json:"digest,omitempty"`
+}
+
+// signerResponse is the signing daemon's answer to a signerRequest.
+type signerResponse struct {
+	PublicKey []byte `json:"public_key,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RemoteSigner is a crypto.Signer whose private key is held by a signing
+// daemon listening on a Unix domain socket.
+type RemoteSigner struct {
+	Path string
+
+	public crypto.PublicKey
+}
+
+// DialSigner connects to the signing daemon at path and fetches its public
+// key.
+func DialSigner(path string) (*RemoteSigner, error) {
+	rs := &RemoteSigner{Path: path}
+	resp, err := rs.call(signerRequest{Op: "public"})
+	if nil != err {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if nil != err {
+		return nil, fmt.Errorf("signing daemon sent a bad public key: %s", err)
+	}
+	if _, ok := pub.(*rsa.PublicKey); !ok {
+		return nil, errors.New("signing daemon does not hold an RSA key")
+	}
+	rs.public = pub
+	return rs, nil
+}
+
+func (rs *RemoteSigner) Public() crypto.PublicKey {
+	return rs.public
+}
+
+// Sign asks the signing daemon to sign digest. Only PKCS#1 v1.5 signatures
+// are supported, so opts must be a crypto.Hash.
+func (rs *RemoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash, ok := opts.(crypto.Hash)
+	if !ok {
+		return nil, errors.New("signing daemon only makes PKCS#1 v1.5 signatures")
+	}
+	resp, err := rs.call(signerRequest{Op: "sign", Hash: hash, Digest: digest})
+	if nil != err {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// call makes a single request to the signing daemon.
+func (rs *RemoteSigner) call(req signerRequest) (signerResponse, error) {
+	var resp signerResponse
+	conn, err := net.DialTimeout("unix", rs.Path, signerTimeout)
+	if nil != err {
+		return resp, fmt.Errorf("unable to reach signing daemon: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(signerTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); nil != err {
+		return resp, fmt.Errorf("unable to send request to signing daemon: %s", err)
+	}
+	if err := json.NewDecoder(conn).Decode(&resp); nil != err {
+		return resp, fmt.Errorf("unable to read response from signing daemon: %s", err)
+	}
+	if resp.Error != "" {
+		return resp, fmt.Errorf("signing daemon: %s", resp.Error)
+	}
+	return resp, nil
+}
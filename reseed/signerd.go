@@ -0,0 +1,211 @@
+package reseed
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	throttled "github.com/throttled/throttled/v2"
+	"github.com/throttled/throttled/v2/store"
+)
+
+// peerCred identifies the process on the other end of a Unix domain socket.
+type peerCred struct {
+	UID int
+	GID int
+	PID int
+}
+
+// SignerDaemon holds a signing key and signs digests for local processes
+// over a Unix domain socket, so the reseeder never loads the key itself.
+type SignerDaemon struct {
+	Key crypto.Signer
+	// AllowedUIDs and AllowedGIDs are the users and groups which may use the
+	// key. If both are empty only the daemon's own user may use it.
+	AllowedUIDs []int
+	AllowedGIDs []int
+	// RateLimit is the number of signatures each user may request per
+	// minute, and RateBurst how many more may be requested at once before
+	// the limit applies. 0 disables the limit.
+	RateLimit int
+	RateBurst int
+	// Audit receives a line for every connection and request
+	Audit *log.Logger
+
+	limiter *throttled.GCRARateLimiter
+}
+
+func NewSignerDaemon(key crypto.Signer) *SignerDaemon {
+	return &SignerDaemon{
+		Key:       key,
+		RateLimit: 600,
+		RateBurst: 400,
+		Audit:     log.New(os.Stdout, "signer: ", log.LstdFlags),
+	}
+}
+
+// ListenAndServe listens on a Unix domain socket at path and serves signing
+// requests on it. Unless other users or groups are allowed, only the
+// daemon's user can connect to the socket at all.
+func (d *SignerDaemon) ListenAndServe(path string) error {
+	// a socket left behind by a previous run
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	l, err := net.Listen("unix", path)
+	if nil != err {
+		return err
+	}
+	defer l.Close()
+	// other users are checked by their peer credentials, so the socket only
+	// has to keep out everyone when nobody else is allowed
+	perm := os.FileMode(0o666)
+	if len(d.AllowedUIDs) == 0 && len(d.AllowedGIDs) == 0 {
+		perm = 0o600
+	}
+	if err := os.Chmod(path, perm); nil != err {
+		return err
+	}
+
+	return d.Serve(l)
+}
+
+// Serve accepts connections on l until it is closed.
+func (d *SignerDaemon) Serve(l net.Listener) error {
+	if d.RateLimit > 0 {
+		limiter, err := throttled.NewGCRARateLimiter(store.NewMemStore(1024), throttled.RateQuota{
+			MaxRate:  throttled.PerMin(d.RateLimit),
+			MaxBurst: d.RateBurst,
+		})
+		if nil != err {
+			return err
+		}
+		d.limiter = limiter
+	}
+
+	for {
+		conn, err := l.Accept()
+		if nil != err {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *SignerDaemon) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(signerTimeout))
+
+	cred, err := peerCredentials(conn)
+	if nil != err {
+		d.Audit.Printf("refused connection: unable to read peer credentials: %s", err)
+		return
+	}
+	if !d.allowed(cred) {
+		d.Audit.Printf("refused connection from uid=%d gid=%d pid=%d: not allowed", cred.UID, cred.GID, cred.PID)
+		return
+	}
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req signerRequest
+		if err := dec.Decode(&req); nil != err {
+			return
+		}
+		resp := d.respond(req, cred)
+		if err := enc.Encode(resp); nil != err {
+			return
+		}
+	}
+}
+
+// respond answers a single request and writes it to the audit log.
+func (d *SignerDaemon) respond(req signerRequest, cred peerCred) signerResponse {
+	var resp signerResponse
+	var err error
+	switch req.Op {
+	case "public":
+		resp.PublicKey, err = x509.MarshalPKIXPublicKey(d.Key.Public())
+	case "sign":
+		resp.Signature, err = d.sign(req, cred)
+	default:
+		err = fmt.Errorf("unknown op %q", req.Op)
+	}
+
+	result := "ok"
+	if nil != err {
+		resp = signerResponse{Error: err.Error()}
+		result = "error: " + err.Error()
+	}
+	d.Audit.Printf("uid=%d gid=%d pid=%d op=%s hash=%s digest=%x %s", cred.UID, cred.GID, cred.PID, req.Op, hashName(req.Hash), req.Digest, result)
+	return resp
+}
+
+func (d *SignerDaemon) sign(req signerRequest, cred peerCred) ([]byte, error) {
+	switch req.Hash {
+	case 0:
+		// a raw su3 signature of an already hashed body
+		switch len(req.Digest) {
+		case crypto.SHA256.Size(), crypto.SHA384.Size(), crypto.SHA512.Size():
+		default:
+			return nil, fmt.Errorf("digest is %d bytes, not a SHA-256, SHA-384 or SHA-512 digest", len(req.Digest))
+		}
+	case crypto.SHA256, crypto.SHA384, crypto.SHA512:
+		if len(req.Digest) != req.Hash.Size() {
+			return nil, fmt.Errorf("digest is %d bytes, expected %d", len(req.Digest), req.Hash.Size())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported hash %s", hashName(req.Hash))
+	}
+
+	if d.limiter != nil {
+		limited, result, err := d.limiter.RateLimit(strconv.Itoa(cred.UID), 1)
+		if nil != err {
+			return nil, err
+		}
+		if limited {
+			return nil, fmt.Errorf("rate limited, retry after %s", result.RetryAfter.Round(time.Second))
+		}
+	}
+
+	return d.Key.Sign(rand.Reader, req.Digest, req.Hash)
+}
+
+// allowed reports whether the peer may use the key.
+func (d *SignerDaemon) allowed(cred peerCred) bool {
+	if len(d.AllowedUIDs) == 0 && len(d.AllowedGIDs) == 0 {
+		return cred.UID == os.Getuid()
+	}
+	for _, uid := range d.AllowedUIDs {
+		if cred.UID == uid {
+			return true
+		}
+	}
+	for _, gid := range d.AllowedGIDs {
+		if cred.GID == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// hashName names a hash for the audit log.
+func hashName(h crypto.Hash) string {
+	if h == 0 {
+		return "raw"
+	}
+	return h.String()
+}
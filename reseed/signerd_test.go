@@ -0,0 +1,132 @@
+package reseed
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func startTestSignerDaemon(t *testing.T, d *SignerDaemon) string {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on Linux")
+	}
+	// unix socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "signer")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "s.sock")
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go d.Serve(l)
+	return path
+}
+
+func TestSignerDaemon(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	var audit strings.Builder
+	d := NewSignerDaemon(key)
+	d.Audit = log.New(&audit, "", 0)
+	d.RateLimit = 60
+	d.RateBurst = 1
+	path := startTestSignerDaemon(t, d)
+
+	signer, err := DialSigner(path)
+	if err != nil {
+		t.Fatalf("DialSigner() failed: %v", err)
+	}
+	if !key.PublicKey.Equal(signer.Public()) {
+		t.Error("RemoteSigner should have the daemon's public key")
+	}
+
+	su3File := su3.New()
+	su3File.SignerID = []byte("test@mail.i2p")
+	su3File.Content = []byte("test content")
+	if err := su3File.SignWith(signer); err != nil {
+		t.Fatalf("SignWith() failed: %v", err)
+	}
+	if err := su3File.VerifySignature(cert); err != nil {
+		t.Errorf("su3 signed through the daemon should verify: %v", err)
+	}
+
+	// tree heads are signed with the digest algorithm identified
+	digest := sha256.Sum256([]byte("tree head"))
+	sig, err := signer.Sign(nil, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+		t.Errorf("Signature from the daemon should verify: %v", err)
+	}
+
+	// the burst is used up
+	if _, err := signer.Sign(nil, digest[:], crypto.SHA256); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+
+	if _, err := signer.Sign(nil, []byte("short"), crypto.Hash(0)); err == nil {
+		t.Error("Sign() should refuse a digest of the wrong length")
+	}
+	if _, err := signer.Sign(nil, make([]byte, 20), crypto.SHA1); err == nil {
+		t.Error("Sign() should refuse SHA-1")
+	}
+
+	if !strings.Contains(audit.String(), "op=sign hash=raw") || !strings.Contains(audit.String(), "op=sign hash=SHA-256") {
+		t.Errorf("Every request should be audited, got:\n%s", audit.String())
+	}
+}
+
+func TestSignerDaemon_PeerCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	d := NewSignerDaemon(key)
+	d.Audit = log.New(io.Discard, "", 0)
+	d.AllowedUIDs = []int{os.Getuid() + 1}
+	path := startTestSignerDaemon(t, d)
+
+	if _, err := DialSigner(path); err == nil {
+		t.Error("DialSigner() should fail for a user who is not allowed")
+	}
+
+	d = NewSignerDaemon(key)
+	d.Audit = log.New(io.Discard, "", 0)
+	d.AllowedUIDs = []int{os.Getuid() + 1}
+	d.AllowedGIDs = []int{os.Getgid()}
+	path = startTestSignerDaemon(t, d)
+	if _, err := DialSigner(path); err != nil {
+		t.Errorf("DialSigner() should succeed for an allowed group: %v", err)
+	}
+}
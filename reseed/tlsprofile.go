@@ -0,0 +1,201 @@
+package reseed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TLSProfile is a TLS policy for the HTTPS listener.
+type TLSProfile struct {
+	Name       string
+	MinVersion uint16
+	// CipherSuites are the TLS 1.2 cipher suites, in order of preference.
+	// TLS 1.3 suites are not configurable.
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	// SessionTicketRotation is how often session ticket keys are replaced.
+	// Zero leaves it to crypto/tls, which replaces them daily, and a negative
+	// value turns session tickets off.
+	SessionTicketRotation time.Duration
+}
+
+// TLS profiles which can be chosen by name.
+var (
+	// ModernTLSProfile only allows TLS 1.3 with the P-384 and P-521 curves.
+	ModernTLSProfile = TLSProfile{
+		Name:             "modern",
+		MinVersion:       tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{tls.CurveP384, tls.CurveP521},
+	}
+	// IntermediateTLSProfile also allows TLS 1.2 with forward secret AEAD
+	// cipher suites, and the common curves, for older clients.
+	IntermediateTLSProfile = TLSProfile{
+		Name:       "intermediate",
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	}
+)
+
+// TLSProfileByName returns the profile called name.
+func TLSProfileByName(name string) (TLSProfile, error) {
+	switch strings.ToLower(name) {
+	case ModernTLSProfile.Name:
+		return ModernTLSProfile, nil
+	case IntermediateTLSProfile.Name:
+		return IntermediateTLSProfile, nil
+	}
+	return TLSProfile{}, fmt.Errorf("unknown TLS profile %q, use modern, intermediate or custom", name)
+}
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurveNames = map[tls.CurveID]string{
+	tls.X25519:         "X25519",
+	tls.CurveP256:      "P-256",
+	tls.CurveP384:      "P-384",
+	tls.CurveP521:      "P-521",
+	tls.X25519MLKEM768: "X25519MLKEM768",
+}
+
+// NewCustomTLSProfile builds a profile from the minimum version ("1.2" or
+// "1.3"), the crypto/tls names of secure TLS 1.2 cipher suites, curve names
+// such as X25519 or P-384, and the session ticket rotation: "auto", "off" or
+// a duration.
+func NewCustomTLSProfile(minVersion string, cipherSuites, curves []string, ticketRotation string) (TLSProfile, error) {
+	p := TLSProfile{Name: "custom"}
+	var ok bool
+	if p.MinVersion, ok = tlsVersions[minVersion]; !ok {
+		return p, fmt.Errorf("unknown TLS version %q, use 1.2 or 1.3", minVersion)
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, name := range cipherSuites {
+		id, ok := suites[strings.ToUpper(name)]
+		if !ok {
+			return p, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		p.CipherSuites = append(p.CipherSuites, id)
+	}
+
+	for _, name := range curves {
+		id, ok := curveByName(name)
+		if !ok {
+			return p, fmt.Errorf("unknown TLS curve %q, use X25519, P-256, P-384, P-521 or X25519MLKEM768", name)
+		}
+		p.CurvePreferences = append(p.CurvePreferences, id)
+	}
+
+	switch ticketRotation {
+	case "", "auto":
+	case "off":
+		p.SessionTicketRotation = -1
+	default:
+		d, err := time.ParseDuration(ticketRotation)
+		if nil != err || d <= 0 {
+			return p, fmt.Errorf("session ticket rotation %q is not auto, off or a duration", ticketRotation)
+		}
+		p.SessionTicketRotation = d
+	}
+	return p, nil
+}
+
+// Apply sets the policy of the profile on config.
+func (p TLSProfile) Apply(config *tls.Config) {
+	config.MinVersion = p.MinVersion
+	config.CipherSuites = p.CipherSuites
+	config.CurvePreferences = p.CurvePreferences
+	config.SessionTicketsDisabled = p.SessionTicketRotation < 0
+}
+
+// String describes the profile, to report which one is used.
+func (p TLSProfile) String() string {
+	var parts []string
+	if p.MinVersion == tls.VersionTLS13 {
+		parts = append(parts, "TLS 1.3")
+	} else {
+		parts = append(parts, fmt.Sprintf("%s and later", tls.VersionName(p.MinVersion)))
+		if len(p.CipherSuites) > 0 {
+			var names []string
+			for _, id := range p.CipherSuites {
+				names = append(names, tls.CipherSuiteName(id))
+			}
+			parts = append(parts, "TLS 1.2 cipher suites "+strings.Join(names, ", "))
+		}
+	}
+	if len(p.CurvePreferences) > 0 {
+		var names []string
+		for _, id := range p.CurvePreferences {
+			names = append(names, curveName(id))
+		}
+		parts = append(parts, "curves "+strings.Join(names, ", "))
+	}
+	switch {
+	case p.SessionTicketRotation < 0:
+		parts = append(parts, "no session tickets")
+	case p.SessionTicketRotation > 0:
+		parts = append(parts, fmt.Sprintf("session ticket keys rotated every %s", p.SessionTicketRotation))
+	}
+	return p.Name + ": " + strings.Join(parts, "; ")
+}
+
+func curveByName(name string) (tls.CurveID, bool) {
+	for id, n := range tlsCurveNames {
+		if strings.EqualFold(n, name) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func curveName(id tls.CurveID) string {
+	if name, ok := tlsCurveNames[id]; ok {
+		return name
+	}
+	return id.String()
+}
+
+// rotateSessionTicketKeys replaces the session ticket key of config every
+// interval, until ctx is done. Tickets issued with the previous key are
+// still accepted for one more interval.
+func rotateSessionTicketKeys(ctx context.Context, config *tls.Config, interval time.Duration) error {
+	var current, previous [32]byte
+	if _, err := rand.Read(current[:]); nil != err {
+		return err
+	}
+	config.SetSessionTicketKeys([][32]byte{current})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				previous = current
+				if _, err := rand.Read(current[:]); nil != err {
+					continue
+				}
+				config.SetSessionTicketKeys([][32]byte{current, previous})
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,130 @@
+package reseed
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewCustomTLSProfile(t *testing.T) {
+	p, err := NewCustomTLSProfile("1.2", []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}, []string{"x25519", "P-384"}, "6h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TLSProfile{
+		Name:                  "custom",
+		MinVersion:            tls.VersionTLS12,
+		CipherSuites:          []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		CurvePreferences:      []tls.CurveID{tls.X25519, tls.CurveP384},
+		SessionTicketRotation: 6 * time.Hour,
+	}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("profile = %+v, want %+v", p, want)
+	}
+	if s := p.String(); !strings.Contains(s, "curves X25519, P-384") || !strings.Contains(s, "every 6h0m0s") {
+		t.Errorf("String() = %q", s)
+	}
+
+	p, err = NewCustomTLSProfile("1.3", nil, nil, "off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &tls.Config{}
+	p.Apply(config)
+	if !config.SessionTicketsDisabled || config.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Apply() gave %+v", config)
+	}
+
+	for _, bad := range [][]string{
+		{"1.1", "", "", "auto"},
+		{"1.2", "TLS_RSA_WITH_RC4_128_SHA", "", "auto"},
+		{"1.2", "", "P-224", "auto"},
+		{"1.2", "", "", "sometimes"},
+	} {
+		var suites, curves []string
+		if bad[1] != "" {
+			suites = []string{bad[1]}
+		}
+		if bad[2] != "" {
+			curves = []string{bad[2]}
+		}
+		if _, err := NewCustomTLSProfile(bad[0], suites, curves, bad[3]); err == nil {
+			t.Errorf("NewCustomTLSProfile(%q) was accepted", bad)
+		}
+	}
+}
+
+func TestTLSProfileByName(t *testing.T) {
+	for _, name := range []string{"modern", "Intermediate"} {
+		p, err := TLSProfileByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(p.Name, name) {
+			t.Errorf("TLSProfileByName(%q) = %s", name, p.Name)
+		}
+	}
+	if _, err := TLSProfileByName("custom"); err == nil {
+		t.Error("custom needs its settings")
+	}
+}
+
+// TestTLSProfileHandshake checks that TLS 1.2 clients are only served by
+// the intermediate profile.
+func TestTLSProfileHandshake(t *testing.T) {
+	certFile, keyFile := writeTestPair(t, t.TempDir(), "localhost", "localhost")
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		profile TLSProfile
+		ok      bool
+	}{
+		{ModernTLSProfile, false},
+		{IntermediateTLSProfile, true},
+	} {
+		config := &tls.Config{Certificates: []tls.Certificate{cert}}
+		tc.profile.Apply(config)
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}()
+
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		conn, err := tls.DialWithDialer(dialer, "tcp", ln.Addr().String(), &tls.Config{
+			InsecureSkipVerify: true,
+			MaxVersion:         tls.VersionTLS12,
+		})
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: TLS 1.2 handshake error = %v", tc.profile.Name, err)
+		}
+		if err == nil {
+			conn.Close()
+		}
+		ln.Close()
+	}
+}
+
+func TestRotateSessionTicketKeys(t *testing.T) {
+	config := &tls.Config{}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := rotateSessionTicketKeys(ctx, config, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	cancel()
+}
@@ -0,0 +1,446 @@
+package reseed
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogEntry records a single su3 file published by the reseeder.
+type LogEntry struct {
+	SHA256      string    `json:"sha256"`
+	Size        int       `json:"size"`
+	SignerID    string    `json:"signer"`
+	Built       time.Time `json:"built"`
+	SetSize     int       `json:"set_size"`
+	SetIndex    int       `json:"set_index"`
+	RouterInfos int       `json:"routerinfos"`
+	// Seed is only recorded for deterministic builds
+	Seed *int64 `json:"seed,omitempty"`
+}
+
+// SignedTreeHead commits to every entry in the log. It is signed with the su3
+// signing key, so it can be checked against the reseeder certificate.
+type SignedTreeHead struct {
+	TreeSize  int    `json:"tree_size"`
+	RootHash  string `json:"root_hash"`
+	Timestamp int64  `json:"timestamp"`
+	SignerID  string `json:"signer"`
+	Signature string `json:"signature"`
+}
+
+// InclusionProof shows that an entry is included in the tree described by
+// Head.
+type InclusionProof struct {
+	Index     int            `json:"index"`
+	Entry     LogEntry       `json:"entry"`
+	LeafHash  string         `json:"leaf_hash"`
+	AuditPath []string       `json:"audit_path"`
+	Head      SignedTreeHead `json:"head"`
+}
+
+// TransparencyLog is an append-only Merkle tree (RFC 6962) of every su3 file
+// the reseeder publishes. Entries are persisted one JSON object per line, and
+// the leaf hash is taken over the line exactly as stored.
+type TransparencyLog struct {
+	Path       string
+	SigningKey crypto.Signer
+	SignerID   []byte
+
+	entries []LogEntry
+	leaves  [][]byte
+	index   map[string]int
+	head    SignedTreeHead
+	m       sync.RWMutex
+}
+
+// OpenTransparencyLog loads the log at path, creating it if it doesn't exist.
+func OpenTransparencyLog(path string, key crypto.Signer, signerID []byte) (*TransparencyLog, error) {
+	tl := &TransparencyLog{
+		Path:       path,
+		SigningKey: key,
+		SignerID:   signerID,
+		index:      make(map[string]int),
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry LogEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("corrupt transparency log entry %d: %s", len(tl.entries), err)
+		}
+		tl.add(entry, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tl.signHead(); err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+func (tl *TransparencyLog) add(entry LogEntry, line []byte) {
+	if _, ok := tl.index[entry.SHA256]; !ok {
+		tl.index[entry.SHA256] = len(tl.entries)
+	}
+	tl.entries = append(tl.entries, entry)
+	tl.leaves = append(tl.leaves, leafHash(line))
+}
+
+// Append durably records entries and signs a new tree head.
+func (tl *TransparencyLog) Append(entries []LogEntry) error {
+	tl.m.Lock()
+	defer tl.m.Unlock()
+
+	f, err := os.OpenFile(tl.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	lines := make([][]byte, len(entries))
+	for i, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		tl.add(entry, lines[i])
+	}
+	return tl.signHead()
+}
+
+// signHead signs a tree head for the current entries. Must be called with
+// the lock held, or before the log is shared.
+func (tl *TransparencyLog) signHead() error {
+	head := SignedTreeHead{
+		TreeSize:  len(tl.leaves),
+		RootHash:  hex.EncodeToString(merkleRoot(tl.leaves)),
+		Timestamp: time.Now().Unix(),
+		SignerID:  string(tl.SignerID),
+	}
+	if tl.SigningKey != nil {
+		digest := sha256.Sum256(head.signedBytes())
+		sig, err := tl.SigningKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return err
+		}
+		head.Signature = base64.StdEncoding.EncodeToString(sig)
+	}
+	tl.head = head
+	return nil
+}
+
+// SetSigningKey signs the tree heads with key from now on, starting with
+// the current one.
+func (tl *TransparencyLog) SetSigningKey(key crypto.Signer) error {
+	tl.m.Lock()
+	defer tl.m.Unlock()
+
+	tl.SigningKey = key
+	return tl.signHead()
+}
+
+// Head returns the latest signed tree head.
+func (tl *TransparencyLog) Head() SignedTreeHead {
+	tl.m.RLock()
+	defer tl.m.RUnlock()
+
+	return tl.head
+}
+
+// Size returns the number of entries in the log.
+func (tl *TransparencyLog) Size() int {
+	tl.m.RLock()
+	defer tl.m.RUnlock()
+
+	return len(tl.entries)
+}
+
+// Entries returns the entries in [start, end).
+func (tl *TransparencyLog) Entries(start, end int) ([]LogEntry, error) {
+	tl.m.RLock()
+	defer tl.m.RUnlock()
+
+	if end > len(tl.entries) {
+		end = len(tl.entries)
+	}
+	if start < 0 || start > end {
+		return nil, fmt.Errorf("invalid range %d-%d for log of size %d", start, end, len(tl.entries))
+	}
+	out := make([]LogEntry, end-start)
+	copy(out, tl.entries[start:end])
+	return out, nil
+}
+
+// Prove returns an inclusion proof for the su3 file with the given SHA-256
+// digest against the latest signed tree head.
+func (tl *TransparencyLog) Prove(sha256Hex string) (*InclusionProof, error) {
+	tl.m.RLock()
+	defer tl.m.RUnlock()
+
+	i, ok := tl.index[sha256Hex]
+	if !ok {
+		return nil, errors.New("su3 file is not in the transparency log")
+	}
+	leaves := tl.leaves[:tl.head.TreeSize]
+	path := inclusionPath(i, leaves)
+	proof := &InclusionProof{
+		Index:    i,
+		Entry:    tl.entries[i],
+		LeafHash: hex.EncodeToString(leaves[i]),
+		Head:     tl.head,
+	}
+	for _, h := range path {
+		proof.AuditPath = append(proof.AuditPath, hex.EncodeToString(h))
+	}
+	return proof, nil
+}
+
+// Consistency returns the RFC 6962 consistency proof between the tree of the
+// first oldSize entries and the latest signed tree head.
+func (tl *TransparencyLog) Consistency(oldSize int) ([]string, SignedTreeHead, error) {
+	tl.m.RLock()
+	defer tl.m.RUnlock()
+
+	if oldSize < 1 || oldSize > tl.head.TreeSize {
+		return nil, tl.head, fmt.Errorf("invalid tree size %d for log of size %d", oldSize, tl.head.TreeSize)
+	}
+	out := []string{}
+	for _, h := range consistencyPath(oldSize, tl.leaves[:tl.head.TreeSize], true) {
+		out = append(out, hex.EncodeToString(h))
+	}
+	return out, tl.head, nil
+}
+
+func (h SignedTreeHead) signedBytes() []byte {
+	return []byte(fmt.Sprintf("reseed-tools tree head v1\n%d\n%s\n%d\n%s\n", h.TreeSize, h.RootHash, h.Timestamp, h.SignerID))
+}
+
+// VerifySignature checks the tree head signature against the reseeder
+// certificate.
+func (h SignedTreeHead) VerifySignature(cert *x509.Certificate) error {
+	sig, err := base64.StdEncoding.DecodeString(h.Signature)
+	if err != nil {
+		return err
+	}
+	return cert.CheckSignature(x509.SHA256WithRSA, h.signedBytes(), sig)
+}
+
+// Verify checks that the proof is for the su3 file with the given SHA-256
+// digest and that its entry is included in the tree committed to by its head.
+// The head signature must be checked separately.
+func (p *InclusionProof) Verify(sha256Hex string) error {
+	if p.Entry.SHA256 != sha256Hex {
+		return errors.New("inclusion proof is for a different su3 file")
+	}
+	line, err := json.Marshal(p.Entry)
+	if err != nil {
+		return err
+	}
+	leaf := leafHash(line)
+	if hex.EncodeToString(leaf) != p.LeafHash {
+		return errors.New("inclusion proof leaf hash does not match its entry")
+	}
+	root, err := hex.DecodeString(p.Head.RootHash)
+	if err != nil {
+		return err
+	}
+	var path [][]byte
+	for _, s := range p.AuditPath {
+		h, err := hex.DecodeString(s)
+		if err != nil {
+			return err
+		}
+		path = append(path, h)
+	}
+	if !VerifyInclusion(leaf, p.Index, p.Head.TreeSize, path, root) {
+		return errors.New("inclusion proof does not match the signed tree head")
+	}
+	return nil
+}
+
+// NewLogEntry describes the setIndex'th of setSize su3 files for the
+// transparency log.
+func NewLogEntry(data, signerID []byte, built time.Time, setSize, setIndex, routerInfos int, seed *int64) LogEntry {
+	digest := sha256.Sum256(data)
+	return LogEntry{
+		SHA256:      hex.EncodeToString(digest[:]),
+		Size:        len(data),
+		SignerID:    string(signerID),
+		Built:       built.UTC(),
+		SetSize:     setSize,
+		SetIndex:    setIndex,
+		RouterInfos: routerInfos,
+		Seed:        seed,
+	}
+}
+
+// RFC 6962 Merkle tree hashing
+
+func leafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// splitPoint returns the largest power of two smaller than n.
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func merkleRoot(leaves [][]byte) []byte {
+	switch len(leaves) {
+	case 0:
+		h := sha256.Sum256(nil)
+		return h[:]
+	case 1:
+		return leaves[0]
+	}
+	k := splitPoint(len(leaves))
+	return nodeHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+func inclusionPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) <= 1 {
+		return nil
+	}
+	k := splitPoint(len(leaves))
+	if m < k {
+		return append(inclusionPath(m, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+	return append(inclusionPath(m-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
+
+func consistencyPath(m int, leaves [][]byte, complete bool) [][]byte {
+	n := len(leaves)
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{merkleRoot(leaves)}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(consistencyPath(m, leaves[:k], complete), merkleRoot(leaves[k:]))
+	}
+	return append(consistencyPath(m-k, leaves[k:], false), merkleRoot(leaves[:k]))
+}
+
+// VerifyInclusion checks an RFC 6962 audit path for the leaf at index in a
+// tree of the given size.
+func VerifyInclusion(leaf []byte, index, size int, path [][]byte, root []byte) bool {
+	if index < 0 || index >= size {
+		return false
+	}
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range path {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// VerifyConsistency checks an RFC 6962 consistency proof between an old and a
+// new tree head, showing that the log only grew between them.
+func VerifyConsistency(oldSize, newSize int, oldRoot, newRoot []byte, proof [][]byte) bool {
+	if oldSize < 1 || oldSize > newSize {
+		return false
+	}
+	if oldSize == newSize {
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+	if oldSize&(oldSize-1) == 0 {
+		// the old tree is a complete subtree, so its root starts the path
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return false
+	}
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot)
+}
@@ -0,0 +1,145 @@
+package reseed
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
+)
+
+func testLogEntries(n, offset int) []LogEntry {
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := make([]LogEntry, n)
+	for i := range entries {
+		data := []byte(fmt.Sprintf("su3 file %d", offset+i))
+		entries[i] = NewLogEntry(data, []byte("test@mail.i2p"), built, n, i, 77, nil)
+	}
+	return entries
+}
+
+func TestTransparencyLog_AppendAndProve(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	certDer, err := su3.NewSigningCertificate("test@mail.i2p", key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDer)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "transparency.log")
+	tl, err := OpenTransparencyLog(path, key, []byte("test@mail.i2p"))
+	if err != nil {
+		t.Fatalf("OpenTransparencyLog() failed: %v", err)
+	}
+
+	// appending sets of different sizes exercises unbalanced trees
+	for i, n := range []int{1, 4, 7} {
+		if err := tl.Append(testLogEntries(n, i*100)); err != nil {
+			t.Fatalf("Append() failed: %v", err)
+		}
+	}
+	if tl.Size() != 12 {
+		t.Fatalf("Expected 12 entries, got %d", tl.Size())
+	}
+
+	head := tl.Head()
+	if err := head.VerifySignature(cert); err != nil {
+		t.Errorf("Tree head signature should verify: %v", err)
+	}
+
+	for i, n := range []int{1, 4, 7} {
+		for j := 0; j < n; j++ {
+			digest := sha256.Sum256([]byte(fmt.Sprintf("su3 file %d", i*100+j)))
+			sum := hex.EncodeToString(digest[:])
+			proof, err := tl.Prove(sum)
+			if err != nil {
+				t.Fatalf("Prove() failed: %v", err)
+			}
+			if err := proof.Verify(sum); err != nil {
+				t.Errorf("Proof for entry %d should verify: %v", proof.Index, err)
+			}
+		}
+	}
+
+	if _, err := tl.Prove("00"); err == nil {
+		t.Error("Prove() should fail for an unknown su3 file")
+	}
+
+	// a tampered entry must not verify
+	digest := sha256.Sum256([]byte("su3 file 0"))
+	sum := hex.EncodeToString(digest[:])
+	proof, err := tl.Prove(sum)
+	if err != nil {
+		t.Fatalf("Prove() failed: %v", err)
+	}
+	proof.Entry.RouterInfos = 1
+	if err := proof.Verify(sum); err == nil {
+		t.Error("Proof with a tampered entry should not verify")
+	}
+
+	// a tampered tree head must not verify
+	head.TreeSize++
+	if err := head.VerifySignature(cert); err == nil {
+		t.Error("Tampered tree head signature should not verify")
+	}
+
+	// reopening the log must reproduce the same tree
+	reopened, err := OpenTransparencyLog(path, key, []byte("test@mail.i2p"))
+	if err != nil {
+		t.Fatalf("OpenTransparencyLog() failed: %v", err)
+	}
+	if reopened.Head().RootHash != tl.Head().RootHash {
+		t.Error("Reopened log should have the same root hash")
+	}
+}
+
+func TestTransparencyLog_Consistency(t *testing.T) {
+	var leaves [][]byte
+	var roots [][]byte
+	for i := 0; i < 20; i++ {
+		leaves = append(leaves, leafHash([]byte(fmt.Sprintf("entry %d", i))))
+		roots = append(roots, merkleRoot(leaves))
+	}
+
+	for newSize := 1; newSize <= len(leaves); newSize++ {
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			proof := consistencyPath(oldSize, leaves[:newSize], true)
+			if !VerifyConsistency(oldSize, newSize, roots[oldSize-1], roots[newSize-1], proof) {
+				t.Errorf("Consistency proof from %d to %d should verify", oldSize, newSize)
+			}
+			if oldSize < newSize && VerifyConsistency(oldSize, newSize, roots[newSize-1], roots[newSize-1], proof) {
+				t.Errorf("Consistency proof from %d to %d should not verify with the wrong old root", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusion(t *testing.T) {
+	var leaves [][]byte
+	for i := 0; i < 13; i++ {
+		leaves = append(leaves, leafHash([]byte(fmt.Sprintf("entry %d", i))))
+	}
+	root := merkleRoot(leaves)
+
+	for i := range leaves {
+		path := inclusionPath(i, leaves)
+		if !VerifyInclusion(leaves[i], i, len(leaves), path, root) {
+			t.Errorf("Inclusion proof for leaf %d should verify", i)
+		}
+		if VerifyInclusion(leaves[i], (i+1)%len(leaves), len(leaves), path, root) {
+			t.Errorf("Inclusion proof for leaf %d should not verify at another index", i)
+		}
+	}
+}
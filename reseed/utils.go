@@ -1,14 +1,16 @@
 package reseed
 
 import (
+	"crypto"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"crypto/x509"
-	"crypto/x509/pkix"
 	"math/big"
 	"net"
 	"strings"
 	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
 )
 
 // KeyStore struct and methods moved to keystore.go
@@ -25,12 +27,23 @@ func NewTLSCertificate(host string, priv *ecdsa.PrivateKey) ([]byte, error) {
 }
 
 func NewTLSCertificateAltNames(priv *ecdsa.PrivateKey, hosts ...string) ([]byte, error) {
+	return NewTLSCertificateWithOptions(priv, su3.CertificateOptions{}, hosts...)
+}
+
+// NewTLSCertificateWithOptions creates a self-signed TLS certificate for
+// hosts with the subject and validity given by opts. It is valid for 5 years
+// by default. priv may be an ECDSA, RSA or Ed25519 key.
+func NewTLSCertificateWithOptions(priv crypto.Signer, opts su3.CertificateOptions, hosts ...string) ([]byte, error) {
 	notBefore := time.Now()
 	notAfter := notBefore.Add(5 * 365 * 24 * time.Hour)
+	if opts.Validity > 0 {
+		notAfter = notBefore.Add(opts.Validity)
+	}
 	host := ""
 	if len(hosts) > 0 {
 		host = hosts[0]
 	}
+	altNames := hosts[1:]
 
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
@@ -40,27 +53,23 @@ func NewTLSCertificateAltNames(priv *ecdsa.PrivateKey, hosts ...string) ([]byte,
 
 	template := x509.Certificate{
 		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization:       []string{"I2P Anonymous Network"},
-			OrganizationalUnit: []string{"I2P"},
-			Locality:           []string{"XX"},
-			StreetAddress:      []string{"XX"},
-			Country:            []string{"XX"},
-			CommonName:         host,
-		},
-		NotBefore:          notBefore,
-		NotAfter:           notAfter,
-		SignatureAlgorithm: x509.ECDSAWithSHA512,
+		Subject:      opts.Subject(host),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
 
 		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		IsCA:                  true,
-		DNSNames:              hosts[1:],
 	}
 
-	hosts = strings.Split(host, ",")
-	for _, h := range hosts {
+	if _, ok := priv.(*ecdsa.PrivateKey); ok {
+		template.SignatureAlgorithm = x509.ECDSAWithSHA512
+	}
+
+	// alternative names may be IP addresses, or .onion and .b32.i2p names
+	names := append(append([]string{}, altNames...), strings.Split(host, ",")...)
+	for _, h := range names {
 		if ip := net.ParseIP(h); ip != nil {
 			template.IPAddresses = append(template.IPAddresses, ip)
 		} else {
@@ -68,7 +77,7 @@ func NewTLSCertificateAltNames(priv *ecdsa.PrivateKey, hosts ...string) ([]byte,
 		}
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package reseed
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
@@ -11,6 +12,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"i2pgit.org/idk/reseed-tools/su3"
 )
 
 func TestSignerFilename(t *testing.T) {
@@ -524,3 +527,35 @@ func BenchmarkNewTLSCertificate(b *testing.B) {
 		}
 	}
 }
+
+func TestNewTLSCertificateWithOptions(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate test private key: %v", err)
+	}
+
+	opts := su3.CertificateOptions{Validity: 90 * 24 * time.Hour, Organization: "Example"}
+	certBytes, err := NewTLSCertificateWithOptions(priv, opts, "reseed.example.com", "abcdefghijklmnop.onion", "abcd.b32.i2p", "10.0.0.1")
+	if err != nil {
+		t.Fatalf("NewTLSCertificateWithOptions() error = %v", err)
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	if got := cert.NotAfter.Sub(cert.NotBefore); got != opts.Validity {
+		t.Errorf("Validity = %v, want %v", got, opts.Validity)
+	}
+	if cert.Subject.Organization[0] != "Example" {
+		t.Errorf("Organization = %v, want Example", cert.Subject.Organization)
+	}
+	for _, name := range []string{"reseed.example.com", "abcdefghijklmnop.onion", "abcd.b32.i2p"} {
+		if err := cert.VerifyHostname(name); err != nil {
+			t.Errorf("Certificate is not valid for %s: %v", name, err)
+		}
+	}
+	if len(cert.IPAddresses) != 1 || cert.IPAddresses[0].String() != "10.0.0.1" {
+		t.Errorf("IPAddresses = %v, want [10.0.0.1]", cert.IPAddresses)
+	}
+}
@@ -0,0 +1,213 @@
+package reseed
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var routerInfoPattern = regexp.MustCompile("^routerInfo-[A-Za-z0-9-=~]+.dat$")
+
+// NetDbChanges records which routerInfos were added, changed or expired since
+// the changes were last taken. Each set is keyed by the routerInfo file name,
+// which is also the name used inside the su3 zip.
+type NetDbChanges struct {
+	Added   map[string]bool
+	Changed map[string]bool
+	Expired map[string]bool
+}
+
+func newNetDbChanges() NetDbChanges {
+	return NetDbChanges{
+		Added:   make(map[string]bool),
+		Changed: make(map[string]bool),
+		Expired: make(map[string]bool),
+	}
+}
+
+// Len returns the total churn recorded in c.
+func (c NetDbChanges) Len() int {
+	return len(c.Added) + len(c.Changed) + len(c.Expired)
+}
+
+// Stale reports whether a bundle containing the named routerInfo is out of
+// date because that routerInfo has since changed or expired.
+func (c NetDbChanges) Stale(name string) bool {
+	return c.Changed[name] || c.Expired[name]
+}
+
+type riState struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// netDbWatcher keeps a snapshot of the fresh routerInfos in a netDb and
+// accumulates the differences between successive observations.
+type netDbWatcher struct {
+	db *LocalNetDbImpl
+
+	known   map[string]riState
+	changes NetDbChanges
+	m       sync.Mutex
+}
+
+func newNetDbWatcher(db *LocalNetDbImpl) *netDbWatcher {
+	return &netDbWatcher{
+		db:      db,
+		known:   make(map[string]riState),
+		changes: newNetDbChanges(),
+	}
+}
+
+// observe updates the snapshot for a single path, recording any churn. A nil
+// info means the file no longer exists.
+func (w *netDbWatcher) observe(path string, info os.FileInfo) {
+	if !routerInfoPattern.MatchString(filepath.Base(path)) {
+		return
+	}
+	name := filepath.Base(path)
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	old, wasKnown := w.known[path]
+	if info == nil || time.Since(info.ModTime()) > w.db.MaxRouterInfoAge {
+		if wasKnown {
+			delete(w.known, path)
+			delete(w.changes.Added, name)
+			delete(w.changes.Changed, name)
+			w.changes.Expired[name] = true
+		}
+		return
+	}
+
+	state := riState{Size: info.Size(), ModTime: info.ModTime()}
+	w.known[path] = state
+	switch {
+	case !wasKnown:
+		delete(w.changes.Expired, name)
+		w.changes.Added[name] = true
+	case old != state && !w.changes.Added[name]:
+		w.changes.Changed[name] = true
+	}
+}
+
+// scan walks the whole netDb, recording added, changed, removed and expired
+// routerInfos.
+func (w *netDbWatcher) scan() {
+	seen := make(map[string]bool)
+	filepath.Walk(w.db.Path, func(path string, f os.FileInfo, err error) error {
+		if err != nil || f.IsDir() {
+			return nil
+		}
+		seen[path] = true
+		w.observe(path, f)
+		return nil
+	})
+
+	w.m.Lock()
+	var gone []string
+	for path := range w.known {
+		if !seen[path] {
+			gone = append(gone, path)
+		}
+	}
+	w.m.Unlock()
+
+	for _, path := range gone {
+		w.observe(path, nil)
+	}
+}
+
+// expire records every known routerInfo which has aged past the maximum
+// routerInfo age, without touching the disk.
+func (w *netDbWatcher) expire() {
+	w.m.Lock()
+	var expired []string
+	for path, state := range w.known {
+		if time.Since(state.ModTime) > w.db.MaxRouterInfoAge {
+			expired = append(expired, path)
+		}
+	}
+	w.m.Unlock()
+
+	for _, path := range expired {
+		w.observe(path, nil)
+	}
+}
+
+// take returns the accumulated changes and starts a new set.
+func (w *netDbWatcher) take() NetDbChanges {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	changes := w.changes
+	w.changes = newNetDbChanges()
+	return changes
+}
+
+// peek returns the amount of churn accumulated so far.
+func (w *netDbWatcher) peek() int {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	return w.changes.Len()
+}
+
+// poll rescans the netDb every interval until ctx is done.
+func (w *netDbWatcher) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.scan()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Watch starts tracking routerInfos which are added to, changed in or expire
+// from the netDb. On Linux inotify is used unless poll is set, falling back to
+// rescanning the netDb every interval if inotify is unavailable. Expiry is
+// checked every interval in either mode. Watching stops when ctx is done.
+func (db *LocalNetDbImpl) Watch(ctx context.Context, poll bool, interval time.Duration) {
+	w := newNetDbWatcher(db)
+	w.scan()
+	w.take()
+	db.watcher = w
+
+	if !poll {
+		err := w.inotify(ctx, interval)
+		if err == nil {
+			log.Println("Watching netDb with inotify:", db.Path)
+			return
+		}
+		log.Println("Unable to watch netDb with inotify, polling instead:", err)
+	}
+	log.Printf("Polling netDb every %s: %s\n", interval, db.Path)
+	go w.poll(ctx, interval)
+}
+
+// Churn returns the number of routerInfos added, changed or expired since the
+// changes were last taken, or -1 if the netDb is not being watched.
+func (db *LocalNetDbImpl) Churn() int {
+	if db.watcher == nil {
+		return -1
+	}
+	return db.watcher.peek()
+}
+
+// TakeChanges returns the routerInfos added, changed or expired since the last
+// call, and resets the record.
+func (db *LocalNetDbImpl) TakeChanges() NetDbChanges {
+	if db.watcher == nil {
+		return newNetDbChanges()
+	}
+	return db.watcher.take()
+}
@@ -0,0 +1,134 @@
+//go:build linux
+// +build linux
+
+package reseed
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO |
+	unix.IN_MOVED_FROM | unix.IN_DELETE | unix.IN_DELETE_SELF
+
+// inotify watches the netDb directory and each of its subdirectories for
+// routerInfo changes. Expiry is checked against the snapshot every interval.
+func (w *netDbWatcher) inotify(ctx context.Context, interval time.Duration) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+
+	dirs := make(map[int]string)
+	addWatch := func(dir string) error {
+		wd, err := unix.InotifyAddWatch(fd, dir, inotifyMask)
+		if err != nil {
+			return err
+		}
+		dirs[wd] = dir
+		return nil
+	}
+	err = filepath.Walk(w.db.Path, func(path string, f os.FileInfo, err error) error {
+		if err != nil || !f.IsDir() {
+			return nil
+		}
+		return addWatch(path)
+	})
+	if err != nil {
+		unix.Close(fd)
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.expire()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer unix.Close(fd)
+		buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			// wake up once a second to notice ctx is done
+			if _, err := unix.Poll(fds, 1000); err != nil && err != unix.EINTR {
+				log.Println("inotify poll failed, polling netDb instead:", err)
+				go w.poll(ctx, interval)
+				return
+			}
+			n, err := unix.Read(fd, buf)
+			if err != nil {
+				if err == unix.EAGAIN || err == unix.EINTR {
+					continue
+				}
+				log.Println("inotify read failed, polling netDb instead:", err)
+				go w.poll(ctx, interval)
+				return
+			}
+			for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+				event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+				offset += unix.SizeofInotifyEvent + int(event.Len)
+
+				if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+					// events were lost, so fall back to a full rescan
+					w.scan()
+					continue
+				}
+				dir, ok := dirs[int(event.Wd)]
+				if !ok {
+					continue
+				}
+				if event.Mask&unix.IN_IGNORED != 0 {
+					delete(dirs, int(event.Wd))
+					continue
+				}
+				path := filepath.Join(dir, string(bytes.TrimRight(nameBytes, "\x00")))
+				if event.Mask&unix.IN_ISDIR != 0 {
+					if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+						// files may have been put in the directory before
+						// it was watched, or it may have been moved in whole
+						filepath.Walk(path, func(path string, f os.FileInfo, err error) error {
+							if err != nil {
+								return nil
+							}
+							if !f.IsDir() {
+								w.observe(path, f)
+							} else if err := addWatch(path); err != nil {
+								log.Println("Unable to watch netDb directory:", path, err)
+							}
+							return nil
+						})
+					}
+					continue
+				}
+				if event.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0 {
+					w.observe(path, nil)
+					continue
+				}
+				if info, err := os.Stat(path); err == nil {
+					w.observe(path, info)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
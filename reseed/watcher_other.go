@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package reseed
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+func (w *netDbWatcher) inotify(ctx context.Context, interval time.Duration) error {
+	return errors.New("inotify is not supported on this platform")
+}
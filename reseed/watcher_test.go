@@ -0,0 +1,167 @@
+package reseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeRouterInfo(t *testing.T, path string, data string, age time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to create test file %s: %v", path, err)
+	}
+	modTime := time.Now().Add(-age)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set mod time for %s: %v", path, err)
+	}
+}
+
+func TestNetDbWatcher_Scan(t *testing.T) {
+	tempDir := t.TempDir()
+	subDir := filepath.Join(tempDir, "rA")
+	if err := os.Mkdir(subDir, 0o755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+
+	keep := filepath.Join(subDir, "routerInfo-keep.dat")
+	change := filepath.Join(subDir, "routerInfo-change.dat")
+	remove := filepath.Join(subDir, "routerInfo-remove.dat")
+	expire := filepath.Join(subDir, "routerInfo-expire.dat")
+	writeRouterInfo(t, keep, "keep", time.Hour)
+	writeRouterInfo(t, change, "change", time.Hour)
+	writeRouterInfo(t, remove, "remove", time.Hour)
+	writeRouterInfo(t, expire, "expire", 71*time.Hour)
+	writeRouterInfo(t, filepath.Join(subDir, "unrelated.txt"), "unrelated", time.Hour)
+
+	w := newNetDbWatcher(NewLocalNetDb(tempDir, 72*time.Hour))
+	w.scan()
+	initial := w.take()
+	if len(initial.Added) != 4 {
+		t.Errorf("Expected 4 routerInfos on the initial scan, got %d", len(initial.Added))
+	}
+
+	writeRouterInfo(t, change, "changed", time.Minute)
+	if err := os.Remove(remove); err != nil {
+		t.Fatalf("Failed to remove test file: %v", err)
+	}
+	writeRouterInfo(t, expire, "expire", 73*time.Hour)
+	writeRouterInfo(t, filepath.Join(subDir, "routerInfo-new.dat"), "new", 0)
+
+	w.scan()
+	changes := w.take()
+
+	if !changes.Added["routerInfo-new.dat"] || len(changes.Added) != 1 {
+		t.Errorf("Expected only routerInfo-new.dat to be added, got %v", changes.Added)
+	}
+	if !changes.Changed["routerInfo-change.dat"] || len(changes.Changed) != 1 {
+		t.Errorf("Expected only routerInfo-change.dat to be changed, got %v", changes.Changed)
+	}
+	if !changes.Expired["routerInfo-remove.dat"] || !changes.Expired["routerInfo-expire.dat"] || len(changes.Expired) != 2 {
+		t.Errorf("Expected routerInfo-remove.dat and routerInfo-expire.dat to be expired, got %v", changes.Expired)
+	}
+	if changes.Stale("routerInfo-keep.dat") {
+		t.Error("Unchanged routerInfo should not be stale")
+	}
+
+	w.scan()
+	if n := w.take().Len(); n != 0 {
+		t.Errorf("Expected no churn on an unchanged netDb, got %d", n)
+	}
+}
+
+func TestNetDbWatcher_Expire(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "routerInfo-old.dat")
+	writeRouterInfo(t, path, "old", 2*time.Hour)
+
+	db := NewLocalNetDb(tempDir, 3*time.Hour)
+	w := newNetDbWatcher(db)
+	w.scan()
+	w.take()
+
+	w.expire()
+	if n := w.peek(); n != 0 {
+		t.Errorf("Expected no churn before expiry, got %d", n)
+	}
+
+	db.MaxRouterInfoAge = time.Hour
+	w.expire()
+	if changes := w.take(); !changes.Expired["routerInfo-old.dat"] {
+		t.Errorf("Expected routerInfo-old.dat to expire, got %v", changes.Expired)
+	}
+}
+
+func TestLocalNetDb_Watch(t *testing.T) {
+	tempDir := t.TempDir()
+	db := NewLocalNetDb(tempDir, 72*time.Hour)
+
+	if db.Churn() != -1 {
+		t.Error("Churn() should be -1 when the netDb is not watched")
+	}
+
+	db.Watch(t.Context(), false, 10*time.Millisecond)
+
+	if err := os.Mkdir(filepath.Join(tempDir, "rB"), 0o755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	// give inotify a moment to add the new directory
+	time.Sleep(50 * time.Millisecond)
+	writeRouterInfo(t, filepath.Join(tempDir, "rB", "routerInfo-new.dat"), "new", 0)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Churn() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the new routerInfo to be noticed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if changes := db.TakeChanges(); !changes.Added["routerInfo-new.dat"] {
+		t.Errorf("Expected routerInfo-new.dat to be added, got %v", changes.Added)
+	}
+}
+
+// TestLocalNetDb_WatchNewDirectory checks that routerInfos already in a
+// directory when it appears in the netDb are noticed.
+func TestLocalNetDb_WatchNewDirectory(t *testing.T) {
+	tempDir := t.TempDir()
+	db := NewLocalNetDb(tempDir, 72*time.Hour)
+	db.Watch(t.Context(), false, 10*time.Millisecond)
+
+	// a directory created together with its files
+	staging := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(staging, "rC", "nested"), 0o755); err != nil {
+		t.Fatalf("Failed to create subdirectory: %v", err)
+	}
+	writeRouterInfo(t, filepath.Join(staging, "rC", "routerInfo-moved.dat"), "moved", 0)
+	writeRouterInfo(t, filepath.Join(staging, "rC", "nested", "routerInfo-nested.dat"), "nested", 0)
+	if err := os.Rename(filepath.Join(staging, "rC"), filepath.Join(tempDir, "rC")); err != nil {
+		t.Fatalf("Failed to move subdirectory into the netDb: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for db.Churn() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the moved routerInfos to be noticed, churn %d", db.Churn())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the nested directory is watched too
+	writeRouterInfo(t, filepath.Join(tempDir, "rC", "nested", "routerInfo-later.dat"), "later", 0)
+	for db.Churn() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a routerInfo in the nested directory to be noticed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	changes := db.TakeChanges()
+	for _, name := range []string{"routerInfo-moved.dat", "routerInfo-nested.dat", "routerInfo-later.dat"} {
+		if !changes.Added[name] {
+			t.Errorf("Expected %s to be added, got %v", name, changes.Added)
+		}
+	}
+}
@@ -4,9 +4,17 @@ import (
 	"archive/zip"
 	"bytes"
 	"io"
+	"time"
 )
 
 func zipSeeds(seeds []routerInfo) ([]byte, error) {
+	return zipSeedsAt(seeds, time.Time{})
+}
+
+// zipSeedsAt zips seeds with every entry stamped with modTime, so that the
+// archive depends only on the routerInfo names and contents. A zero modTime
+// keeps the modification time of each routerInfo.
+func zipSeedsAt(seeds []routerInfo, modTime time.Time) ([]byte, error) {
 	// Create a buffer to write our archive to.
 	buf := new(bytes.Buffer)
 
@@ -16,7 +24,11 @@ func zipSeeds(seeds []routerInfo) ([]byte, error) {
 	// Add some files to the archive.
 	for _, file := range seeds {
 		fileHeader := &zip.FileHeader{Name: file.Name, Method: zip.Deflate}
-		fileHeader.SetModTime(file.ModTime)
+		if modTime.IsZero() {
+			fileHeader.SetModTime(file.ModTime)
+		} else {
+			fileHeader.SetModTime(modTime)
+		}
 		zipFile, err := zipWriter.CreateHeader(fileHeader)
 		if err != nil {
 			return nil, err
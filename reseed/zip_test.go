@@ -88,6 +88,39 @@ func TestZipSeeds_Success(t *testing.T) {
 	}
 }
 
+func TestZipSeedsAt_FixedModTime(t *testing.T) {
+	buildTime := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	seeds := []routerInfo{
+		{Name: "routerInfo-a.dat", ModTime: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Data: []byte("a")},
+		{Name: "routerInfo-b.dat", ModTime: time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC), Data: []byte("b")},
+	}
+
+	first, err := zipSeedsAt(seeds, buildTime)
+	if err != nil {
+		t.Fatalf("zipSeedsAt() error = %v, want nil", err)
+	}
+
+	// the routerInfo mod times must not leak into the archive
+	seeds[0].ModTime = time.Now()
+	second, err := zipSeedsAt(seeds, buildTime)
+	if err != nil {
+		t.Fatalf("zipSeedsAt() error = %v, want nil", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Error("zipSeedsAt() should produce identical archives for the same build time")
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatalf("Failed to read zip: %v", err)
+	}
+	for _, f := range zipReader.File {
+		if !f.Modified.Equal(buildTime) {
+			t.Errorf("Entry %s has mod time %v, want %v", f.Name, f.Modified, buildTime)
+		}
+	}
+}
+
 func TestZipSeeds_EmptyInput(t *testing.T) {
 	// Test with empty slice
 	seeds := []routerInfo{}
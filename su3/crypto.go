@@ -80,7 +80,64 @@ func checkSignature(c *x509.Certificate, algo x509.SignatureAlgorithm, signed, s
 	return x509.ErrUnsupportedAlgorithm
 }
 
+// CertificateOptions configures generated certificates. Zero fields keep the
+// defaults.
+type CertificateOptions struct {
+	// Validity is how long the certificate is valid for
+	Validity           time.Duration
+	Organization       string
+	OrganizationalUnit string
+	Country            string
+	Province           string
+	Locality           string
+	StreetAddress      string
+}
+
+// Subject returns the subject of a certificate for commonName. Fields which
+// aren't set keep the placeholders certificates have always been made with.
+func (o CertificateOptions) Subject(commonName string) pkix.Name {
+	field := func(value, def string) []string {
+		if value == "" {
+			value = def
+		}
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	}
+	return pkix.Name{
+		Organization:       field(o.Organization, "I2P Anonymous Network"),
+		OrganizationalUnit: field(o.OrganizationalUnit, "I2P"),
+		Country:            field(o.Country, "XX"),
+		Province:           field(o.Province, ""),
+		Locality:           field(o.Locality, "XX"),
+		StreetAddress:      field(o.StreetAddress, "XX"),
+		CommonName:         commonName,
+	}
+}
+
+// RSASigType returns the su3 signature type I2P uses for an RSA key whose
+// modulus is size bytes: RSA_SHA256_2048, RSA_SHA384_3072 or
+// RSA_SHA512_4096.
+func RSASigType(size int) uint16 {
+	switch size {
+	case 256:
+		return SigTypeRSAWithSHA256
+	case 384:
+		return SigTypeRSAWithSHA384
+	default:
+		return SigTypeRSAWithSHA512
+	}
+}
+
 func NewSigningCertificate(signerID string, privateKey *rsa.PrivateKey) ([]byte, error) {
+	return NewSigningCertificateWithOptions(signerID, privateKey, CertificateOptions{})
+}
+
+// NewSigningCertificateWithOptions creates a self-signed su3 signing
+// certificate with the subject and validity given by opts. It is valid for 10
+// years by default.
+func NewSigningCertificateWithOptions(signerID string, privateKey *rsa.PrivateKey, opts CertificateOptions) ([]byte, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
@@ -102,18 +159,15 @@ func NewSigningCertificate(signerID string, privateKey *rsa.PrivateKey) ([]byte,
 		IsCA:                  isCA,
 		SubjectKeyId:          subjectKeyId,
 		SerialNumber:          serialNumber,
-		Subject: pkix.Name{
-			Organization:       []string{"I2P Anonymous Network"},
-			OrganizationalUnit: []string{"I2P"},
-			Locality:           []string{"XX"},
-			StreetAddress:      []string{"XX"},
-			Country:            []string{"XX"},
-			CommonName:         signerID,
-		},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(10, 0, 0),
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		Subject:               opts.Subject(signerID),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+
+	if opts.Validity > 0 {
+		template.NotAfter = template.NotBefore.Add(opts.Validity)
 	}
 
 	publicKey := &privateKey.PublicKey
@@ -527,3 +527,42 @@ func BenchmarkCheckSignature(b *testing.B) {
 		_ = checkSignature(cert, x509.SHA256WithRSA, testData, signature)
 	}
 }
+
+func TestNewSigningCertificateWithOptions(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	opts := CertificateOptions{
+		Validity:     90 * 24 * time.Hour,
+		Organization: "Example",
+		Country:      "DE",
+	}
+	certDER, err := NewSigningCertificateWithOptions("test@example.com", privateKey, opts)
+	if err != nil {
+		t.Fatalf("NewSigningCertificateWithOptions failed: %v", err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	if got := cert.NotAfter.Sub(cert.NotBefore); got != opts.Validity {
+		t.Errorf("Expected validity %v, got %v", opts.Validity, got)
+	}
+	if cert.Subject.Organization[0] != "Example" || cert.Subject.Country[0] != "DE" || cert.Subject.OrganizationalUnit[0] != "I2P" {
+		t.Errorf("Unexpected subject %s", cert.Subject)
+	}
+}
+
+func TestRSASigType(t *testing.T) {
+	tests := map[int]uint16{
+		256: SigTypeRSAWithSHA256,
+		384: SigTypeRSAWithSHA384,
+		512: SigTypeRSAWithSHA512,
+	}
+	for size, want := range tests {
+		if got := RSASigType(size); got != want {
+			t.Errorf("RSASigType(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
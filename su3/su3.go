@@ -28,8 +28,14 @@ type File struct {
 }
 
 func New() *File {
+	return NewAt(time.Now())
+}
+
+// NewAt creates a File whose version is the unix timestamp of t, so that
+// builds at a fixed time produce identical headers.
+func NewAt(t time.Time) *File {
 	return &File{
-		Version:       []byte(strconv.FormatInt(time.Now().Unix(), 10)),
+		Version:       []byte(strconv.FormatInt(t.Unix(), 10)),
 		SignatureType: SigTypeRSAWithSHA512,
 	}
 }
@@ -39,10 +45,24 @@ func (s *File) Sign(privkey *rsa.PrivateKey) error {
 		return fmt.Errorf("private key cannot be nil")
 	}
 
+	return s.SignWith(privkey)
+}
+
+// SignWith signs the file with any crypto.Signer holding an RSA key, such as
+// a key held by a separate signing process. The signer is asked for a raw
+// PKCS#1 v1.5 signature of the digest, the same as Sign makes.
+func (s *File) SignWith(signer crypto.Signer) error {
+	if signer == nil {
+		return fmt.Errorf("signer cannot be nil")
+	}
+	pub, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("signer must hold an RSA key")
+	}
+
 	// Pre-calculate signature length based on RSA key size
 	// This ensures BodyBytes() generates the correct header
-	keySize := privkey.Size()           // Returns key size in bytes
-	s.Signature = make([]byte, keySize) // Temporary signature with correct length
+	s.ReserveSignature(pub.Size())
 
 	var hashType crypto.Hash
 	switch s.SignatureType {
@@ -62,7 +82,8 @@ func (s *File) Sign(privkey *rsa.PrivateKey) error {
 	h.Write(s.BodyBytes())
 	digest := h.Sum(nil)
 
-	sig, err := rsa.SignPKCS1v15(rand.Reader, privkey, 0, digest)
+	// the digest is already hashed, so we force a 0 here
+	sig, err := signer.Sign(rand.Reader, digest, crypto.Hash(0))
 	if nil != err {
 		return err
 	}
@@ -72,6 +93,13 @@ func (s *File) Sign(privkey *rsa.PrivateKey) error {
 	return nil
 }
 
+// ReserveSignature sets a zeroed signature of size bytes, so BodyBytes()
+// returns the exact bytes a key of that size will sign. It is used to build
+// unsigned files which are signed elsewhere.
+func (s *File) ReserveSignature(size int) {
+	s.Signature = make([]byte, size)
+}
+
 func (s *File) BodyBytes() []byte {
 	var (
 		buf = new(bytes.Buffer)
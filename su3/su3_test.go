@@ -2,12 +2,14 @@ package su3
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -31,6 +33,24 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewAt(t *testing.T) {
+	buildTime := time.Unix(1700000000, 0)
+	a := NewAt(buildTime)
+	b := NewAt(buildTime)
+
+	if string(a.Version) != "1700000000" {
+		t.Errorf("Expected Version 1700000000, got %q", a.Version)
+	}
+
+	a.SignerID = []byte("test@mail.i2p")
+	b.SignerID = []byte("test@mail.i2p")
+	a.Content = []byte("content")
+	b.Content = []byte("content")
+	if !bytes.Equal(a.BodyBytes(), b.BodyBytes()) {
+		t.Error("Files created at the same time should have identical bodies")
+	}
+}
+
 func TestFile_Sign(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -319,6 +339,77 @@ func TestFile_VerifySignature(t *testing.T) {
 	}
 }
 
+func TestFile_SignWith(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+
+	signed := New()
+	signed.SignerID = []byte("test@example.com")
+	signed.Content = []byte("test content")
+	if err := signed.Sign(privateKey); err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+
+	// any crypto.Signer holding the key makes the same signature
+	var signer crypto.Signer = privateKey
+	signedWith := New()
+	signedWith.Version = signed.Version
+	signedWith.SignerID = signed.SignerID
+	signedWith.Content = signed.Content
+	if err := signedWith.SignWith(signer); err != nil {
+		t.Fatalf("SignWith() failed: %v", err)
+	}
+	if !bytes.Equal(signed.Signature, signedWith.Signature) {
+		t.Error("SignWith() should make the same signature as Sign()")
+	}
+
+	if err := New().SignWith(nil); err == nil {
+		t.Error("SignWith() should fail without a signer")
+	}
+}
+
+func TestFile_ReserveSignature(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate RSA key: %v", err)
+	}
+	cert, err := NewSigningCertificate("test@example.com", privateKey)
+	if err != nil {
+		t.Fatalf("Failed to create test certificate: %v", err)
+	}
+	parsedCert, err := x509.ParseCertificate(cert)
+	if err != nil {
+		t.Fatalf("Failed to parse test certificate: %v", err)
+	}
+
+	// build an unsigned file without the key
+	unsigned := New()
+	unsigned.Content = []byte("test content")
+	unsigned.SignerID = []byte("test@example.com")
+	unsigned.ReserveSignature(privateKey.Size())
+	body := unsigned.BodyBytes()
+
+	// sign the body elsewhere
+	offline := New()
+	if err := offline.UnmarshalBinary(body); err != nil {
+		t.Fatalf("Failed to parse unsigned body: %v", err)
+	}
+	if err := offline.Sign(privateKey); err != nil {
+		t.Fatalf("Failed to sign file: %v", err)
+	}
+	if !bytes.Equal(offline.BodyBytes(), body) {
+		t.Fatal("Signing should not change the body")
+	}
+
+	// attach the signature to the original body
+	unsigned.Signature = offline.Signature
+	if err := unsigned.VerifySignature(parsedCert); err != nil {
+		t.Errorf("Signature made elsewhere should verify: %v", err)
+	}
+}
+
 func TestFile_String(t *testing.T) {
 	file := New()
 	file.Format = 1